@@ -0,0 +1,227 @@
+package account
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/turgaysozen/algotrading/clock"
+	"github.com/turgaysozen/algotrading/config"
+	"github.com/turgaysozen/algotrading/db"
+	"github.com/turgaysozen/algotrading/decimal"
+	"github.com/turgaysozen/algotrading/execution"
+	"github.com/turgaysozen/algotrading/models"
+	"github.com/turgaysozen/algotrading/monitoring/metrics"
+)
+
+// Balances are tracked per asset as free and locked amounts. In paper mode a ledger is
+// simulated from the fills the strategy records, with working limit and parent orders
+// locking what they would spend; in live mode they are read from the exchange account.
+
+// Trading modes, as in TRADING_MODE.
+const (
+	Paper = "paper"
+	Live  = "live"
+)
+
+type Config struct {
+	Mode string
+	// PaperBalances seeds the paper ledger when nothing is stored yet, e.g. USDT:10000
+	PaperBalances     map[string]float64
+	ReconcileInterval time.Duration
+	// Tolerance is the absolute difference per asset below which balances agree
+	Tolerance decimal.Decimal
+	// AutoCorrect resets the paper ledger to the orders table, or in live mode accepts the
+	// exchange's balance as the new baseline, once a drift is confirmed.
+	AutoCorrect bool
+}
+
+func LoadConfig() Config {
+	return Config{
+		Mode:              config.GetEnv("TRADING_MODE", Paper),
+		PaperBalances:     config.GetEnvFloatMap("PAPER_BALANCES"),
+		ReconcileInterval: config.GetEnvDuration("RECONCILE_INTERVAL", time.Minute),
+		Tolerance:         decimal.FromFloat(config.GetEnvFloat("RECONCILE_TOLERANCE", 0.00000001)),
+		AutoCorrect:       config.GetEnvBool("RECONCILE_AUTO_CORRECT", false),
+	}
+}
+
+// Source reports an account's balances by asset.
+type Source interface {
+	Balances() (map[string]models.Balance, error)
+}
+
+var (
+	mu     sync.Mutex
+	cfg    Config
+	source Source
+	// ledger holds the paper account's total per asset; nil outside paper mode
+	ledger map[string]decimal.Decimal
+	clk    clock.Clock = clock.Real{}
+)
+
+// SetClock replaces the wall clock balances and reconciliations are stamped with.
+func SetClock(c clock.Clock) {
+	clk = c
+}
+
+// Init loads the paper ledger or connects the live account, then starts the reconciler.
+func Init() {
+	mu.Lock()
+	cfg = LoadConfig()
+
+	switch cfg.Mode {
+	case Live:
+		source = newBinanceAccount()
+	default:
+		if cfg.Mode != Paper {
+			log.Printf("Unknown TRADING_MODE %q, using paper balances", cfg.Mode)
+			metrics.RecordError("trading_mode_invalid")
+			cfg.Mode = Paper
+		}
+		ledger = loadLedger()
+		source = paperAccount{}
+	}
+	mu.Unlock()
+
+	startReconciler()
+}
+
+// loadLedger starts from the last stored paper balances, or PAPER_BALANCES on a first run.
+func loadLedger() map[string]decimal.Decimal {
+	balances := make(map[string]decimal.Decimal)
+	stored, err := db.LoadBalances(Paper)
+	if err != nil {
+		log.Printf("Error loading paper balances, starting from PAPER_BALANCES: %v", err)
+	}
+	if len(stored) > 0 {
+		for _, b := range stored {
+			balances[b.Asset] = b.Free.Add(b.Locked)
+		}
+		return balances
+	}
+	for asset, amount := range cfg.PaperBalances {
+		balances[asset] = decimal.FromFloat(amount)
+	}
+	return balances
+}
+
+// RecordFill moves a paper fill through the ledger: a buy adds quantity of the base asset
+// and spends quantity times price of the quote, a sell the reverse. Live fills are already
+// in the exchange's balances, so this does nothing outside paper mode.
+func RecordFill(fill models.Fill) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if ledger == nil {
+		return
+	}
+	base, quote, ok := config.SplitSymbol(fill.Symbol)
+	if !ok {
+		log.Printf("Unknown quote asset for %s, fill not applied to balances", fill.Symbol)
+		metrics.RecordError("balance_unknown_symbol")
+		return
+	}
+	applyFill(ledger, base, quote, fill)
+
+	// stored on every fill so a restart resumes from the ledger instead of the last reconciliation
+	balances := paperBalances()
+	var touched []models.Balance
+	for _, asset := range []string{base, quote} {
+		b := balances[asset]
+		b.UpdatedAt = fill.EventTime
+		touched = append(touched, b)
+	}
+	if err := db.SaveBalances(Paper, touched); err != nil {
+		metrics.RecordError("balance_save_error")
+	}
+}
+
+// applyFill moves fill's quantity and notional between base and quote; its fee is paid in quote.
+func applyFill(totals map[string]decimal.Decimal, base, quote string, fill models.Fill) {
+	notional := fill.Quantity.Mul(fill.Price)
+	totals[quote] = totals[quote].Sub(fill.Fee)
+	if fill.Side == "buy" {
+		totals[base] = totals[base].Add(fill.Quantity)
+		totals[quote] = totals[quote].Sub(notional)
+		return
+	}
+	totals[base] = totals[base].Sub(fill.Quantity)
+	totals[quote] = totals[quote].Add(notional)
+}
+
+// Balances returns the current balance of every asset.
+func Balances() (map[string]models.Balance, error) {
+	mu.Lock()
+	src := source
+	mu.Unlock()
+
+	if src == nil {
+		return map[string]models.Balance{}, nil
+	}
+	return src.Balances()
+}
+
+type paperAccount struct{}
+
+func (paperAccount) Balances() (map[string]models.Balance, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	return paperBalances(), nil
+}
+
+// paperBalances splits the ledger into free and locked, locking the quote asset a working
+// buy would spend and the base asset a working sell would deliver. Callers hold mu.
+func paperBalances() map[string]models.Balance {
+	locked := make(map[string]decimal.Decimal)
+	lock := func(symbol, side string, remaining, price decimal.Decimal) {
+		base, quote, ok := config.SplitSymbol(symbol)
+		if !ok || remaining.Sign() <= 0 {
+			return
+		}
+		if side == "buy" {
+			locked[quote] = locked[quote].Add(remaining.Mul(price))
+		} else {
+			locked[base] = locked[base].Add(remaining)
+		}
+	}
+
+	for _, o := range execution.LimitOrders() {
+		if o.Status == execution.StatusOpen || o.Status == execution.StatusPartiallyFilled {
+			lock(o.Symbol, o.Side, o.Quantity.Sub(o.FilledQty), o.Price)
+		}
+	}
+	for _, p := range execution.Orders() {
+		if p.Status != execution.StatusWorking {
+			continue
+		}
+		price := p.LimitPrice
+		if price.IsZero() {
+			price = p.ArrivalPrice
+		}
+		lock(p.Symbol, p.Side, p.Quantity.Sub(p.FilledQty), price)
+	}
+
+	now := clk.Now().UnixMilli()
+	balances := make(map[string]models.Balance, len(ledger))
+	for asset, total := range ledger {
+		balances[asset] = models.Balance{Asset: asset, Free: total.Sub(locked[asset]), Locked: locked[asset], UpdatedAt: now}
+	}
+	for asset, amount := range locked {
+		if _, ok := balances[asset]; !ok {
+			balances[asset] = models.Balance{Asset: asset, Free: amount.Neg(), Locked: amount, UpdatedAt: now}
+		}
+	}
+	return balances
+}
+
+// sortedBalances returns balances in asset order.
+func sortedBalances(balances map[string]models.Balance) []models.Balance {
+	list := make([]models.Balance, 0, len(balances))
+	for _, b := range balances {
+		list = append(list, b)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Asset < list[j].Asset })
+	return list
+}
@@ -0,0 +1,36 @@
+package account
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// BalancesHandler serves /account/balances: GET returns the trading mode, every asset's free
+// and locked balance and the most recent drifts the reconciler confirmed.
+func BalancesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	balances, err := Balances()
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprintf(w, `{"error": %q}`, err.Error())
+		return
+	}
+
+	mu.Lock()
+	mode := cfg.Mode
+	mu.Unlock()
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"mode":     mode,
+		"balances": sortedBalances(balances),
+		"drifts":   Drifts(),
+	})
+}
@@ -0,0 +1,90 @@
+package account
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/turgaysozen/algotrading/config"
+	"github.com/turgaysozen/algotrading/decimal"
+	"github.com/turgaysozen/algotrading/models"
+)
+
+// binanceAccount reads live balances from Binance's signed account endpoint.
+type binanceAccount struct {
+	baseURL   string
+	apiKey    string
+	apiSecret string
+	client    *http.Client
+}
+
+func newBinanceAccount() binanceAccount {
+	return binanceAccount{
+		baseURL:   config.GetEnv("BINANCE_API_URL", "https://api.binance.com"),
+		apiKey:    config.GetEnv("BINANCE_API_KEY", ""),
+		apiSecret: config.GetEnv("BINANCE_API_SECRET", ""),
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (b binanceAccount) Balances() (map[string]models.Balance, error) {
+	if b.apiKey == "" || b.apiSecret == "" {
+		return nil, fmt.Errorf("BINANCE_API_KEY and BINANCE_API_SECRET are required in live mode")
+	}
+
+	now := time.Now().UnixMilli()
+	query := url.Values{}
+	query.Set("omitZeroBalances", "true")
+	query.Set("recvWindow", "5000")
+	query.Set("timestamp", strconv.FormatInt(now, 10))
+	mac := hmac.New(sha256.New, []byte(b.apiSecret))
+	mac.Write([]byte(query.Encode()))
+	signed := query.Encode() + "&signature=" + hex.EncodeToString(mac.Sum(nil))
+
+	req, err := http.NewRequest(http.MethodGet, b.baseURL+"/api/v3/account?"+signed, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-MBX-APIKEY", b.apiKey)
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("binance account: %s", resp.Status)
+	}
+
+	var account struct {
+		Balances []struct {
+			Asset  string `json:"asset"`
+			Free   string `json:"free"`
+			Locked string `json:"locked"`
+		} `json:"balances"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&account); err != nil {
+		return nil, err
+	}
+
+	balances := make(map[string]models.Balance, len(account.Balances))
+	for _, raw := range account.Balances {
+		free, err := decimal.FromString(raw.Free)
+		if err != nil {
+			return nil, fmt.Errorf("binance %s free balance: %w", raw.Asset, err)
+		}
+		locked, err := decimal.FromString(raw.Locked)
+		if err != nil {
+			return nil, fmt.Errorf("binance %s locked balance: %w", raw.Asset, err)
+		}
+		balances[raw.Asset] = models.Balance{Asset: raw.Asset, Free: free, Locked: locked, UpdatedAt: now}
+	}
+	return balances, nil
+}
@@ -0,0 +1,178 @@
+package account
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/turgaysozen/algotrading/config"
+	"github.com/turgaysozen/algotrading/db"
+	"github.com/turgaysozen/algotrading/decimal"
+	"github.com/turgaysozen/algotrading/models"
+	"github.com/turgaysozen/algotrading/monitoring/metrics"
+)
+
+// The reconciler compares the account with what the orders table says it should hold: the
+// balances when the reconciler started plus every fill recorded since. A fill reaches the
+// ledger a moment before its order is saved, so a drift only counts once two passes in a
+// row find the same difference.
+
+var (
+	reconcileOnce sync.Once
+	// baseline is the total per asset when reconciliation started, at baselineTime
+	baseline     map[string]decimal.Decimal
+	baselineTime time.Time
+	// pending holds the drifts found by the last pass, waiting to be confirmed, and
+	// confirmed the uncorrected ones already flagged, which keep the alert raised
+	pending   = make(map[string]decimal.Decimal)
+	confirmed = make(map[string]decimal.Decimal)
+	drifts    []models.BalanceDrift
+)
+
+// maxDrifts recent drifts are kept for the API.
+const maxDrifts = 100
+
+func startReconciler() {
+	reconcileOnce.Do(func() {
+		if balances, err := Balances(); err == nil {
+			setBaseline(balances)
+		} else {
+			log.Printf("Error reading %s balances, reconciling from the first successful read: %v", cfg.Mode, err)
+			metrics.RecordError("balance_fetch_error")
+		}
+
+		go func() {
+			for range time.Tick(cfg.ReconcileInterval) {
+				Reconcile()
+			}
+		}()
+	})
+}
+
+func setBaseline(balances map[string]models.Balance) {
+	mu.Lock()
+	defer mu.Unlock()
+	baseline = make(map[string]decimal.Decimal, len(balances))
+	for asset, b := range balances {
+		baseline[asset] = b.Free.Add(b.Locked)
+	}
+	baselineTime = clk.Now()
+}
+
+// Reconcile runs one pass: it stores and exports the account's balances, compares them with
+// the orders table and flags, and with RECONCILE_AUTO_CORRECT corrects, confirmed drifts.
+func Reconcile() {
+	actual, err := Balances()
+	if err != nil {
+		log.Printf("Error reading %s balances: %v", cfg.Mode, err)
+		metrics.RecordError("balance_fetch_error")
+		return
+	}
+
+	mu.Lock()
+	started := baseline != nil
+	since := baselineTime
+	mu.Unlock()
+	if !started {
+		setBaseline(actual)
+		return
+	}
+
+	fills, err := db.GetFillsSince(since)
+	if err != nil {
+		log.Printf("Error reading fills to reconcile: %v", err)
+		metrics.RecordError("reconcile_error")
+		return
+	}
+
+	list := sortedBalances(actual)
+	for _, b := range list {
+		metrics.SetAccountBalance(b.Asset, b.Free.Float64(), b.Locked.Float64())
+	}
+	if len(list) > 0 {
+		if err := db.SaveBalances(cfg.Mode, list); err != nil {
+			metrics.RecordError("balance_save_error")
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	expected := make(map[string]decimal.Decimal, len(baseline))
+	for asset, total := range baseline {
+		expected[asset] = total
+	}
+	for _, fill := range fills {
+		if base, quote, ok := config.SplitSymbol(fill.Symbol); ok {
+			applyFill(expected, base, quote, fill)
+		}
+	}
+
+	assets := make(map[string]bool)
+	for asset := range expected {
+		assets[asset] = true
+	}
+	for asset := range actual {
+		assets[asset] = true
+	}
+
+	diverged := false
+	now := clk.Now().UnixMilli()
+	for asset := range assets {
+		have := actual[asset].Free.Add(actual[asset].Locked)
+		drift := have.Sub(expected[asset])
+		metrics.SetBalanceDrift(asset, drift.Float64())
+
+		if drift.Abs().Cmp(cfg.Tolerance) <= 0 {
+			delete(pending, asset)
+			delete(confirmed, asset)
+			continue
+		}
+		if flagged, ok := confirmed[asset]; ok && flagged.Cmp(drift) == 0 {
+			diverged = true
+			continue
+		}
+		previous, seen := pending[asset]
+		pending[asset] = drift
+		if !seen || previous.Cmp(drift) != 0 {
+			continue
+		}
+
+		diverged = true
+		delete(pending, asset)
+		d := models.BalanceDrift{Asset: asset, Expected: expected[asset], Actual: have, Drift: drift, DetectedAt: now}
+		if cfg.AutoCorrect {
+			correct(asset, drift)
+			d.Corrected = true
+		} else {
+			confirmed[asset] = drift
+		}
+		log.Printf("Balance drift for %s: account holds %s, orders imply %s (corrected: %t)", asset, have, expected[asset], d.Corrected)
+		metrics.RecordBalanceDrift(asset)
+		db.SaveBalanceDrift(cfg.Mode, d)
+
+		drifts = append(drifts, d)
+		if len(drifts) > maxDrifts {
+			drifts = drifts[1:]
+		}
+	}
+	metrics.SetReconciliationAlert(diverged)
+}
+
+// correct settles a confirmed drift. The orders table is the record of what the paper account
+// traded, so the ledger is moved back to it; a live account is the truth, e.g. after a
+// deposit, so the baseline absorbs the difference. Callers hold mu.
+func correct(asset string, drift decimal.Decimal) {
+	if ledger != nil {
+		ledger[asset] = ledger[asset].Sub(drift)
+		return
+	}
+	baseline[asset] = baseline[asset].Add(drift)
+}
+
+// Drifts returns the most recent confirmed drifts, oldest first.
+func Drifts() []models.BalanceDrift {
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]models.BalanceDrift(nil), drifts...)
+}
@@ -0,0 +1,371 @@
+package bars
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/turgaysozen/algotrading/config"
+	"github.com/turgaysozen/algotrading/db"
+	"github.com/turgaysozen/algotrading/models"
+	"github.com/turgaysozen/algotrading/monitoring/metrics"
+)
+
+// Bars are driven by exchange event time: a time bar closes when the first event of a later
+// interval arrives, and every empty interval in between is emitted as a flat, zero volume bar.
+// Closed bars are stored and handed to subscribers in order by one worker, so a slow database
+// or strategy never holds up the feed.
+
+const (
+	maxGapFill = 3600
+	// maxGapDispatch is the most gap bars of one outage subscribers get; the rest are only stored
+	maxGapDispatch = 60
+)
+
+const (
+	timeBar = iota
+	tickBar
+	volumeBar
+)
+
+type spec struct {
+	name     string
+	kind     int
+	duration int64 // milliseconds, time bars only
+	ticks    int
+	volume   float64
+}
+
+// parseTimeframe accepts Go durations ("1s", "1m", "5m", "1h"), "tick_<n>" and "volume_<qty>".
+func parseTimeframe(timeframe string) (spec, error) {
+	switch {
+	case strings.HasPrefix(timeframe, "tick_"):
+		n, err := strconv.Atoi(strings.TrimPrefix(timeframe, "tick_"))
+		if err != nil || n <= 0 {
+			return spec{}, fmt.Errorf("invalid tick bar size in %q", timeframe)
+		}
+		return spec{name: timeframe, kind: tickBar, ticks: n}, nil
+	case strings.HasPrefix(timeframe, "volume_"):
+		v, err := strconv.ParseFloat(strings.TrimPrefix(timeframe, "volume_"), 64)
+		if err != nil || v <= 0 {
+			return spec{}, fmt.Errorf("invalid volume bar size in %q", timeframe)
+		}
+		return spec{name: timeframe, kind: volumeBar, volume: v}, nil
+	default:
+		d, err := time.ParseDuration(timeframe)
+		if err != nil || d < time.Second || d%time.Second != 0 {
+			return spec{}, fmt.Errorf("invalid bar timeframe %q", timeframe)
+		}
+		return spec{name: timeframe, kind: timeBar, duration: d.Milliseconds()}, nil
+	}
+}
+
+type builder struct {
+	spec      spec
+	symbol    string
+	bar       *models.Bar
+	lastClose float64
+}
+
+// closed is a bar for the worker to store and, if dispatch is set, hand to subscribers. A late
+// one carries only the volume of a trade that arrived after its bar closed.
+type closed struct {
+	bar      models.Bar
+	dispatch bool
+	late     bool
+}
+
+var (
+	mu       sync.Mutex
+	specs    []spec
+	builders = make(map[string]map[string]*builder)
+	// priceFromTrades makes trades, rather than mid prices, drive OHLC
+	priceFromTrades bool
+
+	subscribersMu sync.RWMutex
+	subscribers   = make(map[string][]func(models.Bar))
+
+	// pending is queued under mu, so it's in the order the bars closed
+	pendingMu   sync.Mutex
+	pendingCond = sync.NewCond(&pendingMu)
+	pending     []closed
+	working     bool
+	workerOnce  sync.Once
+)
+
+// Init builds the timeframes listed in BAR_TIMEFRAMES for every symbol.
+func Init() {
+	priceFromTrades = config.GetEnv("BAR_PRICE_SOURCE", "mid") == "trade"
+
+	for _, timeframe := range strings.Split(config.GetEnv("BAR_TIMEFRAMES", "1s,1m,5m,1h"), ",") {
+		timeframe = strings.TrimSpace(timeframe)
+		if timeframe == "" {
+			continue
+		}
+		if err := addTimeframe(timeframe); err != nil {
+			log.Println("Skipping bar timeframe:", err)
+			metrics.RecordError("bar_timeframe_invalid")
+		}
+	}
+}
+
+func addTimeframe(timeframe string) error {
+	s, err := parseTimeframe(timeframe)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, existing := range specs {
+		if existing.name == s.name {
+			return nil
+		}
+	}
+	specs = append(specs, s)
+	return nil
+}
+
+// Subscribe calls handler with every closed bar of the timeframe, building it if it isn't configured.
+func Subscribe(timeframe string, handler func(models.Bar)) error {
+	if err := addTimeframe(timeframe); err != nil {
+		return err
+	}
+
+	subscribersMu.Lock()
+	subscribers[timeframe] = append(subscribers[timeframe], handler)
+	subscribersMu.Unlock()
+	return nil
+}
+
+// Flush waits until every bar closed so far is stored and handed to its subscribers.
+func Flush() {
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+	for len(pending) > 0 || working {
+		pendingCond.Wait()
+	}
+}
+
+func AddMidPrice(symbol string, eventTime int64, price float64) {
+	update(symbol, eventTime, price, 0, !priceFromTrades)
+}
+
+func AddTrade(symbol string, eventTime int64, price, qty float64) {
+	update(symbol, eventTime, price, qty, priceFromTrades)
+}
+
+func update(symbol string, eventTime int64, price, qty float64, isPrice bool) {
+	mu.Lock()
+	symbolBuilders, ok := builders[symbol]
+	if !ok {
+		symbolBuilders = make(map[string]*builder)
+		builders[symbol] = symbolBuilders
+	}
+
+	var done []closed
+	for _, s := range specs {
+		b, ok := symbolBuilders[s.name]
+		if !ok {
+			b = &builder{spec: s, symbol: symbol}
+			symbolBuilders[s.name] = b
+		}
+		done = append(done, b.update(eventTime, price, qty, isPrice)...)
+	}
+
+	if len(done) > 0 {
+		enqueue(done)
+	}
+	mu.Unlock()
+}
+
+func enqueue(done []closed) {
+	workerOnce.Do(func() { go work() })
+
+	pendingMu.Lock()
+	pending = append(pending, done...)
+	pendingMu.Unlock()
+	pendingCond.Broadcast()
+}
+
+// work stores each batch of closed bars in one transaction, then adds the late trades to their
+// stored bars and hands the bars to subscribers, in the order they closed.
+func work() {
+	for {
+		pendingMu.Lock()
+		for len(pending) == 0 {
+			pendingCond.Wait()
+		}
+		batch := pending
+		pending = nil
+		working = true
+		pendingMu.Unlock()
+
+		var bars []models.Bar
+		for _, c := range batch {
+			if !c.late {
+				bars = append(bars, c.bar)
+			}
+		}
+		if len(bars) > 0 {
+			if err := db.SaveBars(bars); err != nil {
+				metrics.RecordDataLoss("bar_save_data_loss")
+			}
+		}
+
+		for _, c := range batch {
+			if c.late {
+				if added, err := db.AddBarTrade(c.bar.Symbol, c.bar.Timeframe, c.bar.OpenTime, c.bar.Volume); err == nil && !added {
+					metrics.RecordError("bar_late_event")
+				}
+				continue
+			}
+			if c.dispatch {
+				dispatch(c.bar)
+			}
+		}
+
+		pendingMu.Lock()
+		working = false
+		pendingMu.Unlock()
+		pendingCond.Broadcast()
+	}
+}
+
+func dispatch(bar models.Bar) {
+	subscribersMu.RLock()
+	handlers := subscribers[bar.Timeframe]
+	subscribersMu.RUnlock()
+	for _, handler := range handlers {
+		handler(bar)
+	}
+}
+
+func (b *builder) update(eventTime int64, price, qty float64, isPrice bool) []closed {
+	var done []closed
+
+	if b.spec.kind == timeBar {
+		bucket := eventTime - eventTime%b.spec.duration
+		if b.bar != nil && bucket < b.bar.OpenTime {
+			// a late trade's volume still belongs to its bar; a late price can't reopen it
+			if qty > 0 {
+				late := models.Bar{Symbol: b.symbol, Timeframe: b.spec.name, OpenTime: bucket, Volume: qty}
+				return []closed{{bar: late, late: true}}
+			}
+			metrics.RecordError("bar_late_event")
+			return nil
+		}
+		if b.bar != nil && bucket > b.bar.OpenTime {
+			from := b.bar.OpenTime
+			done = append(done, closed{bar: b.close(), dispatch: true})
+			done = append(done, b.fillGap(from, bucket)...)
+		}
+		if b.bar == nil {
+			b.open(bucket, price)
+			b.bar.CloseTime = bucket + b.spec.duration
+		}
+	} else if b.bar == nil {
+		b.open(eventTime, price)
+	}
+
+	b.apply(eventTime, price, qty, isPrice)
+
+	switch b.spec.kind {
+	case tickBar:
+		if b.bar.TickCount >= b.spec.ticks {
+			done = append(done, closed{bar: b.close(), dispatch: true})
+		}
+	case volumeBar:
+		if b.bar.Volume >= b.spec.volume {
+			done = append(done, closed{bar: b.close(), dispatch: true})
+		}
+	}
+
+	return done
+}
+
+// open starts a bar at the previous close so bars chain without gaps, or at price for the first bar.
+func (b *builder) open(openTime int64, price float64) {
+	start := b.lastClose
+	if start == 0 {
+		start = price
+	}
+	b.bar = &models.Bar{
+		Symbol:    b.symbol,
+		Timeframe: b.spec.name,
+		OpenTime:  openTime,
+		CloseTime: openTime,
+		Open:      start,
+		High:      start,
+		Low:       start,
+		Close:     start,
+	}
+}
+
+func (b *builder) apply(eventTime int64, price, qty float64, isPrice bool) {
+	bar := b.bar
+	if b.spec.kind != timeBar {
+		bar.CloseTime = eventTime
+	}
+	if qty > 0 {
+		bar.Volume += qty
+		bar.TradeCount++
+	}
+	if !isPrice {
+		return
+	}
+
+	if bar.TickCount == 0 {
+		bar.Open, bar.High, bar.Low = price, price, price
+	}
+	if price > bar.High {
+		bar.High = price
+	}
+	if price < bar.Low {
+		bar.Low = price
+	}
+	bar.Close = price
+	bar.TickCount++
+}
+
+func (b *builder) close() models.Bar {
+	bar := *b.bar
+	b.lastClose = bar.Close
+	b.bar = nil
+	return bar
+}
+
+// fillGap emits flat bars for the empty intervals between the bar opened at from and bucket,
+// keeping only the most recent maxGapFill of them after a long outage and handing only the
+// most recent maxGapDispatch to subscribers.
+func (b *builder) fillGap(from, bucket int64) []closed {
+	first := from + b.spec.duration
+	if limit := bucket - maxGapFill*b.spec.duration; first < limit {
+		first = limit
+	}
+	dispatchFrom := bucket - maxGapDispatch*b.spec.duration
+
+	var filled []closed
+	for openTime := first; openTime < bucket; openTime += b.spec.duration {
+		filled = append(filled, closed{
+			bar: models.Bar{
+				Symbol:    b.symbol,
+				Timeframe: b.spec.name,
+				OpenTime:  openTime,
+				CloseTime: openTime + b.spec.duration,
+				Open:      b.lastClose,
+				High:      b.lastClose,
+				Low:       b.lastClose,
+				Close:     b.lastClose,
+			},
+			dispatch: openTime >= dispatchFrom,
+		})
+	}
+	if len(filled) > maxGapDispatch {
+		metrics.RecordError("bar_gap_not_dispatched")
+	}
+	return filled
+}
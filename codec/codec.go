@@ -0,0 +1,92 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/turgaysozen/algotrading/monitoring/metrics"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Every bus payload starts with a 3 byte header: magic, content type, schema version.
+// Payloads without the magic byte are treated as legacy headerless JSON so that
+// old producers keep working while consumers roll forward.
+const (
+	magic      byte = 0xA7
+	headerSize      = 3
+
+	ContentTypeJSON    byte = 0x01
+	ContentTypeMsgPack byte = 0x02
+
+	// SchemaVersion is bumped whenever a bus message loses or changes a field.
+	// Adding fields does not require a bump since both encodings skip unknown keys.
+	// Version 2: order book levels are [price, qty] floats instead of string pairs.
+	SchemaVersion byte = 2
+)
+
+var ErrUnknownContentType = errors.New("unknown bus content type")
+
+// ContentTypeFromString maps the BUS_ENCODING setting to a content type, defaulting to MessagePack.
+func ContentTypeFromString(s string) byte {
+	switch strings.ToLower(s) {
+	case "json":
+		return ContentTypeJSON
+	default:
+		return ContentTypeMsgPack
+	}
+}
+
+func Encode(contentType byte, v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write([]byte{magic, contentType, SchemaVersion})
+
+	switch contentType {
+	case ContentTypeJSON:
+		if err := json.NewEncoder(&buf).Encode(v); err != nil {
+			return nil, err
+		}
+	case ContentTypeMsgPack:
+		enc := msgpack.GetEncoder()
+		defer msgpack.PutEncoder(enc)
+		enc.Reset(&buf)
+		// json tags are the schema for both encodings, so field keys stay identical
+		enc.SetCustomStructTag("json")
+		enc.UseCompactInts(true)
+		if err := enc.Encode(v); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: 0x%02x", ErrUnknownContentType, contentType)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func Decode(data []byte, v interface{}) error {
+	if len(data) < headerSize || data[0] != magic {
+		metrics.RecordLegacyPayload()
+		return json.Unmarshal(data, v)
+	}
+
+	contentType, version, body := data[1], data[2], data[headerSize:]
+	if version > SchemaVersion {
+		// newer producer: decode what we know and let unknown fields fall through
+		metrics.RecordError("codec_newer_schema_version")
+	}
+
+	switch contentType {
+	case ContentTypeJSON:
+		return json.Unmarshal(body, v)
+	case ContentTypeMsgPack:
+		dec := msgpack.GetDecoder()
+		defer msgpack.PutDecoder(dec)
+		dec.Reset(bytes.NewReader(body))
+		dec.SetCustomStructTag("json")
+		return dec.Decode(v)
+	default:
+		return fmt.Errorf("%w: 0x%02x", ErrUnknownContentType, contentType)
+	}
+}
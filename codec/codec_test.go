@@ -0,0 +1,112 @@
+package codec
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/turgaysozen/algotrading/models"
+)
+
+func sampleOrderBook() models.OrderBook {
+	book := models.OrderBook{
+		Exchange:      "binance",
+		EventType:     "depthUpdate",
+		Symbol:        "BTCUSDT",
+		EventTime:     1718000000123,
+		FirstUpdateID: 4012345,
+		FinalUpdateID: 4012399,
+	}
+	for i := 0; i < 20; i++ {
+		book.Bids = append(book.Bids, models.PriceLevel{Price: 67000 - float64(i)*0.5, Qty: 0.125 + float64(i)})
+		book.Asks = append(book.Asks, models.PriceLevel{Price: 67000.5 + float64(i)*0.5, Qty: 0.25 + float64(i)})
+	}
+	return book
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, contentType := range []byte{ContentTypeJSON, ContentTypeMsgPack} {
+		want := sampleOrderBook()
+		data, err := Encode(contentType, want)
+		if err != nil {
+			t.Fatalf("encode 0x%02x: %v", contentType, err)
+		}
+		if data[0] != magic || data[1] != contentType || data[2] != SchemaVersion {
+			t.Fatalf("header 0x%02x: got % x", contentType, data[:headerSize])
+		}
+
+		var got models.OrderBook
+		if err := Decode(data, &got); err != nil {
+			t.Fatalf("decode 0x%02x: %v", contentType, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("round trip 0x%02x:\n got %+v\nwant %+v", contentType, got, want)
+		}
+	}
+}
+
+func TestDecodeLegacyJSON(t *testing.T) {
+	want := sampleOrderBook()
+	data, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got models.OrderBook
+	if err := Decode(data, &got); err != nil {
+		t.Fatalf("decode legacy payload: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("legacy payload:\n got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestUnknownContentType(t *testing.T) {
+	if _, err := Encode(0x7f, sampleOrderBook()); !errors.Is(err, ErrUnknownContentType) {
+		t.Fatalf("encode: got %v, want ErrUnknownContentType", err)
+	}
+	var book models.OrderBook
+	if err := Decode([]byte{magic, 0x7f, SchemaVersion, 0x80}, &book); !errors.Is(err, ErrUnknownContentType) {
+		t.Fatalf("decode: got %v, want ErrUnknownContentType", err)
+	}
+}
+
+func TestContentTypeFromString(t *testing.T) {
+	cases := map[string]byte{"json": ContentTypeJSON, "JSON": ContentTypeJSON, "msgpack": ContentTypeMsgPack, "": ContentTypeMsgPack}
+	for s, want := range cases {
+		if got := ContentTypeFromString(s); got != want {
+			t.Errorf("ContentTypeFromString(%q) = 0x%02x, want 0x%02x", s, got, want)
+		}
+	}
+}
+
+func benchmarkEncode(b *testing.B, contentType byte) {
+	book := sampleOrderBook()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Encode(contentType, book); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkDecode(b *testing.B, contentType byte) {
+	data, err := Encode(contentType, sampleOrderBook())
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		var book models.OrderBook
+		if err := Decode(data, &book); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodeJSON(b *testing.B)    { benchmarkEncode(b, ContentTypeJSON) }
+func BenchmarkEncodeMsgPack(b *testing.B) { benchmarkEncode(b, ContentTypeMsgPack) }
+func BenchmarkDecodeJSON(b *testing.B)    { benchmarkDecode(b, ContentTypeJSON) }
+func BenchmarkDecodeMsgPack(b *testing.B) { benchmarkDecode(b, ContentTypeMsgPack) }
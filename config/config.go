@@ -5,3 +5,39 @@ const (
 	ShortSMACount = 50
 	LongSMACount  = 200
 )
+
+// Precision is the number of decimal places the exchange accepts for a symbol's prices and quantities.
+type Precision struct {
+	Price    int
+	Quantity int
+}
+
+var DefaultPrecision = Precision{Price: 8, Quantity: 8}
+
+var SymbolPrecision = map[string]Precision{
+	"BTCUSDT": {Price: 2, Quantity: 5},
+	"ETHUSDT": {Price: 2, Quantity: 4},
+	"ETHBTC":  {Price: 5, Quantity: 4},
+	"SOLUSDT": {Price: 2, Quantity: 3},
+}
+
+func PrecisionFor(symbol string) Precision {
+	if p, ok := SymbolPrecision[symbol]; ok {
+		return p
+	}
+	return DefaultPrecision
+}
+
+// QuoteAssets are the quote currencies symbols are split on, longest first so FDUSD wins over USD.
+var QuoteAssets = []string{"FDUSD", "USDT", "USDC", "BUSD", "EUR", "USD", "BTC", "ETH", "BNB"}
+
+// SplitSymbol returns the base and quote assets of a symbol such as BTCUSDT; ok is false
+// when it doesn't end in a known quote asset.
+func SplitSymbol(symbol string) (base, quote string, ok bool) {
+	for _, q := range QuoteAssets {
+		if len(symbol) > len(q) && symbol[len(symbol)-len(q):] == q {
+			return symbol[:len(symbol)-len(q)], q, true
+		}
+	}
+	return "", "", false
+}
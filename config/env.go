@@ -0,0 +1,98 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func GetEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func GetEnvInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid integer for %s=%q, using %d", key, value, fallback)
+		return fallback
+	}
+	return parsed
+}
+
+func GetEnvFloat(key string, fallback float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("Invalid number for %s=%q, using %v", key, value, fallback)
+		return fallback
+	}
+	return parsed
+}
+
+func GetEnvDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid duration for %s=%q, using %s", key, value, fallback)
+		return fallback
+	}
+	return parsed
+}
+
+func GetEnvBool(key string, fallback bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid boolean for %s=%q, using %t", key, value, fallback)
+		return fallback
+	}
+	return parsed
+}
+
+// GetEnvMap parses comma separated key:value pairs, e.g. "binance:10,kraken:26".
+func GetEnvMap(key string) map[string]string {
+	values := make(map[string]string)
+	for _, pair := range strings.Split(os.Getenv(key), ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok {
+			if pair != "" {
+				log.Printf("Invalid key:value pair %q in %s, ignoring it", pair, key)
+			}
+			continue
+		}
+		values[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return values
+}
+
+// GetEnvFloatMap parses comma separated key:number pairs, skipping invalid numbers.
+func GetEnvFloatMap(key string) map[string]float64 {
+	values := make(map[string]float64)
+	for k, v := range GetEnvMap(key) {
+		parsed, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			log.Printf("Invalid number for %s in %s=%q, ignoring it", k, key, v)
+			continue
+		}
+		values[k] = parsed
+	}
+	return values
+}
@@ -0,0 +1,115 @@
+package costs
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/turgaysozen/algotrading/config"
+	"github.com/turgaysozen/algotrading/decimal"
+)
+
+// Trading costs are the same in live, paper and replay: every fill pays its exchange's
+// maker or taker rate on its notional, and slippage is measured against the mid price the
+// signal saw. Rates are in basis points; a negative maker rate is a rebate.
+
+// Liquidity of a fill: a maker fill rested on the book, a taker fill crossed the spread.
+const (
+	Maker = "maker"
+	Taker = "taker"
+)
+
+type Schedule struct {
+	MakerBps float64
+	TakerBps float64
+}
+
+// Bps returns the rate for liquidity.
+func (s Schedule) Bps(liquidity string) float64 {
+	if liquidity == Maker {
+		return s.MakerBps
+	}
+	return s.TakerBps
+}
+
+// DefaultSchedules are the exchanges' base tier spot rates; FEES overrides them for an
+// account's tier.
+var DefaultSchedules = map[string]Schedule{
+	"binance":  {MakerBps: 10, TakerBps: 10},
+	"coinbase": {MakerBps: 40, TakerBps: 60},
+	"kraken":   {MakerBps: 25, TakerBps: 40},
+	"bybit":    {MakerBps: 10, TakerBps: 10},
+}
+
+// DefaultSchedule applies to exchanges without a schedule.
+var DefaultSchedule = Schedule{MakerBps: 10, TakerBps: 10}
+
+var (
+	loadOnce  sync.Once
+	exchanges map[string]Schedule
+	// symbols holds per-symbol overrides keyed exchange.SYMBOL
+	symbols map[string]Schedule
+)
+
+// load reads FEES as exchange:maker/taker pairs, e.g. binance:9/10,coinbase:-1/5, and
+// FEES_SYMBOLS as exchange.SYMBOL:maker/taker overrides, e.g. binance.BTCUSDT:0/0.
+func load() {
+	exchanges = make(map[string]Schedule, len(DefaultSchedules))
+	for exchange, s := range DefaultSchedules {
+		exchanges[exchange] = s
+	}
+	for exchange, value := range config.GetEnvMap("FEES") {
+		if s, ok := parseSchedule("FEES", exchange, value); ok {
+			exchanges[exchange] = s
+		}
+	}
+
+	symbols = make(map[string]Schedule)
+	for key, value := range config.GetEnvMap("FEES_SYMBOLS") {
+		if s, ok := parseSchedule("FEES_SYMBOLS", key, value); ok {
+			symbols[key] = s
+		}
+	}
+}
+
+func parseSchedule(env, key, value string) (Schedule, bool) {
+	maker, taker, ok := strings.Cut(value, "/")
+	if ok {
+		makerBps, err1 := strconv.ParseFloat(strings.TrimSpace(maker), 64)
+		takerBps, err2 := strconv.ParseFloat(strings.TrimSpace(taker), 64)
+		if err1 == nil && err2 == nil {
+			return Schedule{MakerBps: makerBps, TakerBps: takerBps}, true
+		}
+	}
+	log.Printf("Invalid maker/taker rates %q for %s in %s, ignoring them", value, key, env)
+	return Schedule{}, false
+}
+
+// For returns the schedule of symbol on exchange: its override, else the exchange's.
+func For(exchange, symbol string) Schedule {
+	loadOnce.Do(load)
+	if s, ok := symbols[exchange+"."+symbol]; ok {
+		return s
+	}
+	if s, ok := exchanges[exchange]; ok {
+		return s
+	}
+	return DefaultSchedule
+}
+
+// Fee returns the fee on a fill of notional in the quote asset; negative is a rebate.
+func Fee(exchange, symbol, liquidity string, notional decimal.Decimal) decimal.Decimal {
+	rate := For(exchange, symbol).Bps(liquidity) / 10_000
+	return notional.Mul(decimal.FromFloat(rate))
+}
+
+// Slippage returns what filling quantity at price cost against the signal's mid, in the
+// quote asset: positive when a buy paid above mid or a sell received below it.
+func Slippage(side string, mid, price, quantity decimal.Decimal) decimal.Decimal {
+	slippage := price.Sub(mid).Mul(quantity)
+	if side == "sell" {
+		return slippage.Neg()
+	}
+	return slippage
+}
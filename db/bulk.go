@@ -0,0 +1,78 @@
+package db
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/turgaysozen/algotrading/monitoring/metrics"
+)
+
+const stagingTable = "import_staging"
+
+// BulkUpsert COPYs rows into a temporary copy of table and merges them with a single INSERT ... SELECT,
+// so imports get COPY throughput while onConflict keeps re-imports from duplicating rows.
+// It returns the number of rows that were inserted or updated.
+func BulkUpsert(table string, columns []string, onConflict string, rows [][]interface{}) (int64, error) {
+	tx, err := Database.Begin()
+	if err != nil {
+		metrics.RecordError("db_bulk_begin_error")
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(fmt.Sprintf(`CREATE TEMP TABLE %s (LIKE %s INCLUDING DEFAULTS) ON COMMIT DROP`,
+		stagingTable, pq.QuoteIdentifier(table)))
+	if err != nil {
+		log.Printf("Error creating staging table for %s: %v", table, err)
+		metrics.RecordError("db_bulk_staging_error")
+		return 0, err
+	}
+
+	stmt, err := tx.Prepare(pq.CopyIn(stagingTable, columns...))
+	if err != nil {
+		metrics.RecordError("db_bulk_copy_error")
+		return 0, err
+	}
+	for _, row := range rows {
+		if _, err := stmt.Exec(row...); err != nil {
+			stmt.Close()
+			log.Printf("Error copying rows into %s: %v", table, err)
+			metrics.RecordError("db_bulk_copy_error")
+			return 0, err
+		}
+	}
+	if _, err := stmt.Exec(); err != nil {
+		stmt.Close()
+		log.Printf("Error flushing COPY into %s: %v", table, err)
+		metrics.RecordError("db_bulk_copy_error")
+		return 0, err
+	}
+	if err := stmt.Close(); err != nil {
+		metrics.RecordError("db_bulk_copy_error")
+		return 0, err
+	}
+
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = pq.QuoteIdentifier(c)
+	}
+	columnList := strings.Join(quoted, ", ")
+
+	result, err := tx.Exec(fmt.Sprintf(`INSERT INTO %s (%s) SELECT %s FROM %s %s`,
+		pq.QuoteIdentifier(table), columnList, columnList, stagingTable, onConflict))
+	if err != nil {
+		log.Printf("Error merging staged rows into %s: %v", table, err)
+		metrics.RecordError("db_bulk_merge_error")
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		metrics.RecordError("db_bulk_commit_error")
+		return 0, err
+	}
+
+	affected, _ := result.RowsAffected()
+	return affected, nil
+}
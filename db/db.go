@@ -9,13 +9,11 @@ import (
 
 	_ "github.com/lib/pq"
 	"github.com/turgaysozen/algotrading/monitoring/metrics"
+	"github.com/turgaysozen/algotrading/retry"
 )
 
 var Database *sql.DB
 
-const maxRetries = 3
-const retryDelay = 5 * time.Second
-
 func InitializeDB() (*sql.DB, error) {
 	dbUser := os.Getenv("DB_USER")
 	dbPassword := os.Getenv("DB_PASSWORD")
@@ -26,32 +24,40 @@ func InitializeDB() (*sql.DB, error) {
 
 	connStr := "user=" + dbUser + " password=" + dbPassword + " dbname=" + dbName + " host=" + dbHost + " port=" + dbPort + " sslmode=" + dbSslMode
 
-	for i := 0; i < maxRetries; i++ {
-		db, err := sql.Open("postgres", connStr)
+	var db *sql.DB
+	err := retry.Do("postgres", retry.LoadPolicy("DB"), func() error {
+		var err error
+		db, err = sql.Open("postgres", connStr)
 		if err != nil {
-			log.Printf("Error opening database connection (attempt %d/%d): %v", i+1, maxRetries, err)
 			metrics.RecordError("db_open_connection_error")
-			time.Sleep(retryDelay)
-			continue
+			return err
 		}
 
-		db.SetMaxOpenConns(50)
-		db.SetMaxIdleConns(30)
-		db.SetConnMaxLifetime(30 * time.Minute)
-
 		if err := db.Ping(); err != nil {
-			log.Printf("Error pinging the database (attempt %d/%d): %v", i+1, maxRetries, err)
 			metrics.RecordError("db_ping_error")
-			time.Sleep(retryDelay)
-			continue
+			db.Close()
+			return err
 		}
-
-		log.Println("Database connection established successfully")
-		Database = db
-		return db, nil
+		return nil
+	})
+	if err != nil {
+		metrics.RecordError("db_connection_retry_failure")
+		return nil, err
 	}
 
-	log.Println("Failed to establish database connection after retries")
-	metrics.RecordError("db_connection_retry_failure")
-	return nil, errors.New("unable to connect to the database after multiple attempts")
+	db.SetMaxOpenConns(50)
+	db.SetMaxIdleConns(30)
+	db.SetConnMaxLifetime(30 * time.Minute)
+
+	log.Println("Database connection established successfully")
+	Database = db
+	return db, nil
+}
+
+// Ping reports whether the database is reachable, including before it was ever connected.
+func Ping() error {
+	if Database == nil {
+		return errors.New("database not connected")
+	}
+	return Database.Ping()
 }
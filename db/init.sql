@@ -13,46 +13,283 @@ CREATE EXTENSION IF NOT EXISTS timescaledb CASCADE;
 
 CREATE TABLE IF NOT EXISTS order_books (
     id SERIAL,
+    exchange TEXT NOT NULL DEFAULT 'binance',
     event_type TEXT,
     symbol TEXT,
     event_time BIGINT NOT NULL,
+    update_id BIGINT,
     best_bid FLOAT NOT NULL,
     best_ask FLOAT NOT NULL,
+    imbalance FLOAT,
+    microprice FLOAT,
+    spread_bps FLOAT,
+    bid_depth FLOAT,
+    ask_depth FLOAT,
+    book_pressure FLOAT,
     created_at TIMESTAMPTZ DEFAULT NOW(),
     updated_at TIMESTAMPTZ DEFAULT NOW(),
     PRIMARY KEY (id, event_time)
 );
 
-SELECT create_hypertable('order_books', 'event_time');
+SELECT create_hypertable('order_books', 'event_time', if_not_exists => TRUE);
+
+-- columns added since the table was first created, so existing databases pick them up
+ALTER TABLE order_books ADD COLUMN IF NOT EXISTS exchange TEXT NOT NULL DEFAULT 'binance';
+ALTER TABLE order_books ADD COLUMN IF NOT EXISTS update_id BIGINT;
+ALTER TABLE order_books ADD COLUMN IF NOT EXISTS imbalance FLOAT;
+ALTER TABLE order_books ADD COLUMN IF NOT EXISTS microprice FLOAT;
+ALTER TABLE order_books ADD COLUMN IF NOT EXISTS spread_bps FLOAT;
+ALTER TABLE order_books ADD COLUMN IF NOT EXISTS bid_depth FLOAT;
+ALTER TABLE order_books ADD COLUMN IF NOT EXISTS ask_depth FLOAT;
+ALTER TABLE order_books ADD COLUMN IF NOT EXISTS book_pressure FLOAT;
+
+-- lets imports and replays skip book updates they already stored; live rows without an update ID never conflict
+CREATE UNIQUE INDEX IF NOT EXISTS order_books_update_id_idx ON order_books (exchange, symbol, event_type, update_id, event_time);
 
 CREATE TABLE IF NOT EXISTS orders (
     id SERIAL,
+    symbol TEXT,
     price NUMERIC,
     quantity NUMERIC,
+    fee NUMERIC DEFAULT 0,
+    close_price NUMERIC,
+    close_fee NUMERIC DEFAULT 0,
+    pnl NUMERIC,
     status TEXT,
     order_type TEXT,
+    basket_id TEXT,
+    client_order_id TEXT,
+    strategy TEXT,
+    signal_price NUMERIC,
+    slippage NUMERIC DEFAULT 0,
+    close_slippage NUMERIC DEFAULT 0,
     created_at TIMESTAMPTZ DEFAULT NOW(),
     updated_at TIMESTAMPTZ DEFAULT NOW(),
     PRIMARY KEY (id, created_at)
 );
 
-SELECT create_hypertable('orders', 'created_at');
+SELECT create_hypertable('orders', 'created_at', if_not_exists => TRUE);
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS symbol TEXT;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS fee NUMERIC DEFAULT 0;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS close_price NUMERIC;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS close_fee NUMERIC DEFAULT 0;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS pnl NUMERIC;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS basket_id TEXT;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS client_order_id TEXT;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS strategy TEXT;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS signal_price NUMERIC;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS slippage NUMERIC DEFAULT 0;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS close_slippage NUMERIC DEFAULT 0;
+
+-- TimescaleDB needs the time column in unique keys; it is the signal's event time, which a
+-- resubmitted order shares with the original, so the key still rejects the duplicate
+CREATE UNIQUE INDEX IF NOT EXISTS orders_client_order_id ON orders (client_order_id, created_at);
 
 CREATE TABLE IF NOT EXISTS signals (
     id SERIAL,
+    symbol TEXT,
     type TEXT,
     timestamp TIMESTAMPTZ DEFAULT NOW(),
     price NUMERIC,
     short_sma NUMERIC,
     long_sma NUMERIC,
     reason TEXT,
+    basket_id TEXT,
     created_at TIMESTAMPTZ DEFAULT NOW(),
     updated_at TIMESTAMPTZ DEFAULT NOW(),
     PRIMARY KEY (id, timestamp)
 );
 
-SELECT create_hypertable('signals', 'timestamp');
+SELECT create_hypertable('signals', 'timestamp', if_not_exists => TRUE);
+
+ALTER TABLE signals ADD COLUMN IF NOT EXISTS symbol TEXT;
+ALTER TABLE signals ADD COLUMN IF NOT EXISTS basket_id TEXT;
+
+-- a signal is stored once however many instances or retries handle its tick; pairs legs are
+-- told apart by their basket, so they never collide with the SMA signal on the same tick
+CREATE UNIQUE INDEX IF NOT EXISTS signals_symbol_type_timestamp ON signals (symbol, type, COALESCE(basket_id, ''), timestamp);
+
+CREATE TABLE IF NOT EXISTS bars (
+    symbol TEXT NOT NULL,
+    timeframe TEXT NOT NULL,
+    open_time TIMESTAMPTZ NOT NULL,
+    close_time TIMESTAMPTZ NOT NULL,
+    open DOUBLE PRECISION NOT NULL,
+    high DOUBLE PRECISION NOT NULL,
+    low DOUBLE PRECISION NOT NULL,
+    close DOUBLE PRECISION NOT NULL,
+    volume DOUBLE PRECISION DEFAULT 0,
+    trade_count INTEGER DEFAULT 0,
+    tick_count INTEGER DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (symbol, timeframe, open_time)
+);
+
+SELECT create_hypertable('bars', 'open_time', if_not_exists => TRUE);
+
+CREATE TABLE IF NOT EXISTS trades (
+    exchange TEXT NOT NULL DEFAULT 'binance',
+    symbol TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    trade_id BIGINT NOT NULL,
+    price DOUBLE PRECISION NOT NULL,
+    quantity DOUBLE PRECISION NOT NULL,
+    is_buyer_maker BOOLEAN,
+    event_time BIGINT,
+    trade_time TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (exchange, symbol, event_type, trade_id, trade_time)
+);
+
+SELECT create_hypertable('trades', 'trade_time', if_not_exists => TRUE);
+
+ALTER TABLE trades ADD COLUMN IF NOT EXISTS exchange TEXT NOT NULL DEFAULT 'binance';
+
+CREATE TABLE IF NOT EXISTS arbitrage_opportunities (
+    id SERIAL,
+    symbol TEXT NOT NULL,
+    buy_exchange TEXT NOT NULL,
+    sell_exchange TEXT NOT NULL,
+    buy_price DOUBLE PRECISION NOT NULL,
+    sell_price DOUBLE PRECISION NOT NULL,
+    buy_time BIGINT,
+    sell_time BIGINT,
+    gross_bps DOUBLE PRECISION,
+    net_bps DOUBLE PRECISION,
+    max_net_bps DOUBLE PRECISION,
+    detected_at TIMESTAMPTZ NOT NULL,
+    opened_at TIMESTAMPTZ NOT NULL,
+    closed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (id, opened_at)
+);
+
+SELECT create_hypertable('arbitrage_opportunities', 'opened_at', if_not_exists => TRUE);
+
+CREATE TABLE IF NOT EXISTS parent_orders (
+    id SERIAL,
+    algo TEXT NOT NULL,
+    symbol TEXT NOT NULL,
+    side TEXT NOT NULL,
+    quantity NUMERIC NOT NULL,
+    filled_quantity NUMERIC DEFAULT 0,
+    avg_price NUMERIC DEFAULT 0,
+    arrival_price NUMERIC,
+    limit_price NUMERIC,
+    status TEXT NOT NULL,
+    child_count INTEGER DEFAULT 0,
+    fee NUMERIC DEFAULT 0,
+    client_order_id TEXT,
+    start_time TIMESTAMPTZ NOT NULL,
+    end_time TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (id, start_time)
+);
+
+SELECT create_hypertable('parent_orders', 'start_time', if_not_exists => TRUE);
+
+ALTER TABLE parent_orders ADD COLUMN IF NOT EXISTS fee NUMERIC DEFAULT 0;
+ALTER TABLE parent_orders ADD COLUMN IF NOT EXISTS client_order_id TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS parent_orders_client_order_id ON parent_orders (client_order_id, start_time);
+
+CREATE TABLE IF NOT EXISTS child_orders (
+    parent_id INTEGER NOT NULL,
+    child_id BIGINT NOT NULL,
+    symbol TEXT NOT NULL,
+    side TEXT NOT NULL,
+    price NUMERIC NOT NULL,
+    quantity NUMERIC NOT NULL,
+    filled_quantity NUMERIC DEFAULT 0,
+    fee NUMERIC DEFAULT 0,
+    status TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (parent_id, child_id, created_at)
+);
+
+SELECT create_hypertable('child_orders', 'created_at', if_not_exists => TRUE);
+
+ALTER TABLE child_orders ADD COLUMN IF NOT EXISTS fee NUMERIC DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS limit_orders (
+    id SERIAL,
+    symbol TEXT NOT NULL,
+    side TEXT NOT NULL,
+    price NUMERIC NOT NULL,
+    quantity NUMERIC NOT NULL,
+    filled_quantity NUMERIC DEFAULT 0,
+    avg_price NUMERIC DEFAULT 0,
+    time_in_force TEXT NOT NULL,
+    passive BOOLEAN DEFAULT FALSE,
+    status TEXT NOT NULL,
+    fee NUMERIC DEFAULT 0,
+    client_order_id TEXT,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (id, created_at)
+);
+
+SELECT create_hypertable('limit_orders', 'created_at', if_not_exists => TRUE);
+
+ALTER TABLE limit_orders ADD COLUMN IF NOT EXISTS fee NUMERIC DEFAULT 0;
+ALTER TABLE limit_orders ADD COLUMN IF NOT EXISTS client_order_id TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS limit_orders_client_order_id ON limit_orders (client_order_id, created_at);
+
+-- every step of a limit order's lifecycle: placed, fill, replaced and its final status
+CREATE TABLE IF NOT EXISTS limit_order_events (
+    order_id INTEGER NOT NULL,
+    event TEXT NOT NULL,
+    status TEXT NOT NULL,
+    price NUMERIC NOT NULL,
+    quantity NUMERIC NOT NULL,
+    filled_quantity NUMERIC NOT NULL,
+    event_time TIMESTAMPTZ NOT NULL
+);
+
+SELECT create_hypertable('limit_order_events', 'event_time', if_not_exists => TRUE);
+
+-- per-asset balances in paper or live mode, written on every paper fill and reconciliation
+CREATE TABLE IF NOT EXISTS balances (
+    mode TEXT NOT NULL,
+    asset TEXT NOT NULL,
+    free NUMERIC NOT NULL,
+    locked NUMERIC NOT NULL,
+    time TIMESTAMPTZ NOT NULL
+);
+
+SELECT create_hypertable('balances', 'time', if_not_exists => TRUE);
+
+-- differences between the balances the orders table implies and the account's
+CREATE TABLE IF NOT EXISTS balance_drifts (
+    mode TEXT NOT NULL,
+    asset TEXT NOT NULL,
+    expected NUMERIC NOT NULL,
+    actual NUMERIC NOT NULL,
+    drift NUMERIC NOT NULL,
+    corrected BOOLEAN DEFAULT FALSE,
+    detected_at TIMESTAMPTZ NOT NULL
+);
+
+SELECT create_hypertable('balance_drifts', 'detected_at', if_not_exists => TRUE);
+
+-- every asset's value in the reporting currency, with the portfolio's totals, per snapshot
+CREATE TABLE IF NOT EXISTS portfolio_snapshots (
+    currency TEXT NOT NULL,
+    asset TEXT NOT NULL,
+    quantity NUMERIC NOT NULL,
+    price NUMERIC NOT NULL,
+    value NUMERIC NOT NULL,
+    weight DOUBLE PRECISION NOT NULL,
+    equity NUMERIC NOT NULL,
+    gross_exposure NUMERIC NOT NULL,
+    net_exposure NUMERIC NOT NULL,
+    time TIMESTAMPTZ NOT NULL
+);
 
+SELECT create_hypertable('portfolio_snapshots', 'time', if_not_exists => TRUE);
 
 -- print all created tables to make sure they are created
 SELECT * FROM timescaledb_information.hypertables
\ No newline at end of file
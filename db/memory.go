@@ -0,0 +1,468 @@
+package db
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/turgaysozen/algotrading/decimal"
+	"github.com/turgaysozen/algotrading/models"
+)
+
+// Memory is a Store that keeps everything in memory and follows the same rules as the tables:
+// duplicate client order IDs, signals and trades are ignored, closing a closed order fails and a bar
+// is replaced by a later save of the same bar.
+type Memory struct {
+	mu sync.Mutex
+
+	orderBooks int64
+	trades     map[tradeKey]bool
+	signals    []models.Signal
+	orders     []memoryOrder
+	bars       map[barKey]models.Bar
+	arbitrage  []models.ArbitrageOpportunity
+	parents    []models.ParentOrder
+	children   []models.ChildOrder
+
+	limitOrders []models.LimitOrder
+	limitEvents int
+	balances    map[string]map[string]models.Balance
+	drifts      []models.BalanceDrift
+	snapshots   []models.Portfolio
+}
+
+type memoryOrder struct {
+	order    models.Order
+	closedAt int64
+}
+
+type tradeKey struct {
+	exchange, symbol, eventType string
+	tradeID, tradeTime          int64
+}
+
+type barKey struct {
+	symbol, timeframe string
+	openTime          int64
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		trades:   make(map[tradeKey]bool),
+		bars:     make(map[barKey]models.Bar),
+		balances: make(map[string]map[string]models.Balance),
+	}
+}
+
+// Signals returns every stored signal in the order it was saved.
+func (m *Memory) Signals() []models.Signal {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]models.Signal(nil), m.signals...)
+}
+
+// Orders returns every stored order in the order it was saved, with its close if it closed.
+func (m *Memory) Orders() []models.Order {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	orders := make([]models.Order, len(m.orders))
+	for i, o := range m.orders {
+		orders[i] = o.order
+	}
+	return orders
+}
+
+func (m *Memory) SaveOrderBook(exchange, eventType, symbol string, eventTime int64, bestBid, bestAsk float64, micro models.Microstructure) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.orderBooks++
+	return m.orderBooks, nil
+}
+
+func (m *Memory) SaveTrade(trade models.Trade) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.trades[tradeKey{trade.Exchange, trade.Symbol, trade.EventType, trade.TradeID, trade.TradeTime}] = true
+	return nil
+}
+
+func (m *Memory) SaveSignal(signal models.Signal) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.saveSignal(signal) {
+		return ErrDuplicateSignal
+	}
+	return nil
+}
+
+func (m *Memory) saveSignal(signal models.Signal) bool {
+	for _, s := range m.signals {
+		if s.Symbol == signal.Symbol && s.Type == signal.Type && s.BasketID == signal.BasketID && s.EventTime == signal.EventTime {
+			return false
+		}
+	}
+	m.signals = append(m.signals, signal)
+	return true
+}
+
+func (m *Memory) SaveOrder(order models.Order) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, err := m.saveOrder(order)
+	return err
+}
+
+func (m *Memory) saveOrder(order models.Order) (int, error) {
+	if order.ClientOrderID != "" {
+		for _, o := range m.orders {
+			if o.order.ClientOrderID == order.ClientOrderID && o.order.EventTime == order.EventTime {
+				return 0, ErrDuplicateOrder
+			}
+		}
+	}
+	order.ID = len(m.orders) + 1
+	m.orders = append(m.orders, memoryOrder{order: order})
+	return order.ID, nil
+}
+
+func (m *Memory) GetLastOpenOrder(symbol string) (*models.Order, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var last *models.Order
+	for i := range m.orders {
+		o := m.orders[i].order
+		if o.Status == "open" && o.BasketID == "" && o.Symbol == symbol && (last == nil || o.EventTime >= last.EventTime) {
+			last = &o
+		}
+	}
+	return last, nil
+}
+
+func (m *Memory) GetOpenBasketOrders() ([]models.Order, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var orders []models.Order
+	for _, o := range m.orders {
+		if o.order.Status == "open" && o.order.BasketID != "" {
+			orders = append(orders, o.order)
+		}
+	}
+	sort.SliceStable(orders, func(i, j int) bool { return orders[i].EventTime < orders[j].EventTime })
+	return orders, nil
+}
+
+func (m *Memory) CloseOrder(orderID int, closePrice, closeFee, closeSlippage, pnl decimal.Decimal, closeTime int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.closeOrder(orderID, closePrice, closeFee, closeSlippage, pnl, closeTime)
+}
+
+func (m *Memory) closeOrder(orderID int, closePrice, closeFee, closeSlippage, pnl decimal.Decimal, closeTime int64) error {
+	if orderID < 1 || orderID > len(m.orders) || m.orders[orderID-1].order.Status != "open" {
+		return ErrOrderNotOpen
+	}
+	o := &m.orders[orderID-1]
+	o.order.Status = "closed"
+	o.order.ClosePrice, o.order.CloseFee, o.order.CloseSlippage, o.order.PnL = closePrice, closeFee, closeSlippage, pnl
+	o.closedAt = closeTime
+	return nil
+}
+
+func (m *Memory) SaveBasket(signals []models.Signal, orders []models.Order) ([]int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	saved := len(m.orders)
+	ids := make([]int, 0, len(orders))
+	for _, order := range orders {
+		id, err := m.saveOrder(order)
+		if err != nil {
+			m.orders = m.orders[:saved]
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	for _, signal := range signals {
+		m.saveSignal(signal)
+	}
+	return ids, nil
+}
+
+func (m *Memory) CloseBasket(signals []models.Signal, orders []models.Order, closeTime int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, order := range orders {
+		if order.ID < 1 || order.ID > len(m.orders) || m.orders[order.ID-1].order.Status != "open" {
+			return ErrOrderNotOpen
+		}
+	}
+	for _, order := range orders {
+		m.closeOrder(order.ID, order.ClosePrice, order.CloseFee, order.CloseSlippage, order.PnL, closeTime)
+	}
+	for _, signal := range signals {
+		m.saveSignal(signal)
+	}
+	return nil
+}
+
+func (m *Memory) GetFillsSince(since time.Time) ([]models.Fill, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	from := since.UnixMilli()
+	var fills []models.Fill
+	for _, o := range m.orders {
+		if o.order.EventTime >= from {
+			fills = append(fills, models.Fill{Symbol: o.order.Symbol, Side: o.order.OrderType, Quantity: o.order.Quantity,
+				Price: o.order.Price, Fee: o.order.Fee, EventTime: o.order.EventTime})
+		}
+		if o.order.Status == "closed" && o.closedAt >= from {
+			side := "buy"
+			if o.order.OrderType == "buy" {
+				side = "sell"
+			}
+			fills = append(fills, models.Fill{Symbol: o.order.Symbol, Side: side, Quantity: o.order.Quantity,
+				Price: o.order.ClosePrice, Fee: o.order.CloseFee, EventTime: o.closedAt})
+		}
+	}
+	sort.SliceStable(fills, func(i, j int) bool { return fills[i].EventTime < fills[j].EventTime })
+	return fills, nil
+}
+
+func (m *Memory) GetStrategyPnL() ([]models.StrategyPnL, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	byStrategy := make(map[string]*models.StrategyPnL)
+	for _, o := range m.orders {
+		if o.order.Status != "closed" {
+			continue
+		}
+		strategy := o.order.Strategy
+		if strategy == "" {
+			strategy = "unknown"
+		}
+		r, ok := byStrategy[strategy]
+		if !ok {
+			r = &models.StrategyPnL{Strategy: strategy}
+			byStrategy[strategy] = r
+		}
+		fees := o.order.Fee.Add(o.order.CloseFee)
+		r.Orders++
+		r.GrossPnL = r.GrossPnL.Add(o.order.PnL.Add(fees))
+		r.Fees = r.Fees.Add(fees)
+		r.Slippage = r.Slippage.Add(o.order.Slippage).Add(o.order.CloseSlippage)
+		r.NetPnL = r.NetPnL.Add(o.order.PnL)
+	}
+
+	report := make([]models.StrategyPnL, 0, len(byStrategy))
+	for _, r := range byStrategy {
+		report = append(report, *r)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Strategy < report[j].Strategy })
+	return report, nil
+}
+
+func (m *Memory) SaveBars(bars []models.Bar) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, bar := range bars {
+		m.bars[barKey{bar.Symbol, bar.Timeframe, bar.OpenTime}] = bar
+	}
+	return nil
+}
+
+func (m *Memory) AddBarTrade(symbol, timeframe string, openTime int64, qty float64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := barKey{symbol, timeframe, openTime}
+	bar, ok := m.bars[key]
+	if !ok {
+		return false, nil
+	}
+	bar.Volume += qty
+	bar.TradeCount++
+	m.bars[key] = bar
+	return true, nil
+}
+
+func (m *Memory) GetBarSymbols(timeframe string, since time.Time) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	seen := make(map[string]bool)
+	var symbols []string
+	for key := range m.bars {
+		if key.timeframe == timeframe && key.openTime >= since.UnixMilli() && !seen[key.symbol] {
+			seen[key.symbol] = true
+			symbols = append(symbols, key.symbol)
+		}
+	}
+	sort.Strings(symbols)
+	return symbols, nil
+}
+
+func (m *Memory) GetBarsSince(symbol, timeframe string, since time.Time) ([]models.Bar, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var bars []models.Bar
+	for key, bar := range m.bars {
+		if key.symbol == symbol && key.timeframe == timeframe && key.openTime >= since.UnixMilli() {
+			bars = append(bars, bar)
+		}
+	}
+	sort.Slice(bars, func(i, j int) bool { return bars[i].OpenTime < bars[j].OpenTime })
+	return bars, nil
+}
+
+func (m *Memory) SaveArbitrageOpportunity(o models.ArbitrageOpportunity) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	o.ID = int64(len(m.arbitrage) + 1)
+	m.arbitrage = append(m.arbitrage, o)
+	return o.ID, nil
+}
+
+func (m *Memory) CloseArbitrageOpportunity(o models.ArbitrageOpportunity) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if o.ID >= 1 && o.ID <= int64(len(m.arbitrage)) {
+		stored := &m.arbitrage[o.ID-1]
+		stored.MaxNetBps, stored.ClosedAt = o.MaxNetBps, o.ClosedAt
+	}
+	return nil
+}
+
+func (m *Memory) SaveParentOrder(p models.ParentOrder) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if p.ClientOrderID != "" {
+		for _, stored := range m.parents {
+			if stored.ClientOrderID == p.ClientOrderID && stored.StartTime == p.StartTime {
+				return 0, ErrDuplicateOrder
+			}
+		}
+	}
+	p.ID = int64(len(m.parents) + 1)
+	m.parents = append(m.parents, p)
+	return p.ID, nil
+}
+
+func (m *Memory) UpdateParentOrder(p models.ParentOrder) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if p.ID >= 1 && p.ID <= int64(len(m.parents)) {
+		stored := &m.parents[p.ID-1]
+		stored.FilledQty, stored.AvgPrice, stored.Status = p.FilledQty, p.AvgPrice, p.Status
+		stored.ChildCount, stored.Fee, stored.UpdatedAt = p.ChildCount, p.Fee, p.UpdatedAt
+	}
+	return nil
+}
+
+func (m *Memory) SaveChildOrder(c models.ChildOrder) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, stored := range m.children {
+		if stored.ParentID == c.ParentID && stored.ID == c.ID && stored.CreatedAt == c.CreatedAt {
+			m.children[i] = c
+			return nil
+		}
+	}
+	m.children = append(m.children, c)
+	return nil
+}
+
+func (m *Memory) SaveLimitOrder(o models.LimitOrder) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if o.ClientOrderID != "" {
+		for _, stored := range m.limitOrders {
+			if stored.ClientOrderID == o.ClientOrderID && stored.CreatedAt == o.CreatedAt {
+				return 0, ErrDuplicateOrder
+			}
+		}
+	}
+	o.ID = int64(len(m.limitOrders) + 1)
+	m.limitOrders = append(m.limitOrders, o)
+	return o.ID, nil
+}
+
+func (m *Memory) UpdateLimitOrder(o models.LimitOrder, event string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if o.ID >= 1 && o.ID <= int64(len(m.limitOrders)) {
+		stored := &m.limitOrders[o.ID-1]
+		stored.Price, stored.Quantity, stored.FilledQty, stored.AvgPrice = o.Price, o.Quantity, o.FilledQty, o.AvgPrice
+		stored.Fee, stored.Status, stored.UpdatedAt = o.Fee, o.Status, o.UpdatedAt
+	}
+	m.limitEvents++
+	return nil
+}
+
+func (m *Memory) GetWorkingParentOrders() ([]models.ParentOrder, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var parents []models.ParentOrder
+	for _, p := range m.parents {
+		if p.Status == "working" {
+			parents = append(parents, p)
+		}
+	}
+	return parents, nil
+}
+
+func (m *Memory) SaveLimitOrderEvent(o models.LimitOrder, event string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.limitEvents++
+	return nil
+}
+
+func (m *Memory) GetWorkingLimitOrders() ([]models.LimitOrder, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var orders []models.LimitOrder
+	for _, o := range m.limitOrders {
+		if o.Status == "open" || o.Status == "partially_filled" {
+			orders = append(orders, o)
+		}
+	}
+	return orders, nil
+}
+
+func (m *Memory) SaveBalances(mode string, balances []models.Balance) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	latest, ok := m.balances[mode]
+	if !ok {
+		latest = make(map[string]models.Balance)
+		m.balances[mode] = latest
+	}
+	for _, b := range balances {
+		if stored, ok := latest[b.Asset]; !ok || b.UpdatedAt >= stored.UpdatedAt {
+			latest[b.Asset] = b
+		}
+	}
+	return nil
+}
+
+func (m *Memory) LoadBalances(mode string) ([]models.Balance, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var balances []models.Balance
+	for _, b := range m.balances[mode] {
+		balances = append(balances, b)
+	}
+	sort.Slice(balances, func(i, j int) bool { return balances[i].Asset < balances[j].Asset })
+	return balances, nil
+}
+
+func (m *Memory) SaveBalanceDrift(mode string, d models.BalanceDrift) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.drifts = append(m.drifts, d)
+	return nil
+}
+
+func (m *Memory) SavePortfolioSnapshot(portfolio models.Portfolio) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.snapshots = append(m.snapshots, portfolio)
+	return nil
+}
@@ -2,27 +2,42 @@ package db
 
 import (
 	"database/sql"
+	"errors"
 	"log"
+	"time"
 
+	"github.com/turgaysozen/algotrading/decimal"
 	"github.com/turgaysozen/algotrading/models"
 	"github.com/turgaysozen/algotrading/monitoring/metrics"
 )
 
-func SaveOrderBook(eventType, symbol string, eventTime int64, bestBid, bestAsk float64) (int64, error) {
+// postgres is the live Store, on the Database connection.
+type postgres struct{}
+
+func (postgres) SaveOrderBook(exchange, eventType, symbol string, eventTime int64, bestBid, bestAsk float64, micro models.Microstructure) (int64, error) {
 	query := `
-        INSERT INTO order_books (event_type, symbol, event_time, best_bid, best_ask)
-        VALUES ($1, $2, $3, $4, $5)
+        INSERT INTO order_books (exchange, event_type, symbol, event_time, best_bid, best_ask,
+            imbalance, microprice, spread_bps, bid_depth, ask_depth, book_pressure)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
         ON CONFLICT (id, event_time)
         DO UPDATE SET 
+            exchange = EXCLUDED.exchange,
             event_type = EXCLUDED.event_type,
             symbol = EXCLUDED.symbol,
             best_bid = EXCLUDED.best_bid,
-            best_ask = EXCLUDED.best_ask
+            best_ask = EXCLUDED.best_ask,
+            imbalance = EXCLUDED.imbalance,
+            microprice = EXCLUDED.microprice,
+            spread_bps = EXCLUDED.spread_bps,
+            bid_depth = EXCLUDED.bid_depth,
+            ask_depth = EXCLUDED.ask_depth,
+            book_pressure = EXCLUDED.book_pressure
         RETURNING id
     `
 
 	var orderBookID int64
-	err := Database.QueryRow(query, eventType, symbol, eventTime, bestBid, bestAsk).Scan(&orderBookID)
+	err := Database.QueryRow(query, exchange, eventType, symbol, eventTime, bestBid, bestAsk,
+		micro.Imbalance, micro.Microprice, micro.SpreadBps, micro.BidDepth, micro.AskDepth, micro.Pressure).Scan(&orderBookID)
 	if err != nil {
 		log.Printf("Error saving order book: %v", err)
 		metrics.RecordError("db_save_order_book_error")
@@ -32,17 +47,37 @@ func SaveOrderBook(eventType, symbol string, eventTime int64, bestBid, bestAsk f
 	return orderBookID, nil
 }
 
-func SaveOrder(order models.Order) error {
-	query := `
-		INSERT INTO orders (price, quantity, status, order_type)
-		VALUES ($1, $2, $3, $4)
-		ON CONFLICT (id, created_at) DO UPDATE SET
-			price = EXCLUDED.price,
-			quantity = EXCLUDED.quantity,
-			status = EXCLUDED.status,
-			order_type = EXCLUDED.order_type
-	`
-	_, err := Database.Exec(query, order.Price, order.Quantity, order.Status, order.OrderType)
+var (
+	// ErrDuplicateOrder is returned when an order with the same client order ID is already stored.
+	ErrDuplicateOrder = errors.New("duplicate client order ID")
+	// ErrOrderNotOpen is returned when closing an order someone else already closed.
+	ErrOrderNotOpen = errors.New("order is not open")
+	// ErrDuplicateSignal is returned when the same signal, at the same event time, is already stored.
+	ErrDuplicateSignal = errors.New("duplicate signal")
+)
+
+// An order whose client order ID is already stored inserts nothing and returns no ID.
+const insertOrderQuery = `
+	INSERT INTO orders (symbol, price, quantity, fee, status, order_type, basket_id, client_order_id, strategy,
+		signal_price, slippage, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8, ''), NULLIF($9, ''), $10, $11, $12, $12)
+	ON CONFLICT (client_order_id, created_at) DO NOTHING
+	RETURNING id
+`
+
+func insertOrderArgs(order models.Order) []interface{} {
+	return []interface{}{order.Symbol, order.Price, order.Quantity, order.Fee, order.Status, order.OrderType,
+		order.BasketID, order.ClientOrderID, order.Strategy, order.SignalPrice, order.Slippage, time.UnixMilli(order.EventTime)}
+}
+
+// SaveOrder stores order, returning ErrDuplicateOrder if its client order ID is already
+// stored, so a retried save never creates a second order.
+func (postgres) SaveOrder(order models.Order) error {
+	var id int
+	err := Database.QueryRow(insertOrderQuery, insertOrderArgs(order)...).Scan(&id)
+	if err == sql.ErrNoRows {
+		return ErrDuplicateOrder
+	}
 	if err != nil {
 		log.Printf("Error saving order: %v", err)
 		metrics.RecordError("db_save_order_error")
@@ -51,17 +86,21 @@ func SaveOrder(order models.Order) error {
 	return nil
 }
 
-func GetLastOpenOrder() (*models.Order, error) {
+// GetLastOpenOrder returns symbol's newest open order that isn't a basket leg; baskets are closed as a whole.
+func (postgres) GetLastOpenOrder(symbol string) (*models.Order, error) {
 	var order models.Order
+	var createdAt time.Time
 	query := `
-		SELECT id, price, quantity, status, order_type
+		SELECT id, COALESCE(symbol, ''), price, quantity, fee, status, order_type, COALESCE(strategy, ''),
+			COALESCE(client_order_id, ''), COALESCE(signal_price, price), slippage, created_at
 		FROM orders
-		WHERE status = 'open'
+		WHERE status = 'open' AND basket_id IS NULL AND symbol = $1
 		ORDER BY created_at DESC
 		LIMIT 1
 	`
 
-	err := Database.QueryRow(query).Scan(&order.ID, &order.Price, &order.Quantity, &order.Status, &order.OrderType)
+	err := Database.QueryRow(query, symbol).Scan(&order.ID, &order.Symbol, &order.Price, &order.Quantity, &order.Fee, &order.Status, &order.OrderType,
+		&order.Strategy, &order.ClientOrderID, &order.SignalPrice, &order.Slippage, &createdAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -71,42 +110,695 @@ func GetLastOpenOrder() (*models.Order, error) {
 		return nil, err
 	}
 
+	order.EventTime = createdAt.UnixMilli()
 	return &order, nil
 }
 
-func CloseOrder(orderID int) error {
+// GetOpenBasketOrders returns the legs of every open basket, oldest first.
+func (postgres) GetOpenBasketOrders() ([]models.Order, error) {
 	query := `
-		UPDATE orders
-		SET status = 'closed', updated_at = NOW()
-		WHERE id = $1
+		SELECT id, COALESCE(symbol, ''), price, quantity, fee, status, order_type, basket_id, COALESCE(strategy, ''),
+			COALESCE(client_order_id, ''), COALESCE(signal_price, price), slippage, created_at
+		FROM orders
+		WHERE status = 'open' AND basket_id IS NOT NULL
+		ORDER BY created_at, id
 	`
-	_, err := Database.Exec(query, orderID)
+
+	rows, err := Database.Query(query)
+	if err != nil {
+		log.Printf("Error retrieving open basket orders: %v", err)
+		metrics.RecordError("db_get_open_basket_orders_error")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []models.Order
+	for rows.Next() {
+		var order models.Order
+		var createdAt time.Time
+		err := rows.Scan(&order.ID, &order.Symbol, &order.Price, &order.Quantity, &order.Fee, &order.Status, &order.OrderType,
+			&order.BasketID, &order.Strategy, &order.ClientOrderID, &order.SignalPrice, &order.Slippage, &createdAt)
+		if err != nil {
+			log.Printf("Error scanning open basket order: %v", err)
+			metrics.RecordError("db_get_open_basket_orders_error")
+			return nil, err
+		}
+		order.EventTime = createdAt.UnixMilli()
+		orders = append(orders, order)
+	}
+	return orders, rows.Err()
+}
+
+// closeOrderQuery only closes an open order, so closing twice is a no-op.
+const closeOrderQuery = `
+	UPDATE orders
+	SET status = 'closed', close_price = $2, close_fee = $3, close_slippage = $4, pnl = $5, updated_at = $6
+	WHERE id = $1 AND status = 'open'
+`
+
+// CloseOrder stamps updated_at with closeTime, the exchange event time in milliseconds that closed the order.
+// It returns ErrOrderNotOpen if the order was already closed, e.g. by a retry or another instance.
+func (postgres) CloseOrder(orderID int, closePrice, closeFee, closeSlippage, pnl decimal.Decimal, closeTime int64) error {
+	result, err := Database.Exec(closeOrderQuery, orderID, closePrice, closeFee, closeSlippage, pnl, time.UnixMilli(closeTime))
 	if err != nil {
 		log.Printf("Error closing order with ID %d: %v", orderID, err)
 		metrics.RecordError("db_close_order_error")
 		return err
 	}
+	if closed, err := result.RowsAffected(); err == nil && closed == 0 {
+		return ErrOrderNotOpen
+	}
 
 	log.Printf("Order with ID %d has been closed.\n", orderID)
 	return nil
 }
 
-func SaveSignal(signal models.Signal) error {
-	query := `
-		INSERT INTO signals (type, price, short_sma, long_sma, reason)
-		VALUES ($1, $2, $3, $4, $5)
-		ON CONFLICT (id, timestamp) DO UPDATE SET
-			type = EXCLUDED.type,
-			price = EXCLUDED.price,
-			short_sma = EXCLUDED.short_sma,
-			long_sma = EXCLUDED.long_sma,
-			reason = EXCLUDED.reason
-	`
-	_, err := Database.Exec(query, signal.Type, signal.Price, signal.ShortSMA, signal.LongSMA, signal.Reason)
+// A signal already stored for the same symbol, type, basket and event time inserts nothing.
+const insertSignalQuery = `
+	INSERT INTO signals (symbol, type, price, short_sma, long_sma, reason, basket_id, timestamp)
+	VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8)
+	ON CONFLICT DO NOTHING
+`
+
+// SaveSignal stores signal, returning ErrDuplicateSignal if it is already stored, so a signal
+// handled twice, by a retry or a second instance, is only acted on once.
+func (postgres) SaveSignal(signal models.Signal) error {
+	result, err := Database.Exec(insertSignalQuery, signal.Symbol, signal.Type, signal.Price, signal.ShortSMA, signal.LongSMA,
+		signal.Reason, signal.BasketID, time.UnixMilli(signal.EventTime))
 	if err != nil {
 		log.Printf("Error saving signal: %v", err)
 		metrics.RecordError("db_save_signal_error")
 		return err
 	}
+	if saved, err := result.RowsAffected(); err == nil && saved == 0 {
+		return ErrDuplicateSignal
+	}
+	return nil
+}
+
+// SaveBasket stores a basket's signals and orders in one transaction, so either every leg
+// is recorded or none is. It returns the order IDs in the order given, or ErrDuplicateOrder
+// if a leg's client order ID is already stored.
+func (postgres) SaveBasket(signals []models.Signal, orders []models.Order) ([]int, error) {
+	tx, err := Database.Begin()
+	if err != nil {
+		metrics.RecordError("db_save_basket_error")
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	for _, signal := range signals {
+		_, err := tx.Exec(insertSignalQuery, signal.Symbol, signal.Type, signal.Price, signal.ShortSMA, signal.LongSMA, signal.Reason,
+			signal.BasketID, time.UnixMilli(signal.EventTime))
+		if err != nil {
+			log.Printf("Error saving basket signal: %v", err)
+			metrics.RecordError("db_save_basket_error")
+			return nil, err
+		}
+	}
+
+	ids := make([]int, len(orders))
+	for i, order := range orders {
+		err := tx.QueryRow(insertOrderQuery, insertOrderArgs(order)...).Scan(&ids[i])
+		if err == sql.ErrNoRows {
+			return nil, ErrDuplicateOrder
+		}
+		if err != nil {
+			log.Printf("Error saving basket order: %v", err)
+			metrics.RecordError("db_save_basket_error")
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		metrics.RecordError("db_save_basket_error")
+		return nil, err
+	}
+	return ids, nil
+}
+
+// CloseBasket saves the exit signals and closes every leg with its ClosePrice, CloseFee, CloseSlippage and PnL in
+// one transaction.
+// It returns ErrOrderNotOpen, saving nothing, if a leg was already closed.
+func (postgres) CloseBasket(signals []models.Signal, orders []models.Order, closeTime int64) error {
+	tx, err := Database.Begin()
+	if err != nil {
+		metrics.RecordError("db_close_basket_error")
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, signal := range signals {
+		_, err := tx.Exec(insertSignalQuery, signal.Symbol, signal.Type, signal.Price, signal.ShortSMA, signal.LongSMA, signal.Reason,
+			signal.BasketID, time.UnixMilli(signal.EventTime))
+		if err != nil {
+			log.Printf("Error saving basket signal: %v", err)
+			metrics.RecordError("db_close_basket_error")
+			return err
+		}
+	}
+
+	for _, order := range orders {
+		result, err := tx.Exec(closeOrderQuery, order.ID, order.ClosePrice, order.CloseFee, order.CloseSlippage, order.PnL,
+			time.UnixMilli(closeTime))
+		if err != nil {
+			log.Printf("Error closing basket order with ID %d: %v", order.ID, err)
+			metrics.RecordError("db_close_basket_error")
+			return err
+		}
+		if closed, err := result.RowsAffected(); err == nil && closed == 0 {
+			return ErrOrderNotOpen
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		metrics.RecordError("db_close_basket_error")
+		return err
+	}
+	return nil
+}
+
+const saveBarQuery = `
+	INSERT INTO bars (symbol, timeframe, open_time, close_time, open, high, low, close, volume, trade_count, tick_count)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	ON CONFLICT (symbol, timeframe, open_time) DO UPDATE SET
+		close_time = EXCLUDED.close_time,
+		open = EXCLUDED.open,
+		high = EXCLUDED.high,
+		low = EXCLUDED.low,
+		close = EXCLUDED.close,
+		volume = EXCLUDED.volume,
+		trade_count = EXCLUDED.trade_count,
+		tick_count = EXCLUDED.tick_count
+`
+
+// SaveBars saves bars in one transaction, so a long run of gap bars costs one round of commits.
+func (postgres) SaveBars(bars []models.Bar) error {
+	tx, err := Database.Begin()
+	if err != nil {
+		metrics.RecordError("db_save_bar_error")
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, bar := range bars {
+		_, err := tx.Exec(saveBarQuery, bar.Symbol, bar.Timeframe, time.UnixMilli(bar.OpenTime), time.UnixMilli(bar.CloseTime),
+			bar.Open, bar.High, bar.Low, bar.Close, bar.Volume, bar.TradeCount, bar.TickCount)
+		if err != nil {
+			log.Printf("Error saving bar: %v", err)
+			metrics.RecordError("db_save_bar_error")
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		metrics.RecordError("db_save_bar_error")
+		return err
+	}
+	return nil
+}
+
+// AddBarTrade adds a trade that arrived after its bar closed to the stored bar. It returns false
+// when no bar is stored for openTime, e.g. one older than the gap fill kept.
+func (postgres) AddBarTrade(symbol, timeframe string, openTime int64, qty float64) (bool, error) {
+	query := `
+		UPDATE bars SET volume = volume + $4, trade_count = trade_count + 1
+		WHERE symbol = $1 AND timeframe = $2 AND open_time = $3
+	`
+	result, err := Database.Exec(query, symbol, timeframe, time.UnixMilli(openTime), qty)
+	if err != nil {
+		log.Printf("Error adding late trade to bar: %v", err)
+		metrics.RecordError("db_add_bar_trade_error")
+		return false, err
+	}
+	updated, err := result.RowsAffected()
+	return updated > 0, err
+}
+
+// GetBarSymbols returns the symbols with timeframe bars opened since since, in order.
+func (postgres) GetBarSymbols(timeframe string, since time.Time) ([]string, error) {
+	query := `
+		SELECT DISTINCT symbol
+		FROM bars
+		WHERE timeframe = $1 AND open_time >= $2
+		ORDER BY symbol
+	`
+	rows, err := Database.Query(query, timeframe, since)
+	if err != nil {
+		log.Printf("Error retrieving bar symbols: %v", err)
+		metrics.RecordError("db_get_bars_error")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var symbols []string
+	for rows.Next() {
+		var symbol string
+		if err := rows.Scan(&symbol); err != nil {
+			metrics.RecordError("db_get_bars_error")
+			return nil, err
+		}
+		symbols = append(symbols, symbol)
+	}
+	return symbols, rows.Err()
+}
+
+// GetBarsSince returns symbol's timeframe bars opened since since, oldest first.
+func (postgres) GetBarsSince(symbol, timeframe string, since time.Time) ([]models.Bar, error) {
+	query := `
+		SELECT open_time, close_time, open, high, low, close, volume, trade_count, tick_count
+		FROM bars
+		WHERE symbol = $1 AND timeframe = $2 AND open_time >= $3
+		ORDER BY open_time
+	`
+	rows, err := Database.Query(query, symbol, timeframe, since)
+	if err != nil {
+		log.Printf("Error retrieving %s bars: %v", symbol, err)
+		metrics.RecordError("db_get_bars_error")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bars []models.Bar
+	for rows.Next() {
+		bar := models.Bar{Symbol: symbol, Timeframe: timeframe}
+		var openTime, closeTime time.Time
+		err := rows.Scan(&openTime, &closeTime, &bar.Open, &bar.High, &bar.Low, &bar.Close, &bar.Volume,
+			&bar.TradeCount, &bar.TickCount)
+		if err != nil {
+			metrics.RecordError("db_get_bars_error")
+			return nil, err
+		}
+		bar.OpenTime, bar.CloseTime = openTime.UnixMilli(), closeTime.UnixMilli()
+		bars = append(bars, bar)
+	}
+	return bars, rows.Err()
+}
+
+// SaveTrade ignores trades it has already stored, so replays and reconnects can't duplicate them.
+func (postgres) SaveTrade(trade models.Trade) error {
+	query := `
+		INSERT INTO trades (exchange, symbol, event_type, trade_id, price, quantity, is_buyer_maker, event_time, trade_time)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (exchange, symbol, event_type, trade_id, trade_time) DO NOTHING
+	`
+	_, err := Database.Exec(query, trade.Exchange, trade.Symbol, trade.EventType, trade.TradeID, trade.Price, trade.Quantity,
+		trade.IsBuyerMaker, trade.EventTime, time.UnixMilli(trade.TradeTime))
+	if err != nil {
+		log.Printf("Error saving trade: %v", err)
+		metrics.RecordError("db_save_trade_error")
+		return err
+	}
+	return nil
+}
+
+func (postgres) SaveArbitrageOpportunity(o models.ArbitrageOpportunity) (int64, error) {
+	query := `
+		INSERT INTO arbitrage_opportunities (symbol, buy_exchange, sell_exchange, buy_price, sell_price,
+			buy_time, sell_time, gross_bps, net_bps, max_net_bps, detected_at, opened_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id
+	`
+	var id int64
+	err := Database.QueryRow(query, o.Symbol, o.BuyExchange, o.SellExchange, o.BuyPrice, o.SellPrice,
+		o.BuyTime, o.SellTime, o.GrossBps, o.NetBps, o.MaxNetBps,
+		time.UnixMilli(o.DetectedAt), time.UnixMilli(o.OpenedAt)).Scan(&id)
+	if err != nil {
+		log.Printf("Error saving arbitrage opportunity: %v", err)
+		metrics.RecordError("db_save_arbitrage_error")
+		return 0, err
+	}
+	return id, nil
+}
+
+func (postgres) CloseArbitrageOpportunity(o models.ArbitrageOpportunity) error {
+	query := `
+		UPDATE arbitrage_opportunities
+		SET max_net_bps = $1, closed_at = $2
+		WHERE id = $3 AND opened_at = $4
+	`
+	_, err := Database.Exec(query, o.MaxNetBps, time.UnixMilli(o.ClosedAt), o.ID, time.UnixMilli(o.OpenedAt))
+	if err != nil {
+		log.Printf("Error closing arbitrage opportunity: %v", err)
+		metrics.RecordError("db_close_arbitrage_error")
+		return err
+	}
+	return nil
+}
+
+// SaveParentOrder stores a new parent, returning ErrDuplicateOrder if its client order ID is already stored.
+func (postgres) SaveParentOrder(p models.ParentOrder) (int64, error) {
+	query := `
+		INSERT INTO parent_orders (algo, symbol, side, quantity, filled_quantity, avg_price, arrival_price,
+			limit_price, status, child_count, fee, client_order_id, start_time, end_time, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NULLIF($12, ''), $13, $14, $15)
+		ON CONFLICT (client_order_id, start_time) DO NOTHING
+		RETURNING id
+	`
+	var id int64
+	err := Database.QueryRow(query, p.Algo, p.Symbol, p.Side, p.Quantity, p.FilledQty, p.AvgPrice, p.ArrivalPrice,
+		p.LimitPrice, p.Status, p.ChildCount, p.Fee, p.ClientOrderID, time.UnixMilli(p.StartTime), time.UnixMilli(p.EndTime),
+		time.UnixMilli(p.UpdatedAt)).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, ErrDuplicateOrder
+	}
+	if err != nil {
+		log.Printf("Error saving parent order: %v", err)
+		metrics.RecordError("db_save_parent_order_error")
+		return 0, err
+	}
+	return id, nil
+}
+
+// UpdateParentOrder stores a parent's fills, fees, child count and status.
+func (postgres) UpdateParentOrder(p models.ParentOrder) error {
+	query := `
+		UPDATE parent_orders
+		SET filled_quantity = $1, avg_price = $2, status = $3, child_count = $4, fee = $5, updated_at = $6
+		WHERE id = $7 AND start_time = $8
+	`
+	_, err := Database.Exec(query, p.FilledQty, p.AvgPrice, p.Status, p.ChildCount, p.Fee, time.UnixMilli(p.UpdatedAt),
+		p.ID, time.UnixMilli(p.StartTime))
+	if err != nil {
+		log.Printf("Error updating parent order %d: %v", p.ID, err)
+		metrics.RecordError("db_update_parent_order_error")
+		return err
+	}
+	return nil
+}
+
+// SaveChildOrder stores a child order once it is filled, canceled or replaced.
+func (postgres) SaveChildOrder(c models.ChildOrder) error {
+	query := `
+		INSERT INTO child_orders (parent_id, child_id, symbol, side, price, quantity, filled_quantity,
+			fee, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (parent_id, child_id, created_at) DO UPDATE SET
+			filled_quantity = EXCLUDED.filled_quantity,
+			fee = EXCLUDED.fee,
+			status = EXCLUDED.status,
+			updated_at = EXCLUDED.updated_at
+	`
+	_, err := Database.Exec(query, c.ParentID, c.ID, c.Symbol, c.Side, c.Price, c.Quantity, c.FilledQty,
+		c.Fee, c.Status, time.UnixMilli(c.CreatedAt), time.UnixMilli(c.UpdatedAt))
+	if err != nil {
+		log.Printf("Error saving child order %d of parent %d: %v", c.ID, c.ParentID, err)
+		metrics.RecordError("db_save_child_order_error")
+		return err
+	}
+	return nil
+}
+
+// SaveLimitOrder stores a new limit order, returning ErrDuplicateOrder if its client order ID is already stored.
+func (postgres) SaveLimitOrder(o models.LimitOrder) (int64, error) {
+	query := `
+		INSERT INTO limit_orders (symbol, side, price, quantity, filled_quantity, avg_price, time_in_force,
+			passive, status, fee, client_order_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, ''), $12, $13)
+		ON CONFLICT (client_order_id, created_at) DO NOTHING
+		RETURNING id
+	`
+	var id int64
+	err := Database.QueryRow(query, o.Symbol, o.Side, o.Price, o.Quantity, o.FilledQty, o.AvgPrice, o.TimeInForce,
+		o.Passive, o.Status, o.Fee, o.ClientOrderID, time.UnixMilli(o.CreatedAt), time.UnixMilli(o.UpdatedAt)).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, ErrDuplicateOrder
+	}
+	if err != nil {
+		log.Printf("Error saving limit order: %v", err)
+		metrics.RecordError("db_save_limit_order_error")
+		return 0, err
+	}
+	return id, nil
+}
+
+// UpdateLimitOrder stores a limit order's current price, size, fills, fees and status, and
+// appends event to its lifecycle.
+func (pg postgres) UpdateLimitOrder(o models.LimitOrder, event string) error {
+	query := `
+		UPDATE limit_orders
+		SET price = $1, quantity = $2, filled_quantity = $3, avg_price = $4, fee = $5, status = $6, updated_at = $7
+		WHERE id = $8 AND created_at = $9
+	`
+	_, err := Database.Exec(query, o.Price, o.Quantity, o.FilledQty, o.AvgPrice, o.Fee, o.Status, time.UnixMilli(o.UpdatedAt),
+		o.ID, time.UnixMilli(o.CreatedAt))
+	if err != nil {
+		log.Printf("Error updating limit order %d: %v", o.ID, err)
+		metrics.RecordError("db_update_limit_order_error")
+		return err
+	}
+	return pg.SaveLimitOrderEvent(o, event)
+}
+
+func (postgres) SaveLimitOrderEvent(o models.LimitOrder, event string) error {
+	query := `
+		INSERT INTO limit_order_events (order_id, event, status, price, quantity, filled_quantity, event_time)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	_, err := Database.Exec(query, o.ID, event, o.Status, o.Price, o.Quantity, o.FilledQty, time.UnixMilli(o.UpdatedAt))
+	if err != nil {
+		log.Printf("Error saving limit order %d event %s: %v", o.ID, event, err)
+		metrics.RecordError("db_save_limit_order_event_error")
+		return err
+	}
+	return nil
+}
+
+// SaveBalances appends a snapshot of balances for mode, paper or live, in one transaction.
+func (postgres) SaveBalances(mode string, balances []models.Balance) error {
+	tx, err := Database.Begin()
+	if err != nil {
+		metrics.RecordError("db_save_balances_error")
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, b := range balances {
+		_, err := tx.Exec(`
+			INSERT INTO balances (mode, asset, free, locked, time)
+			VALUES ($1, $2, $3, $4, $5)
+		`, mode, b.Asset, b.Free, b.Locked, time.UnixMilli(b.UpdatedAt))
+		if err != nil {
+			log.Printf("Error saving %s balance: %v", b.Asset, err)
+			metrics.RecordError("db_save_balances_error")
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		metrics.RecordError("db_save_balances_error")
+		return err
+	}
+	return nil
+}
+
+// LoadBalances returns the latest stored balance of every asset in mode.
+func (postgres) LoadBalances(mode string) ([]models.Balance, error) {
+	query := `
+		SELECT DISTINCT ON (asset) asset, free, locked, time
+		FROM balances
+		WHERE mode = $1
+		ORDER BY asset, time DESC
+	`
+	rows, err := Database.Query(query, mode)
+	if err != nil {
+		log.Printf("Error loading %s balances: %v", mode, err)
+		metrics.RecordError("db_load_balances_error")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var balances []models.Balance
+	for rows.Next() {
+		var b models.Balance
+		var updatedAt time.Time
+		if err := rows.Scan(&b.Asset, &b.Free, &b.Locked, &updatedAt); err != nil {
+			metrics.RecordError("db_load_balances_error")
+			return nil, err
+		}
+		b.UpdatedAt = updatedAt.UnixMilli()
+		balances = append(balances, b)
+	}
+	return balances, rows.Err()
+}
+
+// GetFillsSince returns the fills the orders table records from since on, oldest first: an
+// order created since then opened with a fill on its side, and one closed since then
+// filled the opposite side at its close price.
+func (postgres) GetFillsSince(since time.Time) ([]models.Fill, error) {
+	query := `
+		SELECT COALESCE(symbol, ''), order_type, quantity, price, fee, created_at
+		FROM orders
+		WHERE created_at >= $1
+		UNION ALL
+		SELECT COALESCE(symbol, ''), CASE WHEN order_type = 'buy' THEN 'sell' ELSE 'buy' END, quantity, close_price,
+			close_fee, updated_at
+		FROM orders
+		WHERE status = 'closed' AND updated_at >= $1
+		ORDER BY 6
+	`
+	rows, err := Database.Query(query, since)
+	if err != nil {
+		log.Printf("Error retrieving fills: %v", err)
+		metrics.RecordError("db_get_fills_error")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fills []models.Fill
+	for rows.Next() {
+		var f models.Fill
+		var eventTime time.Time
+		if err := rows.Scan(&f.Symbol, &f.Side, &f.Quantity, &f.Price, &f.Fee, &eventTime); err != nil {
+			metrics.RecordError("db_get_fills_error")
+			return nil, err
+		}
+		f.EventTime = eventTime.UnixMilli()
+		fills = append(fills, f)
+	}
+	return fills, rows.Err()
+}
+
+func (postgres) SaveBalanceDrift(mode string, d models.BalanceDrift) error {
+	query := `
+		INSERT INTO balance_drifts (mode, asset, expected, actual, drift, corrected, detected_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	_, err := Database.Exec(query, mode, d.Asset, d.Expected, d.Actual, d.Drift, d.Corrected, time.UnixMilli(d.DetectedAt))
+	if err != nil {
+		log.Printf("Error saving %s balance drift: %v", d.Asset, err)
+		metrics.RecordError("db_save_balance_drift_error")
+		return err
+	}
+	return nil
+}
+
+// GetWorkingParentOrders returns the parents stored as working, oldest first. Parents are
+// worked in memory, so after a restart these are in an unknown state.
+func (postgres) GetWorkingParentOrders() ([]models.ParentOrder, error) {
+	query := `
+		SELECT id, algo, symbol, side, quantity, filled_quantity, avg_price, arrival_price, limit_price,
+			status, child_count, fee, COALESCE(client_order_id, ''), start_time, end_time, updated_at
+		FROM parent_orders
+		WHERE status = 'working'
+		ORDER BY start_time, id
+	`
+	rows, err := Database.Query(query)
+	if err != nil {
+		log.Printf("Error retrieving working parent orders: %v", err)
+		metrics.RecordError("db_get_working_parent_orders_error")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var parents []models.ParentOrder
+	for rows.Next() {
+		var p models.ParentOrder
+		var start, end, updated time.Time
+		err := rows.Scan(&p.ID, &p.Algo, &p.Symbol, &p.Side, &p.Quantity, &p.FilledQty, &p.AvgPrice, &p.ArrivalPrice,
+			&p.LimitPrice, &p.Status, &p.ChildCount, &p.Fee, &p.ClientOrderID, &start, &end, &updated)
+		if err != nil {
+			metrics.RecordError("db_get_working_parent_orders_error")
+			return nil, err
+		}
+		p.StartTime, p.EndTime, p.UpdatedAt = start.UnixMilli(), end.UnixMilli(), updated.UnixMilli()
+		parents = append(parents, p)
+	}
+	return parents, rows.Err()
+}
+
+// GetWorkingLimitOrders returns the limit orders stored as open or partially filled, oldest first.
+func (postgres) GetWorkingLimitOrders() ([]models.LimitOrder, error) {
+	query := `
+		SELECT id, symbol, side, price, quantity, filled_quantity, avg_price, time_in_force, passive,
+			status, fee, COALESCE(client_order_id, ''), created_at, updated_at
+		FROM limit_orders
+		WHERE status IN ('open', 'partially_filled')
+		ORDER BY created_at, id
+	`
+	rows, err := Database.Query(query)
+	if err != nil {
+		log.Printf("Error retrieving working limit orders: %v", err)
+		metrics.RecordError("db_get_working_limit_orders_error")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []models.LimitOrder
+	for rows.Next() {
+		var o models.LimitOrder
+		var created, updated time.Time
+		err := rows.Scan(&o.ID, &o.Symbol, &o.Side, &o.Price, &o.Quantity, &o.FilledQty, &o.AvgPrice, &o.TimeInForce,
+			&o.Passive, &o.Status, &o.Fee, &o.ClientOrderID, &created, &updated)
+		if err != nil {
+			metrics.RecordError("db_get_working_limit_orders_error")
+			return nil, err
+		}
+		o.CreatedAt, o.UpdatedAt = created.UnixMilli(), updated.UnixMilli()
+		orders = append(orders, o)
+	}
+	return orders, rows.Err()
+}
+
+// GetStrategyPnL sums the closed orders of every strategy; orders from before strategies were
+// recorded are reported under "unknown".
+func (postgres) GetStrategyPnL() ([]models.StrategyPnL, error) {
+	query := `
+		SELECT COALESCE(strategy, 'unknown'), COUNT(*),
+			COALESCE(SUM(pnl + fee + close_fee), 0), COALESCE(SUM(fee + close_fee), 0),
+			COALESCE(SUM(slippage + close_slippage), 0), COALESCE(SUM(pnl), 0)
+		FROM orders
+		WHERE status = 'closed'
+		GROUP BY 1
+		ORDER BY 1
+	`
+	rows, err := Database.Query(query)
+	if err != nil {
+		log.Printf("Error retrieving strategy PnL: %v", err)
+		metrics.RecordError("db_get_strategy_pnl_error")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var report []models.StrategyPnL
+	for rows.Next() {
+		var r models.StrategyPnL
+		if err := rows.Scan(&r.Strategy, &r.Orders, &r.GrossPnL, &r.Fees, &r.Slippage, &r.NetPnL); err != nil {
+			metrics.RecordError("db_get_strategy_pnl_error")
+			return nil, err
+		}
+		report = append(report, r)
+	}
+	return report, rows.Err()
+}
+
+// SavePortfolioSnapshot stores one row per asset of portfolio, each with the portfolio's
+// totals, in one transaction.
+func (postgres) SavePortfolioSnapshot(portfolio models.Portfolio) error {
+	tx, err := Database.Begin()
+	if err != nil {
+		metrics.RecordError("db_save_portfolio_error")
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, a := range portfolio.Assets {
+		_, err := tx.Exec(`
+			INSERT INTO portfolio_snapshots (currency, asset, quantity, price, value, weight, equity,
+				gross_exposure, net_exposure, time)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		`, portfolio.Currency, a.Asset, a.Quantity, a.Price, a.Value, a.Weight, portfolio.Equity,
+			portfolio.GrossExposure, portfolio.NetExposure, time.UnixMilli(portfolio.Time))
+		if err != nil {
+			log.Printf("Error saving portfolio snapshot of %s: %v", a.Asset, err)
+			metrics.RecordError("db_save_portfolio_error")
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		metrics.RecordError("db_save_portfolio_error")
+		return err
+	}
 	return nil
 }
@@ -0,0 +1,141 @@
+package db
+
+import (
+	"time"
+
+	"github.com/turgaysozen/algotrading/decimal"
+	"github.com/turgaysozen/algotrading/models"
+)
+
+// Store is where the pipeline keeps books, signals, orders and everything derived from them.
+// The live store is Postgres; replays use a Memory store so they never touch live orders and
+// give the same result however often they run.
+type Store interface {
+	SaveOrderBook(exchange, eventType, symbol string, eventTime int64, bestBid, bestAsk float64, micro models.Microstructure) (int64, error)
+	SaveTrade(trade models.Trade) error
+
+	SaveSignal(signal models.Signal) error
+	SaveOrder(order models.Order) error
+	GetLastOpenOrder(symbol string) (*models.Order, error)
+	GetOpenBasketOrders() ([]models.Order, error)
+	CloseOrder(orderID int, closePrice, closeFee, closeSlippage, pnl decimal.Decimal, closeTime int64) error
+	SaveBasket(signals []models.Signal, orders []models.Order) ([]int, error)
+	CloseBasket(signals []models.Signal, orders []models.Order, closeTime int64) error
+	GetFillsSince(since time.Time) ([]models.Fill, error)
+	GetStrategyPnL() ([]models.StrategyPnL, error)
+
+	SaveBars(bars []models.Bar) error
+	AddBarTrade(symbol, timeframe string, openTime int64, qty float64) (bool, error)
+	GetBarSymbols(timeframe string, since time.Time) ([]string, error)
+	GetBarsSince(symbol, timeframe string, since time.Time) ([]models.Bar, error)
+
+	SaveArbitrageOpportunity(o models.ArbitrageOpportunity) (int64, error)
+	CloseArbitrageOpportunity(o models.ArbitrageOpportunity) error
+
+	SaveParentOrder(p models.ParentOrder) (int64, error)
+	UpdateParentOrder(p models.ParentOrder) error
+	SaveChildOrder(c models.ChildOrder) error
+	GetWorkingParentOrders() ([]models.ParentOrder, error)
+	SaveLimitOrder(o models.LimitOrder) (int64, error)
+	UpdateLimitOrder(o models.LimitOrder, event string) error
+	SaveLimitOrderEvent(o models.LimitOrder, event string) error
+	GetWorkingLimitOrders() ([]models.LimitOrder, error)
+
+	SaveBalances(mode string, balances []models.Balance) error
+	LoadBalances(mode string) ([]models.Balance, error)
+	SaveBalanceDrift(mode string, d models.BalanceDrift) error
+	SavePortfolioSnapshot(portfolio models.Portfolio) error
+}
+
+var store Store = postgres{}
+
+// Use makes every function below read and write s instead of Postgres.
+func Use(s Store) {
+	store = s
+}
+
+func SaveOrderBook(exchange, eventType, symbol string, eventTime int64, bestBid, bestAsk float64, micro models.Microstructure) (int64, error) {
+	return store.SaveOrderBook(exchange, eventType, symbol, eventTime, bestBid, bestAsk, micro)
+}
+
+func SaveTrade(trade models.Trade) error { return store.SaveTrade(trade) }
+
+func SaveSignal(signal models.Signal) error { return store.SaveSignal(signal) }
+
+func SaveOrder(order models.Order) error { return store.SaveOrder(order) }
+
+func GetLastOpenOrder(symbol string) (*models.Order, error) { return store.GetLastOpenOrder(symbol) }
+
+func GetOpenBasketOrders() ([]models.Order, error) { return store.GetOpenBasketOrders() }
+
+func CloseOrder(orderID int, closePrice, closeFee, closeSlippage, pnl decimal.Decimal, closeTime int64) error {
+	return store.CloseOrder(orderID, closePrice, closeFee, closeSlippage, pnl, closeTime)
+}
+
+func SaveBasket(signals []models.Signal, orders []models.Order) ([]int, error) {
+	return store.SaveBasket(signals, orders)
+}
+
+func CloseBasket(signals []models.Signal, orders []models.Order, closeTime int64) error {
+	return store.CloseBasket(signals, orders, closeTime)
+}
+
+func GetFillsSince(since time.Time) ([]models.Fill, error) { return store.GetFillsSince(since) }
+
+func GetStrategyPnL() ([]models.StrategyPnL, error) { return store.GetStrategyPnL() }
+
+func SaveBars(bars []models.Bar) error { return store.SaveBars(bars) }
+
+func AddBarTrade(symbol, timeframe string, openTime int64, qty float64) (bool, error) {
+	return store.AddBarTrade(symbol, timeframe, openTime, qty)
+}
+
+func GetBarSymbols(timeframe string, since time.Time) ([]string, error) {
+	return store.GetBarSymbols(timeframe, since)
+}
+
+func GetBarsSince(symbol, timeframe string, since time.Time) ([]models.Bar, error) {
+	return store.GetBarsSince(symbol, timeframe, since)
+}
+
+func SaveArbitrageOpportunity(o models.ArbitrageOpportunity) (int64, error) {
+	return store.SaveArbitrageOpportunity(o)
+}
+
+func CloseArbitrageOpportunity(o models.ArbitrageOpportunity) error {
+	return store.CloseArbitrageOpportunity(o)
+}
+
+func SaveParentOrder(p models.ParentOrder) (int64, error) { return store.SaveParentOrder(p) }
+
+func UpdateParentOrder(p models.ParentOrder) error { return store.UpdateParentOrder(p) }
+
+func SaveChildOrder(c models.ChildOrder) error { return store.SaveChildOrder(c) }
+
+func GetWorkingParentOrders() ([]models.ParentOrder, error) { return store.GetWorkingParentOrders() }
+
+func SaveLimitOrder(o models.LimitOrder) (int64, error) { return store.SaveLimitOrder(o) }
+
+func UpdateLimitOrder(o models.LimitOrder, event string) error {
+	return store.UpdateLimitOrder(o, event)
+}
+
+func SaveLimitOrderEvent(o models.LimitOrder, event string) error {
+	return store.SaveLimitOrderEvent(o, event)
+}
+
+func GetWorkingLimitOrders() ([]models.LimitOrder, error) { return store.GetWorkingLimitOrders() }
+
+func SaveBalances(mode string, balances []models.Balance) error {
+	return store.SaveBalances(mode, balances)
+}
+
+func LoadBalances(mode string) ([]models.Balance, error) { return store.LoadBalances(mode) }
+
+func SaveBalanceDrift(mode string, d models.BalanceDrift) error {
+	return store.SaveBalanceDrift(mode, d)
+}
+
+func SavePortfolioSnapshot(portfolio models.Portfolio) error {
+	return store.SavePortfolioSnapshot(portfolio)
+}
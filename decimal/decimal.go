@@ -0,0 +1,342 @@
+package decimal
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// Decimal is a fixed-point number stored as an int64 scaled by 10^Scale.
+// With 8 places it covers satoshi precision and values up to ~92 billion,
+// which is enough for prices, quantities, fees and PnL without float drift.
+type Decimal int64
+
+const Scale = 8
+
+const unit = 100_000_000
+
+var pow10 = [Scale + 1]int64{1, 10, 100, 1_000, 10_000, 100_000, 1_000_000, 10_000_000, 100_000_000}
+
+var ErrInvalidDecimal = errors.New("invalid decimal")
+
+// ErrOutOfRange is returned for values that don't fit a Decimal, NaN and infinities included.
+var ErrOutOfRange = fmt.Errorf("%w: out of range", ErrInvalidDecimal)
+
+const Zero Decimal = 0
+
+// Max and Min are the largest and smallest representable values; results that overflow
+// saturate to them.
+const (
+	Max Decimal = math.MaxInt64
+	Min Decimal = -math.MaxInt64
+)
+
+func New(value int64, places int) Decimal {
+	if places < 0 || places > Scale {
+		panic(fmt.Sprintf("decimal: places %d out of range", places))
+	}
+	return Decimal(value * pow10[Scale-places])
+}
+
+// FromInt converts i, saturating to Max or Min outside the range; ParseInt reports it instead.
+func FromInt(i int64) Decimal {
+	d, err := ParseInt(i)
+	if err != nil {
+		return saturate(i < 0)
+	}
+	return d
+}
+
+// ParseInt converts i, failing with ErrOutOfRange when it doesn't fit.
+func ParseInt(i int64) (Decimal, error) {
+	if i > math.MaxInt64/unit || i < -math.MaxInt64/unit {
+		return 0, fmt.Errorf("%w: %d", ErrOutOfRange, i)
+	}
+	return Decimal(i * unit), nil
+}
+
+// FromFloat rounds f half away from zero to the nearest representable value, saturating to
+// Max or Min outside the range and returning Zero for NaN; ParseFloat reports both instead.
+func FromFloat(f float64) Decimal {
+	d, err := ParseFloat(f)
+	if err != nil {
+		if math.IsNaN(f) {
+			return Zero
+		}
+		return saturate(f < 0)
+	}
+	return d
+}
+
+// ParseFloat rounds f half away from zero to the nearest representable value, failing with
+// ErrOutOfRange for NaN, infinities and values that don't fit.
+func ParseFloat(f float64) (Decimal, error) {
+	scaled := math.Round(f * unit)
+	// MaxInt64 isn't exact as a float64: it rounds up to 2^63, which no longer fits
+	if math.IsNaN(scaled) || scaled >= math.MaxInt64 || scaled <= -math.MaxInt64 {
+		return 0, fmt.Errorf("%w: %v", ErrOutOfRange, f)
+	}
+	return Decimal(scaled), nil
+}
+
+func saturate(neg bool) Decimal {
+	if neg {
+		return Min
+	}
+	return Max
+}
+
+// FromString parses a plain decimal string exactly, rounding anything past Scale places.
+func FromString(s string) (Decimal, error) {
+	str := strings.TrimSpace(s)
+	if str == "" {
+		return 0, fmt.Errorf("%w: empty string", ErrInvalidDecimal)
+	}
+	if strings.ContainsAny(str, "eE") {
+		f, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidDecimal, s)
+		}
+		return ParseFloat(f)
+	}
+
+	neg := false
+	switch str[0] {
+	case '-':
+		neg = true
+		str = str[1:]
+	case '+':
+		str = str[1:]
+	}
+
+	intPart, fracPart, _ := strings.Cut(str, ".")
+	if intPart == "" && fracPart == "" {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidDecimal, s)
+	}
+
+	roundUp := false
+	if len(fracPart) > Scale {
+		if !isDigits(fracPart[Scale:]) {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidDecimal, s)
+		}
+		roundUp = fracPart[Scale] >= '5'
+		fracPart = fracPart[:Scale]
+	}
+	fracPart += strings.Repeat("0", Scale-len(fracPart))
+
+	if !isDigits(intPart) || !isDigits(fracPart) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidDecimal, s)
+	}
+
+	var i, f int64
+	var err error
+	if intPart != "" {
+		i, err = strconv.ParseInt(intPart, 10, 64)
+		if err != nil || i > math.MaxInt64/unit {
+			return 0, fmt.Errorf("%w: %q", ErrOutOfRange, s)
+		}
+	}
+	f, _ = strconv.ParseInt(fracPart, 10, 64)
+	if roundUp {
+		f++
+	}
+	// i <= MaxInt64/unit, so only the fraction and its rounding can carry past MaxInt64
+	if i*unit > math.MaxInt64-f {
+		return 0, fmt.Errorf("%w: %q", ErrOutOfRange, s)
+	}
+	v := i*unit + f
+	if neg {
+		v = -v
+	}
+	return Decimal(v), nil
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (d Decimal) Add(o Decimal) Decimal { return d + o }
+
+func (d Decimal) Sub(o Decimal) Decimal { return d - o }
+
+func (d Decimal) Neg() Decimal { return -d }
+
+func (d Decimal) Abs() Decimal {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+func (d Decimal) Sign() int {
+	switch {
+	case d < 0:
+		return -1
+	case d > 0:
+		return 1
+	}
+	return 0
+}
+
+func (d Decimal) IsZero() bool { return d == 0 }
+
+func (d Decimal) Cmp(o Decimal) int {
+	switch {
+	case d < o:
+		return -1
+	case d > o:
+		return 1
+	}
+	return 0
+}
+
+// Mul multiplies with a 128 bit intermediate and rounds half away from zero, saturating to
+// Max or Min when the result doesn't fit.
+func (d Decimal) Mul(o Decimal) Decimal {
+	neg := (d < 0) != (o < 0)
+	hi, lo := bits.Mul64(uint64(d.Abs()), uint64(o.Abs()))
+	lo, carry := bits.Add64(lo, unit/2, 0)
+	hi += carry
+	if hi >= unit {
+		// the quotient needs more than 64 bits
+		return saturate(neg)
+	}
+	q, _ := bits.Div64(hi, lo, unit)
+	return signed(q, neg)
+}
+
+// Div divides with a 128 bit intermediate and rounds half away from zero, saturating to Max
+// or Min when the result doesn't fit. It panics on a zero divisor, like integer division.
+func (d Decimal) Div(o Decimal) Decimal {
+	if o == 0 {
+		panic("decimal: division by zero")
+	}
+	neg := (d < 0) != (o < 0)
+	divisor := uint64(o.Abs())
+	hi, lo := bits.Mul64(uint64(d.Abs()), unit)
+	lo, carry := bits.Add64(lo, divisor/2, 0)
+	hi += carry
+	if hi >= divisor {
+		return saturate(neg)
+	}
+	q, _ := bits.Div64(hi, lo, divisor)
+	return signed(q, neg)
+}
+
+func signed(q uint64, neg bool) Decimal {
+	if q > math.MaxInt64 {
+		return saturate(neg)
+	}
+	if neg {
+		return -Decimal(q)
+	}
+	return Decimal(q)
+}
+
+// Round rounds half away from zero to the given number of decimal places.
+func (d Decimal) Round(places int) Decimal {
+	if places >= Scale {
+		return d
+	}
+	if places < 0 {
+		places = 0
+	}
+	step := pow10[Scale-places]
+	v := int64(d.Abs())
+	if v > math.MaxInt64-step/2 {
+		// rounding up would overflow, so round down instead
+		v -= step
+	}
+	v = (v + step/2) / step * step
+	if d < 0 {
+		return Decimal(-v)
+	}
+	return Decimal(v)
+}
+
+func (d Decimal) Float64() float64 {
+	return float64(d) / unit
+}
+
+func (d Decimal) String() string {
+	v := int64(d)
+	sign := ""
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	frac := strings.TrimRight(fmt.Sprintf("%08d", v%unit), "0")
+	if frac == "" {
+		return sign + strconv.FormatInt(v/unit, 10)
+	}
+	return sign + strconv.FormatInt(v/unit, 10) + "." + frac
+}
+
+// Value stores the decimal as its exact string form so NUMERIC columns never see a float.
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+func (d *Decimal) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*d = 0
+		return nil
+	case []byte:
+		parsed, err := FromString(string(v))
+		if err != nil {
+			return err
+		}
+		*d = parsed
+		return nil
+	case string:
+		parsed, err := FromString(v)
+		if err != nil {
+			return err
+		}
+		*d = parsed
+		return nil
+	case int64:
+		parsed, err := ParseInt(v)
+		if err != nil {
+			return err
+		}
+		*d = parsed
+		return nil
+	case float64:
+		parsed, err := ParseFloat(v)
+		if err != nil {
+			return err
+		}
+		*d = parsed
+		return nil
+	}
+	return fmt.Errorf("%w: cannot scan %T", ErrInvalidDecimal, src)
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + d.String() + `"`), nil
+}
+
+// UnmarshalJSON accepts a number or a quoted number; null is zero.
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*d = 0
+		return nil
+	}
+	parsed, err := FromString(strings.Trim(string(data), `"`))
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
@@ -0,0 +1,86 @@
+package decimal
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestFromString(t *testing.T) {
+	cases := map[string]string{
+		"1":                 "1",
+		"-0.5":              "-0.5",
+		"67000.123456789":   "67000.12345679",
+		"0.000000004":       "0",
+		"1e-8":              "0.00000001",
+		"92233720368.54775": "92233720368.54775",
+	}
+	for in, want := range cases {
+		d, err := FromString(in)
+		if err != nil {
+			t.Fatalf("FromString(%q): %v", in, err)
+		}
+		if d.String() != want {
+			t.Errorf("FromString(%q) = %s, want %s", in, d, want)
+		}
+	}
+}
+
+func TestFromStringOutOfRange(t *testing.T) {
+	for _, in := range []string{"92233720368.54775808", "92233720368.547758079", "92233720369", "1e20", "NaN"} {
+		if _, err := FromString(in); !errors.Is(err, ErrInvalidDecimal) {
+			t.Errorf("FromString(%q): got %v, want ErrInvalidDecimal", in, err)
+		}
+	}
+}
+
+func TestParseFloat(t *testing.T) {
+	for _, f := range []float64{math.NaN(), math.Inf(1), math.Inf(-1), 1e11, -1e11} {
+		if _, err := ParseFloat(f); !errors.Is(err, ErrOutOfRange) {
+			t.Errorf("ParseFloat(%v): got %v, want ErrOutOfRange", f, err)
+		}
+	}
+	if FromFloat(math.NaN()) != Zero || FromFloat(1e11) != Max || FromFloat(-1e11) != Min {
+		t.Errorf("FromFloat doesn't saturate")
+	}
+	if _, err := ParseInt(math.MaxInt64 / unit * 2); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("ParseInt: got %v, want ErrOutOfRange", err)
+	}
+}
+
+func TestMulDiv(t *testing.T) {
+	if got := FromFloat(1.5).Mul(FromFloat(-2.25)); got.String() != "-3.375" {
+		t.Errorf("Mul = %s", got)
+	}
+	if got := FromInt(1).Div(FromInt(3)); got.String() != "0.33333333" {
+		t.Errorf("Div = %s", got)
+	}
+	if got := FromInt(1_000_000).Mul(FromInt(1_000_000)); got != Max {
+		t.Errorf("overflowing Mul = %s, want Max", got)
+	}
+	if got := FromInt(-1_000_000).Mul(FromInt(1_000_000)); got != Min {
+		t.Errorf("overflowing Mul = %s, want Min", got)
+	}
+	if got := FromInt(1_000_000_000).Div(New(1, 8)); got != Max {
+		t.Errorf("overflowing Div = %s, want Max", got)
+	}
+	if got := Max.Round(2); got <= 0 {
+		t.Errorf("Max.Round(2) = %s", got)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var v struct {
+		A Decimal `json:"a"`
+		B Decimal `json:"b"`
+		C Decimal `json:"c"`
+	}
+	v.C = FromInt(5)
+	if err := json.Unmarshal([]byte(`{"a": "1.25", "b": 2.5, "c": null}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.A.String() != "1.25" || v.B.String() != "2.5" || !v.C.IsZero() {
+		t.Errorf("got %s %s %s", v.A, v.B, v.C)
+	}
+}
@@ -0,0 +1,175 @@
+package execution
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/turgaysozen/algotrading/decimal"
+	"github.com/turgaysozen/algotrading/models"
+)
+
+// OrdersHandler serves /execution/orders: GET lists the working and recently finished
+// parent orders, or the one named by ?id=, and DELETE with ?id= cancels a working one.
+func OrdersHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, ok := queryID(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		orders := Orders()
+		if id == 0 {
+			json.NewEncoder(w).Encode(orders)
+			return
+		}
+		for _, order := range orders {
+			if order.ID == id {
+				json.NewEncoder(w).Encode(order)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"error": "parent order %d not found"}`, id)
+
+	case http.MethodDelete:
+		if id == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"error": "id is required"}`)
+			return
+		}
+		if err := Cancel(id); err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"error": %q}`, err.Error())
+			return
+		}
+		fmt.Fprintf(w, `{"status": "canceled", "id": %d}`, id)
+
+	default:
+		w.Header().Set("Allow", "GET, DELETE")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// LimitOrdersHandler serves /execution/limit-orders: GET lists working and recently finished
+// limit orders, or the one named by ?id=; POST places the order in the JSON body, answering
+// 409 with the existing order when its client_order_id was already placed; PUT with
+// ?id= and price and/or quantity cancels and replaces it; DELETE with ?id= cancels it.
+func LimitOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, ok := queryID(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		orders := LimitOrders()
+		if id == 0 {
+			json.NewEncoder(w).Encode(orders)
+			return
+		}
+		for _, order := range orders {
+			if order.ID == id {
+				json.NewEncoder(w).Encode(order)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"error": "limit order %d not found"}`, id)
+
+	case http.MethodPost:
+		var order models.LimitOrder
+		if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"error": %q}`, err.Error())
+			return
+		}
+		if order.TimeInForce == "" {
+			order.TimeInForce = GTC
+		}
+		placed, err := PlaceLimit(order, nil)
+		if errors.Is(err, ErrDuplicateOrder) {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(placed)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"error": %q}`, err.Error())
+			return
+		}
+		json.NewEncoder(w).Encode(placed)
+
+	case http.MethodPut:
+		price, ok := queryDecimal(w, r, "price")
+		if !ok {
+			return
+		}
+		quantity, ok := queryDecimal(w, r, "quantity")
+		if !ok {
+			return
+		}
+		amended, err := Amend(id, price, quantity)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"error": %q}`, err.Error())
+			return
+		}
+		json.NewEncoder(w).Encode(amended)
+
+	case http.MethodDelete:
+		if id == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"error": "id is required"}`)
+			return
+		}
+		if err := CancelLimit(id); err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"error": %q}`, err.Error())
+			return
+		}
+		fmt.Fprintf(w, `{"status": "canceled", "id": %d}`, id)
+
+	default:
+		w.Header().Set("Allow", "GET, POST, PUT, DELETE")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// queryDecimal parses an optional positive decimal parameter, zero when it is absent, answering
+// 400 when it is malformed or not positive.
+func queryDecimal(w http.ResponseWriter, r *http.Request, name string) (decimal.Decimal, bool) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return decimal.Zero, true
+	}
+	d, err := decimal.FromString(value)
+	if err != nil || d.Sign() <= 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"error": "invalid %s %q"}`, name, value)
+		return decimal.Zero, false
+	}
+	return d, true
+}
+
+// queryID parses the optional ?id= parameter, answering 400 when it is malformed.
+func queryID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	value := r.URL.Query().Get("id")
+	if value == "" {
+		return 0, true
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"error": "invalid id %q"}`, value)
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,48 @@
+package execution
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/turgaysozen/algotrading/db"
+	"github.com/turgaysozen/algotrading/decimal"
+	"github.com/turgaysozen/algotrading/models"
+)
+
+func TestAmendRejectsMalformedAndSkipsNoOps(t *testing.T) {
+	db.Use(db.NewMemory())
+	t.Cleanup(func() { db.Use(db.NewMemory()) })
+
+	book := func(eventTime int64) {
+		OnBook("AMENDUSDT", []models.PriceLevel{{Price: 100, Qty: 3}}, []models.PriceLevel{{Price: 101, Qty: 3}}, eventTime)
+	}
+	book(1704067200000)
+	placed, err := PlaceLimit(models.LimitOrder{Symbol: "AMENDUSDT", Side: "buy", Price: decimal.FromInt(100),
+		Quantity: decimal.FromInt(1), TimeInForce: GTC, ClientOrderID: "amend-test"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { CancelLimit(placed.ID) })
+	book(1704067201000)
+
+	put := func(query string) int {
+		rec := httptest.NewRecorder()
+		LimitOrdersHandler(rec, httptest.NewRequest(http.MethodPut, "/execution/limit-orders?id=1&"+query, nil))
+		return rec.Code
+	}
+	for _, query := range []string{"price=abc", "quantity=abc", "quantity=-1", "price=0"} {
+		if code := put(query); code != http.StatusBadRequest {
+			t.Errorf("PUT %s answered %d, want 400", query, code)
+		}
+	}
+
+	if code := put("price=100&quantity=1"); code != http.StatusOK {
+		t.Fatalf("no-op amend answered %d, want 200", code)
+	}
+	for _, order := range LimitOrders() {
+		if order.ID == placed.ID && (order.UpdatedAt != placed.UpdatedAt || order.QueueAhead != placed.QueueAhead) {
+			t.Errorf("no-op amend requeued the order: %+v, placed as %+v", order, placed)
+		}
+	}
+}
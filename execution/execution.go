@@ -0,0 +1,544 @@
+package execution
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/turgaysozen/algotrading/clock"
+	"github.com/turgaysozen/algotrading/config"
+	"github.com/turgaysozen/algotrading/costs"
+	"github.com/turgaysozen/algotrading/db"
+	"github.com/turgaysozen/algotrading/decimal"
+	"github.com/turgaysozen/algotrading/models"
+	"github.com/turgaysozen/algotrading/monitoring/metrics"
+)
+
+// Execution algorithms work a parent order through child orders over a horizon, driven by
+// the strategy exchange's books and trades in event time, so replays execute identically.
+// Children are simulated against the book: one priced at or through the opposite touch
+// takes its top level, and a resting one fills at its own price when trades print through
+// it or the book crosses it. No child is ever priced through the parent's limit price.
+
+// Algorithms, as named in EXECUTION_ALGO.
+const (
+	TWAP    = "twap"
+	VWAP    = "vwap"
+	Iceberg = "iceberg"
+)
+
+// Parent and child order statuses.
+const (
+	StatusWorking  = "working"
+	StatusFilled   = "filled"
+	StatusExpired  = "expired"
+	StatusCanceled = "canceled"
+	StatusReplaced = "replaced"
+)
+
+// maxRecent finished parent orders are kept for the API.
+const maxRecent = 100
+
+type Config struct {
+	// Algo works every strategy order when set; empty executes orders in full at the mid price.
+	Algo    string
+	Horizon time.Duration
+	// Slices is the number of equal TWAP slices over the horizon.
+	Slices int
+	// Participation is the VWAP share of the market volume traded since the order started.
+	Participation float64
+	// ClipSize is the quantity an iceberg shows at a time.
+	ClipSize float64
+	// LimitBps is how far from the arrival mid children may be priced.
+	LimitBps float64
+
+	// OrderType is market, executing strategy orders at mid, or limit.
+	OrderType   string
+	TimeInForce string
+	// Passive strategy limit orders join the near touch; others are priced LimitOffsetBps inside mid.
+	Passive        bool
+	LimitOffsetBps float64
+	// RepriceBps is how far the near touch may move from a passive order before it follows.
+	RepriceBps float64
+
+	// Exchange is the strategy exchange, whose fee schedule fills pay.
+	Exchange string
+}
+
+func LoadConfig() Config {
+	return Config{
+		Algo:          config.GetEnv("EXECUTION_ALGO", ""),
+		Horizon:       config.GetEnvDuration("EXECUTION_HORIZON", 5*time.Minute),
+		Slices:        config.GetEnvInt("EXECUTION_TWAP_SLICES", 10),
+		Participation: config.GetEnvFloat("EXECUTION_VWAP_PARTICIPATION", 0.1),
+		ClipSize:      config.GetEnvFloat("EXECUTION_ICEBERG_CLIP", 0.1),
+		LimitBps:      config.GetEnvFloat("EXECUTION_LIMIT_BPS", 50),
+
+		OrderType:      config.GetEnv("ORDER_TYPE", "market"),
+		TimeInForce:    config.GetEnv("ORDER_TIME_IN_FORCE", GTC),
+		Passive:        config.GetEnvBool("ORDER_PASSIVE", false),
+		LimitOffsetBps: config.GetEnvFloat("ORDER_LIMIT_OFFSET_BPS", 0),
+		RepriceBps:     config.GetEnvFloat("ORDER_REPRICE_BPS", 5),
+
+		Exchange: config.GetEnv("STRATEGY_EXCHANGE", models.DefaultExchange),
+	}
+}
+
+// touch is the latest book of a symbol, bids best first and asks best first.
+type touch struct {
+	bid, bidQty float64
+	ask, askQty float64
+	bids, asks  []models.PriceLevel
+	time        int64
+}
+
+// newTouch keeps the levels with quantity; zero quantity levels are removals in diff streams.
+func newTouch(bids, asks []models.PriceLevel, eventTime int64) touch {
+	bids = withQuantity(bids)
+	asks = withQuantity(asks)
+	sort.Slice(bids, func(i, j int) bool { return bids[i].Price > bids[j].Price })
+	sort.Slice(asks, func(i, j int) bool { return asks[i].Price < asks[j].Price })
+	return touch{
+		bid: bids[0].Price, bidQty: bids[0].Qty,
+		ask: asks[0].Price, askQty: asks[0].Qty,
+		bids: bids, asks: asks,
+		time: eventTime,
+	}
+}
+
+func withQuantity(levels []models.PriceLevel) []models.PriceLevel {
+	kept := make([]models.PriceLevel, 0, len(levels))
+	for _, level := range levels {
+		if level.Qty > 0 {
+			kept = append(kept, level)
+		}
+	}
+	return kept
+}
+
+type working struct {
+	parent models.ParentOrder
+	child  *models.ChildOrder
+	// marketVolume is the volume traded in the symbol since the parent started
+	marketVolume decimal.Decimal
+	onDone       func(models.ParentOrder)
+}
+
+var (
+	mu       sync.Mutex
+	cfg      Config
+	active   = make(map[int64]*working)
+	recent   []models.ParentOrder
+	touches  = make(map[string]touch)
+	childSeq int64
+	// finished holds the callbacks of finished orders, run once mu is released
+	finished []func()
+	clk      clock.Clock = clock.Real{}
+)
+
+// Init reads the execution settings.
+func Init() {
+	cfg = LoadConfig()
+	switch cfg.Algo {
+	case "", TWAP, VWAP, Iceberg:
+	default:
+		log.Printf("Unknown EXECUTION_ALGO %q, executing orders directly", cfg.Algo)
+		metrics.RecordError("execution_algo_invalid")
+		cfg.Algo = ""
+	}
+	if cfg.Algo != "" {
+		log.Printf("Executing orders with %s over %s", cfg.Algo, cfg.Horizon)
+	}
+	if cfg.OrderType != "market" && cfg.OrderType != "limit" {
+		log.Printf("Unknown ORDER_TYPE %q, using market orders", cfg.OrderType)
+		metrics.RecordError("order_type_invalid")
+		cfg.OrderType = "market"
+	}
+}
+
+// SetClock replaces the wall clock recovered orders are stamped with.
+func SetClock(c clock.Clock) {
+	clk = c
+}
+
+// Enabled reports whether orders should be submitted as parent orders.
+func Enabled() bool {
+	return cfg.Algo != ""
+}
+
+// Submit starts working quantity of symbol with the configured algorithm. onDone is called
+// once, with the final fills, when the parent is filled, expires or is canceled. A parent
+// already submitted with clientOrderID is returned with ErrDuplicateOrder.
+func Submit(symbol, side string, quantity decimal.Decimal, now int64, clientOrderID string, onDone func(models.ParentOrder)) (models.ParentOrder, error) {
+	if quantity.Sign() <= 0 {
+		return models.ParentOrder{}, fmt.Errorf("invalid quantity %s", quantity)
+	}
+
+	mu.Lock()
+	if existing, ok := parentByClientID(clientOrderID); ok {
+		mu.Unlock()
+		metrics.RecordDuplicateOrder("executor")
+		return existing, ErrDuplicateOrder
+	}
+	t, ok := touches[symbol]
+	if !ok {
+		mu.Unlock()
+		return models.ParentOrder{}, fmt.Errorf("no quote for %s yet", symbol)
+	}
+
+	precision := config.PrecisionFor(symbol)
+	arrival := (t.bid + t.ask) / 2
+	limit := arrival * (1 + cfg.LimitBps/10_000)
+	if side == "sell" {
+		limit = arrival * (1 - cfg.LimitBps/10_000)
+	}
+
+	w := &working{
+		parent: models.ParentOrder{
+			Algo:          cfg.Algo,
+			Symbol:        symbol,
+			Side:          side,
+			Quantity:      quantity,
+			ArrivalPrice:  decimal.FromFloat(arrival).Round(precision.Price),
+			LimitPrice:    decimal.FromFloat(limit).Round(precision.Price),
+			Status:        StatusWorking,
+			StartTime:     now,
+			EndTime:       now + cfg.Horizon.Milliseconds(),
+			UpdatedAt:     now,
+			ClientOrderID: clientOrderID,
+		},
+		onDone: onDone,
+	}
+
+	id, err := db.SaveParentOrder(w.parent)
+	if err != nil {
+		mu.Unlock()
+		if err == ErrDuplicateOrder {
+			metrics.RecordDuplicateOrder("executor")
+		}
+		return models.ParentOrder{}, err
+	}
+	w.parent.ID = id
+	active[id] = w
+	metrics.SetActiveParentOrders(cfg.Algo, len(active))
+	log.Printf("Parent order %d: %s %s %s with %s, limit %s", id, side, quantity, symbol, cfg.Algo, w.parent.LimitPrice)
+
+	w.step(t, now)
+	submitted := w.parent
+	done := takeFinished()
+	mu.Unlock()
+
+	notifyFinished(done)
+	return submitted, nil
+}
+
+// Cancel stops working parent order id and cancels its child.
+func Cancel(id int64) error {
+	mu.Lock()
+	w, ok := active[id]
+	if !ok {
+		mu.Unlock()
+		return fmt.Errorf("parent order %d is not working", id)
+	}
+	w.finish(StatusCanceled, touches[w.parent.Symbol].time)
+	done := takeFinished()
+	mu.Unlock()
+
+	notifyFinished(done)
+	return nil
+}
+
+// CancelSymbol cancels every parent and limit order working symbol, e.g. before the strategy reverses.
+func CancelSymbol(symbol string) {
+	mu.Lock()
+	for _, w := range workingIn(symbol) {
+		w.finish(StatusCanceled, touches[symbol].time)
+	}
+	for _, r := range restingIn(symbol) {
+		r.finish(StatusCanceled, touches[symbol].time)
+	}
+	done := takeFinished()
+	mu.Unlock()
+
+	notifyFinished(done)
+}
+
+// OnBook advances every parent and limit order in symbol on a new book. Both sides must have
+// a level with quantity.
+func OnBook(symbol string, bids, asks []models.PriceLevel, eventTime int64) {
+	t := newTouch(bids, asks, eventTime)
+
+	mu.Lock()
+	touches[symbol] = t
+	for _, w := range workingIn(symbol) {
+		w.step(t, eventTime)
+	}
+	for _, r := range restingIn(symbol) {
+		r.onBook(t, eventTime)
+	}
+	done := takeFinished()
+	mu.Unlock()
+
+	notifyFinished(done)
+}
+
+// OnTrade counts market volume for VWAP and fills resting children the trade printed through.
+func OnTrade(trade models.Trade) {
+	mu.Lock()
+	qty := decimal.FromFloat(trade.Quantity)
+	for _, w := range workingIn(trade.Symbol) {
+		if trade.TradeTime < w.parent.StartTime {
+			continue
+		}
+		w.marketVolume = w.marketVolume.Add(qty)
+
+		c := w.child
+		if c == nil {
+			continue
+		}
+		price := decimal.FromFloat(trade.Price)
+		// a buyer-maker trade is a seller hitting bids, the flow that fills a resting buy
+		through := (c.Side == "buy" && trade.IsBuyerMaker && price.Cmp(c.Price) <= 0) ||
+			(c.Side == "sell" && !trade.IsBuyerMaker && price.Cmp(c.Price) >= 0)
+		if through {
+			w.fill(minDecimal(qty, c.Quantity.Sub(c.FilledQty)), c.Price, costs.Maker, trade.TradeTime)
+		}
+	}
+	for _, r := range restingIn(trade.Symbol) {
+		r.onTrade(trade)
+	}
+	done := takeFinished()
+	mu.Unlock()
+
+	notifyFinished(done)
+}
+
+// Orders returns the working parent orders and the most recently finished ones, oldest first.
+func Orders() []models.ParentOrder {
+	mu.Lock()
+	defer mu.Unlock()
+
+	orders := append([]models.ParentOrder(nil), recent...)
+	for _, w := range workingIn("") {
+		orders = append(orders, w.parent)
+	}
+	return orders
+}
+
+// Touch returns the best bid and ask of symbol's latest book; ok is false before the first one.
+func Touch(symbol string) (bid, ask float64, ok bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	t, ok := touches[symbol]
+	return t.bid, t.ask, ok
+}
+
+// workingIn returns the parents working symbol, or all of them for "", in ID order so
+// replays fill them in the same order. Callers hold mu.
+func workingIn(symbol string) []*working {
+	var ws []*working
+	for _, w := range active {
+		if symbol == "" || w.parent.Symbol == symbol {
+			ws = append(ws, w)
+		}
+	}
+	sort.Slice(ws, func(i, j int) bool { return ws[i].parent.ID < ws[j].parent.ID })
+	return ws
+}
+
+// step brings the child in line with what the algorithm wants working now, replacing it
+// when its price or size is out of date, and takes liquidity if it is marketable.
+func (w *working) step(t touch, now int64) {
+	if now >= w.parent.EndTime {
+		w.finish(StatusExpired, now)
+		return
+	}
+
+	want := w.target(now).Sub(w.parent.FilledQty)
+	if cfg.Algo == Iceberg {
+		want = minDecimal(want, decimal.FromFloat(cfg.ClipSize))
+	}
+	want = want.Round(config.PrecisionFor(w.parent.Symbol).Quantity)
+	price := w.price(t)
+
+	if c := w.child; c != nil {
+		if c.Price == price && c.Quantity.Sub(c.FilledQty) == want {
+			// a resting child the book has crossed fills at its own price
+			if (c.Side == "buy" && t.ask <= c.Price.Float64()) || (c.Side == "sell" && t.bid >= c.Price.Float64()) {
+				w.fill(minDecimal(want, decimal.FromFloat(oppositeQty(c.Side, t))), c.Price, costs.Maker, now)
+			}
+			return
+		}
+		status := StatusReplaced
+		if want.Sign() <= 0 {
+			status = StatusCanceled
+		}
+		w.closeChild(status, now)
+	}
+
+	if want.Sign() <= 0 {
+		return
+	}
+
+	childSeq++
+	w.child = &models.ChildOrder{
+		ID:        childSeq,
+		ParentID:  w.parent.ID,
+		Symbol:    w.parent.Symbol,
+		Side:      w.parent.Side,
+		Price:     price,
+		Quantity:  want,
+		Status:    StatusWorking,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	w.parent.ChildCount++
+
+	// a new marketable child takes the opposite touch
+	if w.parent.Side == "buy" && t.ask <= price.Float64() {
+		w.fill(minDecimal(want, decimal.FromFloat(t.askQty)), decimal.FromFloat(t.ask), costs.Taker, now)
+	} else if w.parent.Side == "sell" && t.bid >= price.Float64() {
+		w.fill(minDecimal(want, decimal.FromFloat(t.bidQty)), decimal.FromFloat(t.bid), costs.Taker, now)
+	}
+}
+
+// target is the quantity the algorithm wants filled by now.
+func (w *working) target(now int64) decimal.Decimal {
+	p := w.parent
+	switch p.Algo {
+	case TWAP:
+		sliceLen := (p.EndTime - p.StartTime) / int64(max(cfg.Slices, 1))
+		due := int64(cfg.Slices)
+		if sliceLen > 0 {
+			due = min((now-p.StartTime)/sliceLen+1, int64(cfg.Slices))
+		}
+		return p.Quantity.Mul(decimal.FromInt(due)).Div(decimal.FromInt(int64(max(cfg.Slices, 1))))
+	case VWAP:
+		return minDecimal(p.Quantity, w.marketVolume.Mul(decimal.FromFloat(cfg.Participation)))
+	}
+	return p.Quantity
+}
+
+// price is where the next child goes: TWAP and VWAP cross the spread, icebergs join the
+// near touch, and neither goes through the limit price.
+func (w *working) price(t touch) decimal.Decimal {
+	p := w.parent
+	var price float64
+	if p.Side == "buy" {
+		price = t.ask
+		if p.Algo == Iceberg {
+			price = t.bid
+		}
+		price = min(price, p.LimitPrice.Float64())
+	} else {
+		price = t.bid
+		if p.Algo == Iceberg {
+			price = t.ask
+		}
+		price = max(price, p.LimitPrice.Float64())
+	}
+	return decimal.FromFloat(price).Round(config.PrecisionFor(p.Symbol).Price)
+}
+
+// fill rolls a child fill up into the parent's filled quantity, average price and fees;
+// liquidity is costs.Maker for a resting child and costs.Taker for a marketable one.
+func (w *working) fill(qty, price decimal.Decimal, liquidity string, now int64) {
+	if qty.Sign() <= 0 {
+		return
+	}
+	c, p := w.child, &w.parent
+
+	fee := costs.Fee(cfg.Exchange, p.Symbol, liquidity, qty.Mul(price))
+	c.FilledQty = c.FilledQty.Add(qty)
+	c.Fee = c.Fee.Add(fee)
+	c.UpdatedAt = now
+	p.Fee = p.Fee.Add(fee)
+	notional := p.AvgPrice.Mul(p.FilledQty).Add(price.Mul(qty))
+	p.FilledQty = p.FilledQty.Add(qty)
+	p.AvgPrice = notional.Div(p.FilledQty)
+	p.UpdatedAt = now
+	metrics.SetParentFillRatio(p.Algo, p.Symbol, p.FilledQty.Float64()/p.Quantity.Float64())
+
+	if c.FilledQty.Cmp(c.Quantity) >= 0 {
+		w.closeChild(StatusFilled, now)
+	}
+	if p.FilledQty.Cmp(p.Quantity) >= 0 {
+		w.finish(StatusFilled, now)
+		return
+	}
+	db.UpdateParentOrder(*p)
+}
+
+func (w *working) closeChild(status string, now int64) {
+	c := w.child
+	c.Status = status
+	c.UpdatedAt = now
+	db.SaveChildOrder(*c)
+	metrics.RecordChildOrder(w.parent.Algo, status)
+	w.child = nil
+}
+
+// finish ends the parent; the callback runs once mu is released. Callers hold mu.
+func (w *working) finish(status string, now int64) {
+	if w.child != nil {
+		w.closeChild(StatusCanceled, now)
+	}
+
+	p := &w.parent
+	if status == StatusExpired && p.FilledQty.Cmp(p.Quantity) >= 0 {
+		status = StatusFilled
+	}
+	p.Status = status
+	p.UpdatedAt = now
+	db.UpdateParentOrder(*p)
+
+	delete(active, p.ID)
+	recent = append(recent, *p)
+	if len(recent) > maxRecent {
+		recent = recent[1:]
+	}
+	finished = append(finished, func() {
+		if w.onDone != nil {
+			w.onDone(w.parent)
+		}
+	})
+
+	metrics.SetActiveParentOrders(p.Algo, len(active))
+	if p.FilledQty.Sign() > 0 {
+		slippage := (p.AvgPrice.Float64() - p.ArrivalPrice.Float64()) / p.ArrivalPrice.Float64() * 10_000
+		if p.Side == "sell" {
+			slippage = -slippage
+		}
+		metrics.SetExecutionSlippage(p.Algo, p.Symbol, slippage)
+	}
+	log.Printf("Parent order %d %s: filled %s of %s %s at %s in %d children",
+		p.ID, status, p.FilledQty, p.Quantity, p.Symbol, p.AvgPrice, p.ChildCount)
+}
+
+func takeFinished() []func() {
+	done := finished
+	finished = nil
+	return done
+}
+
+func notifyFinished(done []func()) {
+	for _, callback := range done {
+		callback()
+	}
+}
+
+func oppositeQty(side string, t touch) float64 {
+	if side == "buy" {
+		return t.askQty
+	}
+	return t.bidQty
+}
+
+func minDecimal(a, b decimal.Decimal) decimal.Decimal {
+	if a.Cmp(b) < 0 {
+		return a
+	}
+	return b
+}
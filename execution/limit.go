@@ -0,0 +1,450 @@
+package execution
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+
+	"github.com/turgaysozen/algotrading/config"
+	"github.com/turgaysozen/algotrading/costs"
+	"github.com/turgaysozen/algotrading/db"
+	"github.com/turgaysozen/algotrading/decimal"
+	"github.com/turgaysozen/algotrading/models"
+	"github.com/turgaysozen/algotrading/monitoring/metrics"
+)
+
+// Limit orders are simulated in paper mode with a queue model. An order that joins a price
+// level queues behind the quantity displayed there when it arrives (nothing if it improves
+// the book). Trades printing at the price and quantity leaving the level shrink that queue,
+// and only trade volume beyond it fills the order. A trade through the price, or the
+// opposite side crossing it, means the level was cleared and fills what remains.
+
+// Times in force, as in ORDER_TIME_IN_FORCE.
+const (
+	GTC      = "GTC"
+	IOC      = "IOC"
+	FOK      = "FOK"
+	PostOnly = "POST_ONLY"
+)
+
+// Limit order statuses besides the shared ones.
+const (
+	StatusOpen            = "open"
+	StatusPartiallyFilled = "partially_filled"
+	StatusRejected        = "rejected"
+)
+
+type resting struct {
+	order  models.LimitOrder
+	onDone func(models.LimitOrder)
+}
+
+var (
+	limits       = make(map[int64]*resting)
+	recentLimits []models.LimitOrder
+)
+
+// LimitOrdersEnabled reports whether strategy orders are placed as limit orders.
+func LimitOrdersEnabled() bool {
+	return cfg.OrderType == "limit"
+}
+
+// StrategyLimitOrder builds a limit order for quantity from the ORDER_* settings, created at
+// the signal's eventTime so a resubmission collides with the original's client order ID.
+func StrategyLimitOrder(symbol, side string, quantity decimal.Decimal, mid float64, eventTime int64, clientOrderID string) models.LimitOrder {
+	offset := cfg.LimitOffsetBps / 10_000
+	price := mid * (1 - offset)
+	if side == "sell" {
+		price = mid * (1 + offset)
+	}
+	return models.LimitOrder{
+		Symbol:        symbol,
+		Side:          side,
+		Price:         roundPrice(symbol, price),
+		Quantity:      quantity,
+		TimeInForce:   cfg.TimeInForce,
+		Passive:       cfg.Passive,
+		CreatedAt:     eventTime,
+		ClientOrderID: clientOrderID,
+	}
+}
+
+// PlaceLimit places o against the latest book of its symbol. onDone is called once, with
+// the final fills, when the order is filled, canceled, expires or is rejected. An order
+// already placed with o's client order ID is returned with ErrDuplicateOrder.
+func PlaceLimit(o models.LimitOrder, onDone func(models.LimitOrder)) (models.LimitOrder, error) {
+	switch o.TimeInForce {
+	case GTC, IOC, FOK, PostOnly:
+	default:
+		return models.LimitOrder{}, fmt.Errorf("unknown time in force %q", o.TimeInForce)
+	}
+	if o.Side != "buy" && o.Side != "sell" {
+		return models.LimitOrder{}, fmt.Errorf("unknown side %q", o.Side)
+	}
+	if o.Quantity.Sign() <= 0 || (!o.Passive && o.Price.Sign() <= 0) {
+		return models.LimitOrder{}, fmt.Errorf("invalid price %s or quantity %s", o.Price, o.Quantity)
+	}
+
+	mu.Lock()
+	if existing, ok := limitByClientID(o.ClientOrderID); ok {
+		mu.Unlock()
+		metrics.RecordDuplicateOrder("executor")
+		return existing, ErrDuplicateOrder
+	}
+	t, ok := touches[o.Symbol]
+	if !ok {
+		mu.Unlock()
+		return models.LimitOrder{}, fmt.Errorf("no quote for %s yet", o.Symbol)
+	}
+
+	if o.Passive {
+		o.Price = nearTouch(o, t)
+	}
+	o.Status = StatusOpen
+	o.FilledQty, o.AvgPrice, o.Fee = decimal.Zero, decimal.Zero, decimal.Zero
+	if o.CreatedAt == 0 {
+		o.CreatedAt = t.time
+	}
+	o.UpdatedAt = t.time
+
+	id, err := db.SaveLimitOrder(o)
+	if err != nil {
+		mu.Unlock()
+		if err == ErrDuplicateOrder {
+			metrics.RecordDuplicateOrder("executor")
+		}
+		return models.LimitOrder{}, err
+	}
+	o.ID = id
+	db.SaveLimitOrderEvent(o, "placed")
+
+	r := &resting{order: o, onDone: onDone}
+	limits[id] = r
+	r.arrive(t)
+	placed := r.order
+	done := takeFinished()
+	mu.Unlock()
+
+	notifyFinished(done)
+	return placed, nil
+}
+
+// Amend cancels and replaces a working limit order with a new price and quantity; a zero
+// price or quantity keeps the current one, and an amend that changes neither leaves the
+// order untouched. Like on exchanges, only reducing the quantity at the same price keeps
+// the order's place in the queue.
+func Amend(id int64, price, quantity decimal.Decimal) (models.LimitOrder, error) {
+	mu.Lock()
+	r, ok := limits[id]
+	if !ok {
+		mu.Unlock()
+		return models.LimitOrder{}, fmt.Errorf("limit order %d is not working", id)
+	}
+	o := r.order
+	if price.Sign() <= 0 {
+		price = o.Price
+	}
+	if quantity.Sign() <= 0 {
+		quantity = o.Quantity
+	}
+	if price == o.Price && quantity == o.Quantity {
+		mu.Unlock()
+		return o, nil
+	}
+	if quantity.Cmp(o.FilledQty) <= 0 {
+		mu.Unlock()
+		return models.LimitOrder{}, fmt.Errorf("quantity %s is not above the %s already filled", quantity, o.FilledQty)
+	}
+
+	t := touches[o.Symbol]
+	amended := o
+	amended.Price, amended.Quantity = price, quantity
+	if amended.TimeInForce == PostOnly && crosses(amended, t) {
+		mu.Unlock()
+		return models.LimitOrder{}, fmt.Errorf("amended price %s would take liquidity", price)
+	}
+
+	keepsPriority := price == o.Price && quantity.Cmp(o.Quantity) < 0
+	r.order.Price, r.order.Quantity = price, quantity
+	r.order.Passive = o.Passive && price == o.Price
+	metrics.RecordLimitOrderReplacement("amend")
+	if keepsPriority {
+		r.update("replaced", t.time)
+	} else {
+		r.requeue(t, "replaced")
+	}
+	amended = r.order
+	done := takeFinished()
+	mu.Unlock()
+
+	notifyFinished(done)
+	return amended, nil
+}
+
+// CancelLimit cancels a working limit order.
+func CancelLimit(id int64) error {
+	mu.Lock()
+	r, ok := limits[id]
+	if !ok {
+		mu.Unlock()
+		return fmt.Errorf("limit order %d is not working", id)
+	}
+	r.finish(StatusCanceled, touches[r.order.Symbol].time)
+	done := takeFinished()
+	mu.Unlock()
+
+	notifyFinished(done)
+	return nil
+}
+
+// LimitOrders returns the working limit orders and the most recently finished ones, oldest first.
+func LimitOrders() []models.LimitOrder {
+	mu.Lock()
+	defer mu.Unlock()
+
+	orders := append([]models.LimitOrder(nil), recentLimits...)
+	for _, r := range restingIn("") {
+		orders = append(orders, r.order)
+	}
+	return orders
+}
+
+// restingIn returns the limit orders working symbol, or all of them for "", in ID order. Callers hold mu.
+func restingIn(symbol string) []*resting {
+	var rs []*resting
+	for _, r := range limits {
+		if symbol == "" || r.order.Symbol == symbol {
+			rs = append(rs, r)
+		}
+	}
+	sort.Slice(rs, func(i, j int) bool { return rs[i].order.ID < rs[j].order.ID })
+	return rs
+}
+
+// arrive applies the time in force to a new order: it takes what it may from the book and
+// either rests the remainder or finishes.
+func (r *resting) arrive(t touch) {
+	o := &r.order
+	if crosses(*o, t) {
+		switch o.TimeInForce {
+		case PostOnly:
+			r.finish(StatusRejected, t.time)
+			return
+		case FOK:
+			if available(*o, t).Cmp(o.Quantity) < 0 {
+				r.finish(StatusExpired, t.time)
+				return
+			}
+		}
+		r.take(t)
+		if r.done() {
+			return
+		}
+	} else if o.TimeInForce == FOK {
+		r.finish(StatusExpired, t.time)
+		return
+	}
+
+	if o.TimeInForce == IOC {
+		r.finish(StatusExpired, t.time)
+		return
+	}
+	o.QueueAhead, _ = queueAt(*o, t)
+}
+
+// onBook follows the near touch for passive orders, fills orders the book crossed and
+// shrinks the queue when quantity leaves the order's level.
+func (r *resting) onBook(t touch, now int64) {
+	o := &r.order
+	if crosses(*o, t) {
+		// the opposite side trading through our price cleared our level
+		r.fill(o.Quantity.Sub(o.FilledQty), o.Price, costs.Maker, now)
+		return
+	}
+
+	if o.Passive {
+		near := nearTouch(*o, t)
+		if math.Abs(near.Float64()-o.Price.Float64())/o.Price.Float64()*10_000 > cfg.RepriceBps {
+			o.Price = near
+			metrics.RecordLimitOrderReplacement("passive_reprice")
+			r.requeue(t, "replaced")
+			return
+		}
+	}
+	if qty, visible := queueAt(*o, t); visible {
+		o.QueueAhead = math.Min(o.QueueAhead, qty)
+	}
+}
+
+// onTrade lets trades hitting the order's side eat the queue ahead of it, then fill it.
+func (r *resting) onTrade(trade models.Trade) {
+	o := &r.order
+	price := roundPrice(o.Symbol, trade.Price)
+	// a buyer-maker trade is a seller hitting bids, the flow that fills a resting buy
+	var at, through bool
+	if o.Side == "buy" {
+		at, through = trade.IsBuyerMaker && price == o.Price, trade.IsBuyerMaker && price.Cmp(o.Price) < 0
+	} else {
+		at, through = !trade.IsBuyerMaker && price == o.Price, !trade.IsBuyerMaker && price.Cmp(o.Price) > 0
+	}
+
+	qty := trade.Quantity
+	switch {
+	case through:
+		o.QueueAhead = 0
+	case at:
+		eaten := math.Min(qty, o.QueueAhead)
+		o.QueueAhead -= eaten
+		qty -= eaten
+	default:
+		return
+	}
+	if qty > 0 {
+		r.fill(minDecimal(decimal.FromFloat(qty), o.Quantity.Sub(o.FilledQty)), o.Price, costs.Maker, trade.TradeTime)
+	}
+}
+
+// requeue moves the order to the back of the queue at its price, taking liquidity first if
+// the new price crosses. Callers hold mu.
+func (r *resting) requeue(t touch, event string) {
+	r.order.QueueAhead, _ = queueAt(r.order, t)
+	r.update(event, t.time)
+	if crosses(r.order, t) {
+		r.take(t)
+	}
+}
+
+// take fills against the opposite side's levels up to the order's price.
+func (r *resting) take(t touch) {
+	o := &r.order
+	levels := t.asks
+	if o.Side == "sell" {
+		levels = t.bids
+	}
+	for _, level := range levels {
+		if r.done() || !priceOK(*o, level.Price) {
+			return
+		}
+		r.fill(minDecimal(decimal.FromFloat(level.Qty), o.Quantity.Sub(o.FilledQty)), decimal.FromFloat(level.Price), costs.Taker, t.time)
+	}
+}
+
+// fill adds a fill and its fee: costs.Taker when the order took the book, costs.Maker when it rested.
+func (r *resting) fill(qty, price decimal.Decimal, liquidity string, now int64) {
+	if qty.Sign() <= 0 || r.done() {
+		return
+	}
+	o := &r.order
+	o.Fee = o.Fee.Add(costs.Fee(cfg.Exchange, o.Symbol, liquidity, qty.Mul(price)))
+	notional := o.AvgPrice.Mul(o.FilledQty).Add(price.Mul(qty))
+	o.FilledQty = o.FilledQty.Add(qty)
+	o.AvgPrice = notional.Div(o.FilledQty)
+
+	if o.FilledQty.Cmp(o.Quantity) >= 0 {
+		r.finish(StatusFilled, now)
+		return
+	}
+	o.Status = StatusPartiallyFilled
+	r.update("fill", now)
+}
+
+func (r *resting) update(event string, now int64) {
+	r.order.UpdatedAt = now
+	db.UpdateLimitOrder(r.order, event)
+}
+
+// done reports whether the order has finished.
+func (r *resting) done() bool {
+	_, working := limits[r.order.ID]
+	return !working
+}
+
+// finish ends the order; the callback runs once mu is released. Callers hold mu.
+func (r *resting) finish(status string, now int64) {
+	o := &r.order
+	o.Status = status
+	o.UpdatedAt = now
+	db.UpdateLimitOrder(*o, status)
+
+	delete(limits, o.ID)
+	recentLimits = append(recentLimits, *o)
+	if len(recentLimits) > maxRecent {
+		recentLimits = recentLimits[1:]
+	}
+	finished = append(finished, func() {
+		if r.onDone != nil {
+			r.onDone(r.order)
+		}
+	})
+
+	metrics.RecordLimitOrder(o.TimeInForce, status)
+	log.Printf("Limit order %d %s: %s %s of %s %s at %s, avg %s",
+		o.ID, status, o.Side, o.FilledQty, o.Quantity, o.Symbol, o.Price, o.AvgPrice)
+}
+
+// nearTouch is the best price on the order's own side, where a passive order joins.
+func nearTouch(o models.LimitOrder, t touch) decimal.Decimal {
+	if o.Side == "sell" {
+		return roundPrice(o.Symbol, t.ask)
+	}
+	return roundPrice(o.Symbol, t.bid)
+}
+
+func roundPrice(symbol string, price float64) decimal.Decimal {
+	return decimal.FromFloat(price).Round(config.PrecisionFor(symbol).Price)
+}
+
+// crosses reports whether o would take liquidity from the opposite touch.
+func crosses(o models.LimitOrder, t touch) bool {
+	if o.Side == "buy" {
+		return priceOK(o, t.ask)
+	}
+	return priceOK(o, t.bid)
+}
+
+// priceOK reports whether o may trade at price.
+func priceOK(o models.LimitOrder, price float64) bool {
+	if o.Side == "buy" {
+		return price <= o.Price.Float64()
+	}
+	return price >= o.Price.Float64()
+}
+
+// available is the opposite side's quantity o could take.
+func available(o models.LimitOrder, t touch) decimal.Decimal {
+	levels := t.asks
+	if o.Side == "sell" {
+		levels = t.bids
+	}
+	total := decimal.Zero
+	for _, level := range levels {
+		if !priceOK(o, level.Price) {
+			break
+		}
+		total = total.Add(decimal.FromFloat(level.Qty))
+	}
+	return total
+}
+
+// queueAt is the quantity displayed at o's price on its own side, which o queues behind.
+// visible is false when the price is beyond the levels the book carries.
+func queueAt(o models.LimitOrder, t touch) (qty float64, visible bool) {
+	levels := t.bids
+	if o.Side == "sell" {
+		levels = t.asks
+	}
+	for _, level := range levels {
+		if roundPrice(o.Symbol, level.Price) == o.Price {
+			return level.Qty, true
+		}
+	}
+	if len(levels) == 0 {
+		return 0, false
+	}
+	worst := levels[len(levels)-1].Price
+	if o.Side == "buy" {
+		return 0, o.Price.Float64() >= worst
+	}
+	return 0, o.Price.Float64() <= worst
+}
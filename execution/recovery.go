@@ -0,0 +1,111 @@
+package execution
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+
+	"github.com/turgaysozen/algotrading/db"
+	"github.com/turgaysozen/algotrading/models"
+	"github.com/turgaysozen/algotrading/monitoring/metrics"
+)
+
+// Every strategy order carries a client order ID derived from the strategy, symbol and
+// signal, so submitting it again, whether retried after a timeout or sent by a second
+// instance that saw the same signal, is rejected instead of trading twice. Working
+// orders are checked in memory and the database's unique keys catch the rest.
+
+// ErrDuplicateOrder is returned when an order with the same client order ID was already submitted.
+var ErrDuplicateOrder = db.ErrDuplicateOrder
+
+// maxClientOrderID is the longest client order ID Binance accepts.
+const maxClientOrderID = 36
+
+// ClientOrderID derives the client order ID of strategy's order in symbol for the signal
+// with sequence, the signal's event time. IDs that would be too long keep the strategy and
+// replace the rest with a hash of it.
+func ClientOrderID(strategy, symbol string, sequence int64) string {
+	id := fmt.Sprintf("%s-%s-%d", strategy, symbol, sequence)
+	if len(id) <= maxClientOrderID {
+		return id
+	}
+	prefix := strategy
+	if len(prefix) > 12 {
+		prefix = prefix[:12]
+	}
+	sum := sha256.Sum256([]byte(id))
+	return prefix + "-" + hex.EncodeToString(sum[:])[:maxClientOrderID-len(prefix)-1]
+}
+
+// parentByClientID returns the working or recently finished parent with clientOrderID. Callers hold mu.
+func parentByClientID(clientOrderID string) (models.ParentOrder, bool) {
+	if clientOrderID == "" {
+		return models.ParentOrder{}, false
+	}
+	for _, w := range active {
+		if w.parent.ClientOrderID == clientOrderID {
+			return w.parent, true
+		}
+	}
+	for _, p := range recent {
+		if p.ClientOrderID == clientOrderID {
+			return p, true
+		}
+	}
+	return models.ParentOrder{}, false
+}
+
+// limitByClientID returns the working or recently finished limit order with clientOrderID. Callers hold mu.
+func limitByClientID(clientOrderID string) (models.LimitOrder, bool) {
+	if clientOrderID == "" {
+		return models.LimitOrder{}, false
+	}
+	for _, r := range limits {
+		if r.order.ClientOrderID == clientOrderID {
+			return r.order, true
+		}
+	}
+	for _, o := range recentLimits {
+		if o.ClientOrderID == clientOrderID {
+			return o, true
+		}
+	}
+	return models.LimitOrder{}, false
+}
+
+// Recover resolves the parent and limit orders the database still has working from before a
+// restart. Orders are simulated in memory, so they stopped working with the process: each
+// is canceled with the fills it had, and returned so those fills can be stored.
+func Recover() ([]models.ParentOrder, []models.LimitOrder, error) {
+	now := clk.Now().UnixMilli()
+
+	parents, err := db.GetWorkingParentOrders()
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range parents {
+		p := &parents[i]
+		p.Status = StatusCanceled
+		p.UpdatedAt = now
+		db.UpdateParentOrder(*p)
+		metrics.RecordRecoveredOrder("parent")
+		log.Printf("Recovered parent order %d (%s): canceled with %s of %s %s filled",
+			p.ID, p.ClientOrderID, p.FilledQty, p.Quantity, p.Symbol)
+	}
+
+	orders, err := db.GetWorkingLimitOrders()
+	if err != nil {
+		return parents, nil, err
+	}
+	for i := range orders {
+		o := &orders[i]
+		o.Status = StatusCanceled
+		o.UpdatedAt = now
+		db.UpdateLimitOrder(*o, "recovered")
+		metrics.RecordRecoveredOrder("limit")
+		log.Printf("Recovered limit order %d (%s): canceled with %s of %s %s filled",
+			o.ID, o.ClientOrderID, o.FilledQty, o.Quantity, o.Symbol)
+	}
+	return parents, orders, nil
+}
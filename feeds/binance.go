@@ -0,0 +1,26 @@
+package feeds
+
+import (
+	"github.com/turgaysozen/algotrading/models"
+	"github.com/turgaysozen/algotrading/wsclient"
+)
+
+// binanceFeed adapts wsclient, which already keeps local books with Binance's update ID
+// rules, merges redundant connections and hands over before the 24 hour disconnect.
+type binanceFeed struct{}
+
+func (f *binanceFeed) Exchange() string {
+	return Binance
+}
+
+func (f *binanceFeed) Run(h Handler) {
+	trackStatus(Binance, wsclient.Connected.Load, wsclient.Reconnect)
+
+	wsclient.Run(func(orderBook models.OrderBook) {
+		orderBook.Exchange = Binance
+		h.OnOrderBook(orderBook)
+	}, func(trade models.Trade) {
+		trade.Exchange = Binance
+		h.OnTrade(trade)
+	})
+}
@@ -0,0 +1,65 @@
+package feeds
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/turgaysozen/algotrading/wsclient"
+)
+
+// The first update is held until the REST snapshot arrives, the next applies on top of it and
+// the jump from update 102 to 110 fetches a second snapshot that the later updates build on.
+func TestBinanceUpdateIDGapResnapshots(t *testing.T) {
+	snapshots := []string{
+		`{"lastUpdateId":100,"bids":[["41998.00","1.000"]],"asks":[["42002.00","1.000"]]}`,
+		`{"lastUpdateId":120,"bids":[["42000.00","3.000"]],"asks":[["42001.00","2.000"]]}`,
+	}
+	var mu sync.Mutex
+	requests := 0
+	rest := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		n := requests
+		requests++
+		mu.Unlock()
+		if r.URL.Query().Get("symbol") != "BTCUSDT" || n >= len(snapshots) {
+			http.Error(w, "unexpected snapshot request", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, snapshots[n])
+	}))
+	defer rest.Close()
+
+	server := newFixtureServer(t, "binance_gap.jsonl")
+	t.Setenv("WEB_SOCKET_URL", server.url())
+	t.Setenv("WEB_SOCKET_RETRY_INITIAL", "10ms")
+	t.Setenv("WEB_SOCKET_RETRY_MAX", "10ms")
+	t.Setenv("WEB_SOCKET_RETRY_MAX_ATTEMPTS", "1")
+	wsclient.UseLocalBooks(rest.URL)
+	e := runFeed(t, Binance, server)
+
+	books, _ := e.wait(t, 3, 0)
+	if len(books) != 3 {
+		t.Fatalf("got %d books, want 3: %+v", len(books), books)
+	}
+	for _, b := range books {
+		if b.Exchange != Binance || b.Symbol != "BTCUSDT" {
+			t.Errorf("book from %s %s, want binance BTCUSDT", b.Exchange, b.Symbol)
+		}
+	}
+	assertTop(t, books[0], 42000, 1, 42002, 1)
+	assertTop(t, books[1], 42000, 1, 42001, 2)
+	// update 110 was never applied; the second snapshot brought the 42000 bid and 121 added 41999
+	assertTop(t, books[2], 42000, 3, 42001, 2)
+	if len(books[2].Bids) != 2 || books[2].FinalUpdateID != 121 {
+		t.Errorf("book after the resnapshot = %+v, want update 121 with bids 42000 and 41999", books[2])
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if requests != 2 {
+		t.Errorf("got %d snapshot requests, want a second one after the gap", requests)
+	}
+}
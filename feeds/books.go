@@ -0,0 +1,68 @@
+package feeds
+
+import (
+	"github.com/turgaysozen/algotrading/config"
+	"github.com/turgaysozen/algotrading/models"
+	"github.com/turgaysozen/algotrading/monitoring/metrics"
+	"github.com/turgaysozen/algotrading/orderbook"
+	"github.com/turgaysozen/algotrading/utils"
+)
+
+// books holds an adapter's local order books by canonical symbol. Each adapter only
+// touches its books from its session goroutine.
+type books struct {
+	exchange string
+	depth    int
+	bySymbol map[string]*orderbook.Book
+}
+
+func newBooks(exchange string) *books {
+	return &books{
+		exchange: exchange,
+		depth:    config.GetEnvInt("ORDER_BOOK_DEPTH", 20),
+		bySymbol: make(map[string]*orderbook.Book),
+	}
+}
+
+func (b *books) get(symbol string) *orderbook.Book {
+	book, ok := b.bySymbol[symbol]
+	if !ok {
+		book = orderbook.New()
+		b.bySymbol[symbol] = book
+	}
+	return book
+}
+
+// reset drops every book, e.g. before resubscribing for fresh snapshots.
+func (b *books) reset() {
+	for _, book := range b.bySymbol {
+		book.Reset()
+	}
+}
+
+// emit passes the top of symbol's book on as a normalised order book event.
+func (b *books) emit(h Handler, symbol string, eventTime int64) {
+	metrics.SetStartTime("orderbook_avg")
+
+	bids, asks := b.get(symbol).Top(b.depth)
+	h.OnOrderBook(models.OrderBook{
+		Exchange:  b.exchange,
+		EventType: "book",
+		Symbol:    symbol,
+		EventTime: eventTime,
+		Bids:      bids,
+		Asks:      asks,
+	})
+}
+
+func parseLevel(price, qty string) (models.PriceLevel, error) {
+	p, err := utils.StringToFloat64(price)
+	if err != nil {
+		return models.PriceLevel{}, err
+	}
+	q, err := utils.StringToFloat64(qty)
+	if err != nil {
+		return models.PriceLevel{}, err
+	}
+	return models.PriceLevel{Price: p, Qty: q}, nil
+}
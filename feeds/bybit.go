@@ -0,0 +1,202 @@
+package feeds
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/turgaysozen/algotrading/config"
+	"github.com/turgaysozen/algotrading/models"
+	"github.com/turgaysozen/algotrading/monitoring/metrics"
+	"github.com/turgaysozen/algotrading/orderbook"
+)
+
+// Bybit v5 sends a snapshot on subscribe and whenever its own state is reset (also signalled
+// by update ID 1), then deltas with increasing update IDs; older deltas are stale and dropped.
+// Bybit wants an application level {"op":"ping"} to keep the connection open.
+
+type bybitMessage struct {
+	Topic string          `json:"topic"`
+	Type  string          `json:"type"`
+	TS    int64           `json:"ts"`
+	Data  json.RawMessage `json:"data"`
+	Op    string          `json:"op"`
+	// Success is only set on command responses
+	Success *bool  `json:"success"`
+	RetMsg  string `json:"ret_msg"`
+}
+
+type bybitBook struct {
+	Symbol   string      `json:"s"`
+	Bids     [][2]string `json:"b"`
+	Asks     [][2]string `json:"a"`
+	UpdateID int64       `json:"u"`
+}
+
+type bybitTrade struct {
+	Time    int64  `json:"T"`
+	Symbol  string `json:"s"`
+	Side    string `json:"S"`
+	Volume  string `json:"v"`
+	Price   string `json:"p"`
+	TradeID string `json:"i"`
+}
+
+type bybitFeed struct {
+	symbols   []string
+	depth     int
+	books     *books
+	updateIDs map[string]int64
+	h         Handler
+}
+
+func newBybitFeed() *bybitFeed {
+	return &bybitFeed{
+		symbols:   splitList(config.GetEnv("BYBIT_SYMBOLS", "BTCUSDT")),
+		depth:     config.GetEnvInt("BYBIT_BOOK_DEPTH", 50),
+		books:     newBooks(Bybit),
+		updateIDs: make(map[string]int64),
+	}
+}
+
+func (f *bybitFeed) Exchange() string {
+	return Bybit
+}
+
+func (f *bybitFeed) Run(h Handler) {
+	f.h = h
+	s := &session{
+		exchange:  Bybit,
+		url:       config.GetEnv("BYBIT_WS_URL", "wss://stream.bybit.com/v5/public/spot"),
+		subscribe: f.subscribe,
+		handle:    f.handle,
+		ping:      []byte(`{"op":"ping"}`),
+	}
+	s.run()
+}
+
+func (f *bybitFeed) subscribe(s *session) error {
+	f.books.reset()
+
+	var topics []string
+	for _, symbol := range f.symbols {
+		topics = append(topics, "orderbook."+strconv.Itoa(f.depth)+"."+symbol, "publicTrade."+symbol)
+	}
+	msg, err := json.Marshal(map[string]interface{}{"op": "subscribe", "args": topics})
+	if err != nil {
+		return err
+	}
+	return s.send(msg)
+}
+
+func (f *bybitFeed) handle(s *session, data []byte) error {
+	var msg bybitMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		log.Println("Error unmarshalling Bybit message:", err)
+		metrics.RecordError("json_unmarshal_error")
+		return nil
+	}
+
+	if msg.Success != nil && !*msg.Success {
+		log.Printf("Bybit %s request failed: %s", msg.Op, msg.RetMsg)
+		metrics.RecordError("bybit_request_error")
+		return nil
+	}
+
+	switch {
+	case strings.HasPrefix(msg.Topic, "orderbook."):
+		return f.handleBook(msg)
+	case strings.HasPrefix(msg.Topic, "publicTrade."):
+		f.handleTrades(msg)
+	}
+	return nil
+}
+
+func (f *bybitFeed) handleBook(msg bybitMessage) error {
+	var update bybitBook
+	if err := json.Unmarshal(msg.Data, &update); err != nil {
+		log.Println("Error unmarshalling Bybit book:", err)
+		metrics.RecordError("json_unmarshal_error")
+		return errResync
+	}
+
+	bids, err := bybitLevels(update.Bids)
+	if err != nil {
+		return errResync
+	}
+	asks, err := bybitLevels(update.Asks)
+	if err != nil {
+		return errResync
+	}
+
+	symbol := canonicalSymbol(update.Symbol)
+	book := f.books.get(symbol)
+	if msg.Type == "snapshot" || update.UpdateID == 1 {
+		book.LoadSnapshot(orderbook.Snapshot{Bids: bids, Asks: asks})
+	} else {
+		if update.UpdateID <= f.updateIDs[symbol] {
+			return nil
+		}
+		if err := book.Update(bids, asks); err != nil {
+			return nil
+		}
+	}
+	f.updateIDs[symbol] = update.UpdateID
+
+	f.books.emit(f.h, symbol, msg.TS)
+	return nil
+}
+
+func (f *bybitFeed) handleTrades(msg bybitMessage) {
+	var trades []bybitTrade
+	if err := json.Unmarshal(msg.Data, &trades); err != nil {
+		log.Println("Error unmarshalling Bybit trades:", err)
+		metrics.RecordError("json_unmarshal_error")
+		return
+	}
+
+	for _, t := range trades {
+		level, err := parseLevel(t.Price, t.Volume)
+		if err != nil {
+			metrics.RecordTickRejection(models.RejectMalformedPrice)
+			continue
+		}
+		f.h.OnTrade(models.Trade{
+			Exchange:  Bybit,
+			EventType: "trade",
+			Symbol:    canonicalSymbol(t.Symbol),
+			EventTime: msg.TS,
+			TradeID:   bybitTradeID(t.TradeID),
+			Price:     level.Price,
+			Quantity:  level.Qty,
+			TradeTime: t.Time,
+			// S is the taker's side
+			IsBuyerMaker: t.Side == "Sell",
+		})
+	}
+}
+
+func bybitLevels(levels [][2]string) ([]models.PriceLevel, error) {
+	parsed := make([]models.PriceLevel, 0, len(levels))
+	for _, l := range levels {
+		level, err := parseLevel(l[0], l[1])
+		if err != nil {
+			metrics.RecordTickRejection(models.RejectMalformedPrice)
+			return nil, err
+		}
+		parsed = append(parsed, level)
+	}
+	return parsed, nil
+}
+
+// bybitTradeID returns numeric trade IDs as is and hashes the UUIDs some markets use.
+func bybitTradeID(id string) int64 {
+	if n, err := strconv.ParseInt(id, 10, 64); err == nil {
+		return n
+	}
+	h := fnv.New64a()
+	h.Write([]byte(id))
+	return int64(h.Sum64() >> 1)
+}
@@ -0,0 +1,31 @@
+package feeds
+
+import "testing"
+
+// A snapshot, a delta, a stale delta repeating update ID 101 that must be dropped, a trade and
+// a delta on the ask side.
+func TestBybitDropsStaleDeltas(t *testing.T) {
+	server := newFixtureServer(t, "bybit.jsonl")
+	e := runFeed(t, Bybit, server)
+
+	books, trades := e.wait(t, 3, 1)
+	if len(books) != 3 {
+		t.Fatalf("got %d books, want 3: %+v", len(books), books)
+	}
+	assertTop(t, books[0], 100, 1.5, 101, 1)
+	assertTop(t, books[1], 99.5, 4, 101, 1)
+	// the stale 100.50 bid never made it into the book
+	assertTop(t, books[2], 99.5, 4, 101.5, 2)
+	if books[2].EventTime != 1704067204000 {
+		t.Errorf("last book at %d, want the delta's ts 1704067204000", books[2].EventTime)
+	}
+	if got := server.connections(); got != 1 {
+		t.Errorf("got %d connections, want no reconnect", got)
+	}
+
+	tr := trades[0]
+	if tr.Exchange != Bybit || tr.Symbol != "BTCUSDT" || tr.TradeID != 2290000000061666327 || tr.Price != 99.5 ||
+		tr.Quantity != 0.25 || !tr.IsBuyerMaker {
+		t.Errorf("trade = %+v, want taker sell 2290000000061666327 of 0.25 BTCUSDT at 99.5", tr)
+	}
+}
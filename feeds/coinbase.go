@@ -0,0 +1,195 @@
+package feeds
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/turgaysozen/algotrading/config"
+	"github.com/turgaysozen/algotrading/models"
+	"github.com/turgaysozen/algotrading/monitoring/metrics"
+	"github.com/turgaysozen/algotrading/orderbook"
+)
+
+// Coinbase Advanced Trade numbers every message on a connection with sequence_num; a
+// skipped number means a lost message, so the connection is replaced and level2 sends
+// a fresh snapshot. The heartbeats channel keeps quiet connections open.
+
+type coinbaseMessage struct {
+	Channel     string          `json:"channel"`
+	Timestamp   time.Time       `json:"timestamp"`
+	SequenceNum int64           `json:"sequence_num"`
+	Events      json.RawMessage `json:"events"`
+}
+
+type coinbaseBookEvent struct {
+	Type      string `json:"type"`
+	ProductID string `json:"product_id"`
+	Updates   []struct {
+		Side        string `json:"side"`
+		PriceLevel  string `json:"price_level"`
+		NewQuantity string `json:"new_quantity"`
+	} `json:"updates"`
+}
+
+type coinbaseTradeEvent struct {
+	Type   string `json:"type"`
+	Trades []struct {
+		TradeID   string    `json:"trade_id"`
+		ProductID string    `json:"product_id"`
+		Price     string    `json:"price"`
+		Size      string    `json:"size"`
+		Side      string    `json:"side"`
+		Time      time.Time `json:"time"`
+	} `json:"trades"`
+}
+
+type coinbaseFeed struct {
+	products []string
+	books    *books
+	h        Handler
+	lastSeq  int64
+	seqSeen  bool
+}
+
+func newCoinbaseFeed() *coinbaseFeed {
+	return &coinbaseFeed{
+		products: splitList(config.GetEnv("COINBASE_PRODUCTS", "BTC-USD")),
+		books:    newBooks(Coinbase),
+	}
+}
+
+func (f *coinbaseFeed) Exchange() string {
+	return Coinbase
+}
+
+func (f *coinbaseFeed) Run(h Handler) {
+	f.h = h
+	s := &session{
+		exchange:  Coinbase,
+		url:       config.GetEnv("COINBASE_WS_URL", "wss://advanced-trade-ws.coinbase.com"),
+		subscribe: f.subscribe,
+		handle:    f.handle,
+	}
+	s.run()
+}
+
+func (f *coinbaseFeed) subscribe(s *session) error {
+	f.books.reset()
+	f.seqSeen = false
+
+	for _, channel := range []string{"heartbeats", "level2", "market_trades"} {
+		msg, err := json.Marshal(map[string]interface{}{
+			"type":        "subscribe",
+			"product_ids": f.products,
+			"channel":     channel,
+		})
+		if err != nil {
+			return err
+		}
+		if err := s.send(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *coinbaseFeed) handle(s *session, data []byte) error {
+	var msg coinbaseMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		log.Println("Error unmarshalling Coinbase message:", err)
+		metrics.RecordError("json_unmarshal_error")
+		return nil
+	}
+
+	if f.seqSeen && msg.SequenceNum != f.lastSeq+1 {
+		metrics.RecordDataLoss("coinbase_sequence_gap")
+		return fmt.Errorf("%w: expected sequence %d, got %d", errResync, f.lastSeq+1, msg.SequenceNum)
+	}
+	f.lastSeq, f.seqSeen = msg.SequenceNum, true
+
+	switch msg.Channel {
+	case "l2_data":
+		return f.handleBook(msg)
+	case "market_trades":
+		f.handleTrades(msg)
+	case "error":
+		log.Printf("Coinbase feed error: %s", data)
+		metrics.RecordError("coinbase_feed_error")
+	}
+	return nil
+}
+
+func (f *coinbaseFeed) handleBook(msg coinbaseMessage) error {
+	var events []coinbaseBookEvent
+	if err := json.Unmarshal(msg.Events, &events); err != nil {
+		log.Println("Error unmarshalling Coinbase book events:", err)
+		metrics.RecordError("json_unmarshal_error")
+		return errResync
+	}
+
+	for _, event := range events {
+		var bids, asks []models.PriceLevel
+		for _, u := range event.Updates {
+			level, err := parseLevel(u.PriceLevel, u.NewQuantity)
+			if err != nil {
+				metrics.RecordTickRejection(models.RejectMalformedPrice)
+				return errResync
+			}
+			if u.Side == "bid" {
+				bids = append(bids, level)
+			} else {
+				asks = append(asks, level)
+			}
+		}
+
+		symbol := canonicalSymbol(event.ProductID)
+		book := f.books.get(symbol)
+		if event.Type == "snapshot" {
+			book.LoadSnapshot(orderbook.Snapshot{Bids: bids, Asks: asks})
+		} else if err := book.Update(bids, asks); err != nil {
+			// an update before the snapshot
+			continue
+		}
+		f.books.emit(f.h, symbol, msg.Timestamp.UnixMilli())
+	}
+	return nil
+}
+
+func (f *coinbaseFeed) handleTrades(msg coinbaseMessage) {
+	var events []coinbaseTradeEvent
+	if err := json.Unmarshal(msg.Events, &events); err != nil {
+		log.Println("Error unmarshalling Coinbase trade events:", err)
+		metrics.RecordError("json_unmarshal_error")
+		return
+	}
+
+	for _, event := range events {
+		// the snapshot replays recent trades from before the subscription
+		if event.Type == "snapshot" {
+			continue
+		}
+		for _, t := range event.Trades {
+			level, err := parseLevel(t.Price, t.Size)
+			if err != nil {
+				metrics.RecordTickRejection(models.RejectMalformedPrice)
+				continue
+			}
+			id, _ := strconv.ParseInt(t.TradeID, 10, 64)
+			f.h.OnTrade(models.Trade{
+				Exchange:  Coinbase,
+				EventType: "trade",
+				Symbol:    canonicalSymbol(t.ProductID),
+				EventTime: msg.Timestamp.UnixMilli(),
+				TradeID:   id,
+				Price:     level.Price,
+				Quantity:  level.Qty,
+				TradeTime: t.Time.UnixMilli(),
+				// side is the taker's side
+				IsBuyerMaker: t.Side == "SELL",
+			})
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package feeds
+
+import "testing"
+
+// The first connection delivers a snapshot, a delta, a trade and then skips sequence number 4,
+// which must drop the connection; the second connection's snapshot rebuilds the book.
+func TestCoinbaseSequenceGapResyncs(t *testing.T) {
+	server := newFixtureServer(t, "coinbase_gap.jsonl", "coinbase_resync.jsonl")
+	e := runFeed(t, Coinbase, server)
+
+	books, trades := e.wait(t, 3, 1)
+	if len(books) != 3 {
+		t.Fatalf("got %d books, want 3: %+v", len(books), books)
+	}
+	for _, b := range books {
+		if b.Exchange != Coinbase || b.Symbol != "BTCUSD" {
+			t.Errorf("book from %s %s, want coinbase BTCUSD", b.Exchange, b.Symbol)
+		}
+	}
+	assertTop(t, books[0], 100, 1.5, 101, 1)
+	// the delta removed the 100 bid and added 99.50
+	assertTop(t, books[1], 99.5, 4, 101, 1)
+	if len(books[1].Bids) != 2 {
+		t.Errorf("bids after the delta = %+v, want 99.50 and 99", books[1].Bids)
+	}
+	// the update after the gap was never applied; the resubscribed snapshot brought its level
+	assertTop(t, books[2], 100.5, 9, 101, 1)
+	if got := server.connections(); got != 2 {
+		t.Errorf("got %d connections, want a reconnect after the gap", got)
+	}
+
+	tr := trades[0]
+	if tr.Exchange != Coinbase || tr.Symbol != "BTCUSD" || tr.TradeID != 42 || tr.Price != 101 || tr.Quantity != 0.25 || tr.IsBuyerMaker {
+		t.Errorf("trade = %+v, want taker buy 42 of 0.25 BTCUSD at 101", tr)
+	}
+}
@@ -0,0 +1,143 @@
+package feeds
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/turgaysozen/algotrading/config"
+	"github.com/turgaysozen/algotrading/models"
+	"github.com/turgaysozen/algotrading/monitoring/metrics"
+	"github.com/turgaysozen/algotrading/redisclient"
+)
+
+// Exchange names, as stored in the exchange column and used in MARKET_DATA_FEEDS.
+const (
+	Binance  = "binance"
+	Coinbase = "coinbase"
+	Kraken   = "kraken"
+	Bybit    = "bybit"
+)
+
+// Handler receives normalised events: symbols are upper case without separators
+// (BTC-USD becomes BTCUSD), prices and quantities are floats and books carry the best
+// ORDER_BOOK_DEPTH levels of the adapter's local book.
+type Handler struct {
+	OnOrderBook func(models.OrderBook)
+	OnTrade     func(models.Trade)
+}
+
+// MarketDataFeed streams one exchange's books and trades. Each adapter speaks its
+// exchange's subscription protocol and enforces its checksum and sequence rules,
+// reconnecting for a fresh snapshot when they fail.
+type MarketDataFeed interface {
+	Exchange() string
+	// Run streams events to h until the reconnect policy gives up.
+	Run(h Handler)
+}
+
+// feedStatus reports whether a running feed is connected and forces it to reconnect.
+type feedStatus struct {
+	up        func() bool
+	reconnect func()
+}
+
+var (
+	statusMu sync.Mutex
+	status   = make(map[string]feedStatus)
+)
+
+// New returns the adapter for exchange.
+func New(exchange string) (MarketDataFeed, error) {
+	switch exchange {
+	case Binance:
+		return &binanceFeed{}, nil
+	case Coinbase:
+		return newCoinbaseFeed(), nil
+	case Kraken:
+		return newKrakenFeed(), nil
+	case Bybit:
+		return newBybitFeed(), nil
+	}
+	return nil, fmt.Errorf("unknown market data feed %q", exchange)
+}
+
+// Start runs every feed in the comma separated MARKET_DATA_FEEDS (default binance).
+func Start(h Handler) {
+	for _, name := range strings.Split(config.GetEnv("MARKET_DATA_FEEDS", Binance), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		feed, err := New(name)
+		if err != nil {
+			log.Println("Error starting market data feed:", err)
+			metrics.RecordError("feed_unknown_exchange")
+			continue
+		}
+		go feed.Run(h)
+	}
+}
+
+// Publish is the Handler used by the live service: events go on the Redis bus, and
+// each exchange and symbol's last update is recorded for the staleness monitor.
+var Publish = Handler{
+	OnOrderBook: func(orderBook models.OrderBook) {
+		markUpdated(orderBook.Exchange, orderBook.Symbol)
+		redisclient.Publish("order_book", orderBook)
+	},
+	OnTrade: func(trade models.Trade) {
+		markUpdated(trade.Exchange, trade.Symbol)
+		redisclient.Publish("trades", trade)
+	},
+}
+
+func trackStatus(exchange string, up func() bool, reconnect func()) {
+	statusMu.Lock()
+	status[exchange] = feedStatus{up: up, reconnect: reconnect}
+	statusMu.Unlock()
+}
+
+// reconnect forces exchange's feed to reconnect, if it is running.
+func reconnect(exchange string) {
+	statusMu.Lock()
+	feed, ok := status[exchange]
+	statusMu.Unlock()
+	if ok {
+		feed.reconnect()
+	}
+}
+
+// Down returns the running feeds that currently have no connection up.
+func Down() []string {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+
+	var down []string
+	for exchange, feed := range status {
+		if !feed.up() {
+			down = append(down, exchange)
+		}
+	}
+	sort.Strings(down)
+	return down
+}
+
+// canonicalSymbol turns exchange product names such as BTC-USD or BTC/USD into BTCUSD.
+func canonicalSymbol(product string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", "/", "", "_", "").Replace(product))
+}
+
+// splitList splits a comma separated setting, dropping blanks.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
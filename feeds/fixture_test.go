@@ -0,0 +1,176 @@
+package feeds
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/turgaysozen/algotrading/models"
+)
+
+// fixtureServer is an exchange WebSocket that plays its nth connection the frames of the nth
+// fixture file in testdata, one message per line, and ignores what the adapter sends. A line
+// holding a duration such as 200ms pauses the playback, for adapters that fetch snapshots
+// between frames. Connections past the last fixture stay open without frames.
+type fixtureServer struct {
+	*httptest.Server
+	fixtures [][]byte
+
+	mu    sync.Mutex
+	conns []*websocket.Conn
+}
+
+func newFixtureServer(t *testing.T, fixtures ...string) *fixtureServer {
+	t.Helper()
+	s := &fixtureServer{}
+	for _, name := range fixtures {
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.fixtures = append(s.fixtures, data)
+	}
+
+	upgrader := websocket.Upgrader{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		n := len(s.conns)
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+
+		if n < len(s.fixtures) {
+			lines := bufio.NewScanner(strings.NewReader(string(s.fixtures[n])))
+			for lines.Scan() {
+				if pause, err := time.ParseDuration(lines.Text()); err == nil {
+					time.Sleep(pause)
+					continue
+				}
+				if err := conn.WriteMessage(websocket.TextMessage, lines.Bytes()); err != nil {
+					return
+				}
+			}
+		}
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	return s
+}
+
+func (s *fixtureServer) url() string {
+	return "ws" + strings.TrimPrefix(s.URL, "http")
+}
+
+func (s *fixtureServer) connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+// shutdown closes every connection and the listener, so the adapter's next dial fails.
+func (s *fixtureServer) shutdown() {
+	s.mu.Lock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.Close()
+}
+
+// events collects what an adapter emits.
+type events struct {
+	mu     sync.Mutex
+	books  []models.OrderBook
+	trades []models.Trade
+}
+
+func (e *events) handler() Handler {
+	return Handler{
+		OnOrderBook: func(b models.OrderBook) {
+			e.mu.Lock()
+			e.books = append(e.books, b)
+			e.mu.Unlock()
+		},
+		OnTrade: func(t models.Trade) {
+			e.mu.Lock()
+			e.trades = append(e.trades, t)
+			e.mu.Unlock()
+		},
+	}
+}
+
+// wait returns the books and trades once at least books and trades of them arrived, failing
+// the test after a few seconds.
+func (e *events) wait(t *testing.T, books, trades int) ([]models.OrderBook, []models.Trade) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		e.mu.Lock()
+		b, tr := append([]models.OrderBook(nil), e.books...), append([]models.Trade(nil), e.trades...)
+		e.mu.Unlock()
+		if len(b) >= books && len(tr) >= trades {
+			return b, tr
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d books and %d trades, want %d and %d: %+v %+v", len(b), len(tr), books, trades, b, tr)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// runFeed runs the exchange's adapter against server with a reconnect policy that gives up on
+// the first failed dial, so the adapter stops once the test shuts the server down.
+func runFeed(t *testing.T, exchange string, server *fixtureServer) *events {
+	t.Helper()
+	prefix := strings.ToUpper(exchange)
+	t.Setenv(prefix+"_WS_URL", server.url())
+	t.Setenv(prefix+"_RETRY_INITIAL", "10ms")
+	t.Setenv(prefix+"_RETRY_MAX", "10ms")
+	t.Setenv(prefix+"_RETRY_MAX_ATTEMPTS", "1")
+
+	feed, err := New(exchange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &events{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		feed.Run(e.handler())
+	}()
+
+	t.Cleanup(func() {
+		server.shutdown()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Errorf("%s feed still running after the server shut down", exchange)
+		}
+	})
+	return e
+}
+
+// assertTop checks the best bid and ask of a published book.
+func assertTop(t *testing.T, b models.OrderBook, bid, bidQty, ask, askQty float64) {
+	t.Helper()
+	if len(b.Bids) == 0 || len(b.Asks) == 0 {
+		t.Fatalf("book %+v has an empty side", b)
+	}
+	if b.Bids[0].Price != bid || b.Bids[0].Qty != bidQty || b.Asks[0].Price != ask || b.Asks[0].Qty != askQty {
+		t.Errorf("top of book = %v@%v / %v@%v, want %v@%v / %v@%v", b.Bids[0].Qty, b.Bids[0].Price, b.Asks[0].Qty,
+			b.Asks[0].Price, bidQty, bid, askQty, ask)
+	}
+}
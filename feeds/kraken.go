@@ -0,0 +1,258 @@
+package feeds
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/turgaysozen/algotrading/config"
+	"github.com/turgaysozen/algotrading/models"
+	"github.com/turgaysozen/algotrading/monitoring/metrics"
+	"github.com/turgaysozen/algotrading/orderbook"
+)
+
+// Kraken's v2 book carries no sequence numbers; instead every message has a CRC32 of the
+// top ten levels, which only matches if the local book, truncated to the subscribed depth,
+// equals Kraken's. The checksum formats prices and quantities with the pair's precision,
+// so the book is subscribed once the instrument snapshot has supplied them.
+
+const krakenChecksumLevels = 10
+
+type krakenMessage struct {
+	Channel string          `json:"channel"`
+	Type    string          `json:"type"`
+	Data    json.RawMessage `json:"data"`
+	Method  string          `json:"method"`
+	Success *bool           `json:"success"`
+	Error   string          `json:"error"`
+}
+
+type krakenLevel struct {
+	Price json.Number `json:"price"`
+	Qty   json.Number `json:"qty"`
+}
+
+type krakenBook struct {
+	Symbol    string        `json:"symbol"`
+	Bids      []krakenLevel `json:"bids"`
+	Asks      []krakenLevel `json:"asks"`
+	Checksum  uint32        `json:"checksum"`
+	Timestamp time.Time     `json:"timestamp"`
+}
+
+type krakenTrade struct {
+	Symbol    string      `json:"symbol"`
+	Side      string      `json:"side"`
+	Price     json.Number `json:"price"`
+	Qty       json.Number `json:"qty"`
+	TradeID   int64       `json:"trade_id"`
+	Timestamp time.Time   `json:"timestamp"`
+}
+
+type krakenInstruments struct {
+	Pairs []struct {
+		Symbol         string `json:"symbol"`
+		PricePrecision int    `json:"price_precision"`
+		QtyPrecision   int    `json:"qty_precision"`
+	} `json:"pairs"`
+}
+
+type krakenPrecision struct {
+	price, qty int
+}
+
+type krakenFeed struct {
+	symbols    []string
+	depth      int
+	books      *books
+	precisions map[string]krakenPrecision
+	h          Handler
+}
+
+func newKrakenFeed() *krakenFeed {
+	return &krakenFeed{
+		symbols:    splitList(config.GetEnv("KRAKEN_SYMBOLS", "BTC/USD")),
+		depth:      config.GetEnvInt("KRAKEN_BOOK_DEPTH", 10),
+		books:      newBooks(Kraken),
+		precisions: make(map[string]krakenPrecision),
+	}
+}
+
+func (f *krakenFeed) Exchange() string {
+	return Kraken
+}
+
+func (f *krakenFeed) Run(h Handler) {
+	f.h = h
+	s := &session{
+		exchange:  Kraken,
+		url:       config.GetEnv("KRAKEN_WS_URL", "wss://ws.kraken.com/v2"),
+		subscribe: f.subscribe,
+		handle:    f.handle,
+		ping:      []byte(`{"method":"ping"}`),
+	}
+	s.run()
+}
+
+func (f *krakenFeed) subscribe(s *session) error {
+	f.books.reset()
+	return f.send(s, map[string]interface{}{"channel": "instrument", "snapshot": true})
+}
+
+func (f *krakenFeed) send(s *session, params map[string]interface{}) error {
+	msg, err := json.Marshal(map[string]interface{}{"method": "subscribe", "params": params})
+	if err != nil {
+		return err
+	}
+	return s.send(msg)
+}
+
+func (f *krakenFeed) handle(s *session, data []byte) error {
+	var msg krakenMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		log.Println("Error unmarshalling Kraken message:", err)
+		metrics.RecordError("json_unmarshal_error")
+		return nil
+	}
+
+	if msg.Success != nil && !*msg.Success {
+		log.Printf("Kraken %s request failed: %s", msg.Method, msg.Error)
+		metrics.RecordError("kraken_request_error")
+		return nil
+	}
+
+	switch msg.Channel {
+	case "instrument":
+		if msg.Type == "snapshot" {
+			return f.handleInstruments(s, msg.Data)
+		}
+	case "book":
+		return f.handleBook(msg)
+	case "trade":
+		f.handleTrades(msg)
+	}
+	return nil
+}
+
+// handleInstruments stores the precisions the checksum needs, then subscribes to the book and trades.
+func (f *krakenFeed) handleInstruments(s *session, data json.RawMessage) error {
+	var instruments krakenInstruments
+	if err := json.Unmarshal(data, &instruments); err != nil {
+		log.Println("Error unmarshalling Kraken instruments:", err)
+		metrics.RecordError("json_unmarshal_error")
+		return err
+	}
+	for _, pair := range instruments.Pairs {
+		f.precisions[pair.Symbol] = krakenPrecision{price: pair.PricePrecision, qty: pair.QtyPrecision}
+	}
+
+	if err := f.send(s, map[string]interface{}{"channel": "book", "symbol": f.symbols, "depth": f.depth}); err != nil {
+		return err
+	}
+	return f.send(s, map[string]interface{}{"channel": "trade", "symbol": f.symbols, "snapshot": false})
+}
+
+func (f *krakenFeed) handleBook(msg krakenMessage) error {
+	var updates []krakenBook
+	if err := json.Unmarshal(msg.Data, &updates); err != nil {
+		log.Println("Error unmarshalling Kraken book:", err)
+		metrics.RecordError("json_unmarshal_error")
+		return errResync
+	}
+
+	for _, u := range updates {
+		bids, err := krakenLevels(u.Bids)
+		if err != nil {
+			return errResync
+		}
+		asks, err := krakenLevels(u.Asks)
+		if err != nil {
+			return errResync
+		}
+
+		symbol := canonicalSymbol(u.Symbol)
+		book := f.books.get(symbol)
+		if msg.Type == "snapshot" {
+			book.LoadSnapshot(orderbook.Snapshot{Bids: bids, Asks: asks})
+		} else if err := book.Update(bids, asks); err != nil {
+			continue
+		}
+		book.Trim(f.depth)
+
+		precision, ok := f.precisions[u.Symbol]
+		if ok {
+			if sum := krakenChecksum(book, precision); sum != u.Checksum {
+				metrics.RecordDataLoss("kraken_checksum_mismatch")
+				return fmt.Errorf("%w: %s checksum %d, expected %d", errResync, u.Symbol, sum, u.Checksum)
+			}
+		}
+		f.books.emit(f.h, symbol, u.Timestamp.UnixMilli())
+	}
+	return nil
+}
+
+func (f *krakenFeed) handleTrades(msg krakenMessage) {
+	var trades []krakenTrade
+	if err := json.Unmarshal(msg.Data, &trades); err != nil {
+		log.Println("Error unmarshalling Kraken trades:", err)
+		metrics.RecordError("json_unmarshal_error")
+		return
+	}
+
+	for _, t := range trades {
+		level, err := parseLevel(t.Price.String(), t.Qty.String())
+		if err != nil {
+			metrics.RecordTickRejection(models.RejectMalformedPrice)
+			continue
+		}
+		f.h.OnTrade(models.Trade{
+			Exchange:  Kraken,
+			EventType: "trade",
+			Symbol:    canonicalSymbol(t.Symbol),
+			EventTime: t.Timestamp.UnixMilli(),
+			TradeID:   t.TradeID,
+			Price:     level.Price,
+			Quantity:  level.Qty,
+			TradeTime: t.Timestamp.UnixMilli(),
+			// side is the taker's side
+			IsBuyerMaker: t.Side == "sell",
+		})
+	}
+}
+
+func krakenLevels(levels []krakenLevel) ([]models.PriceLevel, error) {
+	parsed := make([]models.PriceLevel, 0, len(levels))
+	for _, l := range levels {
+		level, err := parseLevel(l.Price.String(), l.Qty.String())
+		if err != nil {
+			metrics.RecordTickRejection(models.RejectMalformedPrice)
+			return nil, err
+		}
+		parsed = append(parsed, level)
+	}
+	return parsed, nil
+}
+
+// krakenChecksum is the CRC32 of the top ten asks then bids, each price and quantity
+// written with the pair's precision, without the decimal point and leading zeros.
+func krakenChecksum(book *orderbook.Book, precision krakenPrecision) uint32 {
+	bids, asks := book.Top(krakenChecksumLevels)
+
+	var b strings.Builder
+	for _, side := range [][]models.PriceLevel{asks, bids} {
+		for _, l := range side {
+			b.WriteString(krakenChecksumField(l.Price, precision.price))
+			b.WriteString(krakenChecksumField(l.Qty, precision.qty))
+		}
+	}
+	return crc32.ChecksumIEEE([]byte(b.String()))
+}
+
+func krakenChecksumField(value float64, decimals int) string {
+	s := strings.Replace(strconv.FormatFloat(value, 'f', decimals, 64), ".", "", 1)
+	return strings.TrimLeft(s, "0")
+}
@@ -0,0 +1,55 @@
+package feeds
+
+import (
+	"testing"
+
+	"github.com/turgaysozen/algotrading/orderbook"
+)
+
+// The first connection delivers the instruments, a snapshot and a delta with valid checksums,
+// a trade, then a delta whose checksum doesn't match, which must drop the connection; the
+// second connection's snapshot rebuilds the book.
+func TestKrakenChecksumMismatchResyncs(t *testing.T) {
+	server := newFixtureServer(t, "kraken_checksum.jsonl", "kraken_resync.jsonl")
+	e := runFeed(t, Kraken, server)
+
+	books, trades := e.wait(t, 3, 1)
+	if len(books) != 3 {
+		t.Fatalf("got %d books, want 3: %+v", len(books), books)
+	}
+	assertTop(t, books[0], 100, 1.5, 101, 1)
+	assertTop(t, books[1], 99.5, 4, 101, 1)
+	// the mismatched delta was never published; the resubscribed snapshot brought its level
+	assertTop(t, books[2], 100.5, 9, 101, 1)
+	if got := server.connections(); got != 2 {
+		t.Errorf("got %d connections, want a reconnect after the checksum mismatch", got)
+	}
+
+	tr := trades[0]
+	if tr.Exchange != Kraken || tr.Symbol != "BTCUSD" || tr.TradeID != 7 || tr.Price != 99.5 || !tr.IsBuyerMaker {
+		t.Errorf("trade = %+v, want taker sell 7 of BTCUSD at 99.5", tr)
+	}
+}
+
+// Kraken's documented example: prices and quantities without the decimal point and leading zeros.
+func TestKrakenChecksumField(t *testing.T) {
+	for _, tc := range []struct {
+		value    float64
+		decimals int
+		want     string
+	}{
+		{45285.2, 1, "452852"},
+		{0.00100000, 8, "100000"},
+		{0.5, 8, "50000000"},
+	} {
+		if got := krakenChecksumField(tc.value, tc.decimals); got != tc.want {
+			t.Errorf("krakenChecksumField(%v, %d) = %s, want %s", tc.value, tc.decimals, got, tc.want)
+		}
+	}
+
+	book := orderbook.New()
+	book.LoadSnapshot(orderbook.Snapshot{})
+	if got := krakenChecksum(book, krakenPrecision{price: 1, qty: 8}); got != 0 {
+		t.Errorf("checksum of an empty book = %d, want 0", got)
+	}
+}
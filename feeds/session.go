@@ -0,0 +1,162 @@
+package feeds
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/turgaysozen/algotrading/config"
+	"github.com/turgaysozen/algotrading/monitoring/metrics"
+	"github.com/turgaysozen/algotrading/retry"
+)
+
+const writeWait = 10 * time.Second
+
+// errResync makes a session drop its connection; resubscribing on the new one delivers fresh snapshots.
+var errResync = errors.New("book out of sync")
+
+// session is one exchange connection with the shared reconnect policy (<EXCHANGE>_RETRY_*),
+// keepalive pings and a read deadline, like the Binance client. subscribe runs after every
+// connect, and an error from handle reconnects.
+type session struct {
+	exchange  string
+	url       string
+	subscribe func(s *session) error
+	handle    func(s *session, msg []byte) error
+	// ping is sent as a text message for exchanges that want application level pings
+	ping []byte
+
+	mu   sync.Mutex
+	conn *websocket.Conn
+	up   bool
+}
+
+func (s *session) run() {
+	trackStatus(s.exchange, s.connected, s.reconnect)
+	policy := retry.LoadPolicy(strings.ToUpper(s.exchange))
+
+	failures := 0
+	for {
+		err := retry.Do(s.exchange, policy, func() error {
+			conn, _, err := websocket.DefaultDialer.Dial(s.url, nil)
+			if err != nil {
+				metrics.RecordError(s.exchange + "_connection_error")
+				return err
+			}
+			s.setConn(conn)
+
+			if err := s.subscribe(s); err != nil {
+				metrics.RecordError(s.exchange + "_subscribe_error")
+				conn.Close()
+				return err
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("Error connecting %s feed: %v", s.exchange, err)
+			return
+		}
+
+		log.Printf("%s feed connected to: %s", s.exchange, s.url)
+		s.setUp(true)
+		connected := time.Now()
+
+		err = s.read()
+		s.setUp(false)
+		metrics.SetConnectionUp(s.exchange, false)
+		log.Printf("Error reading %s feed, reconnecting: %v", s.exchange, err)
+		metrics.RecordError(s.exchange + "_feed_error")
+		s.conn.Close()
+
+		// connections that keep failing soon after connecting back off like failed dials
+		if time.Since(connected) > policy.Max {
+			failures = 0
+		}
+		failures++
+		time.Sleep(policy.Backoff(failures))
+	}
+}
+
+func (s *session) read() error {
+	conn := s.conn
+	timeout := config.GetEnvDuration("WEB_SOCKET_READ_TIMEOUT", time.Minute)
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(timeout))
+	})
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go s.keepAlive(stop)
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		if err := s.handle(s, msg); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *session) keepAlive(stop <-chan struct{}) {
+	ticker := time.NewTicker(config.GetEnvDuration("WEB_SOCKET_PING_INTERVAL", 20*time.Second))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			var err error
+			if s.ping != nil {
+				err = s.send(s.ping)
+			} else {
+				err = s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait))
+			}
+			if err != nil {
+				// the read loop notices the broken connection
+				return
+			}
+		}
+	}
+}
+
+// send writes a text message; gorilla connections allow only one writer at a time.
+func (s *session) send(msg []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return s.conn.WriteMessage(websocket.TextMessage, msg)
+}
+
+// reconnect closes the current connection; the read loop fails, reconnects and resubscribes.
+func (s *session) reconnect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != nil {
+		s.conn.Close()
+	}
+}
+
+func (s *session) setConn(conn *websocket.Conn) {
+	s.mu.Lock()
+	s.conn = conn
+	s.mu.Unlock()
+}
+
+func (s *session) setUp(up bool) {
+	s.mu.Lock()
+	s.up = up
+	s.mu.Unlock()
+}
+
+func (s *session) connected() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.up
+}
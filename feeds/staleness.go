@@ -0,0 +1,120 @@
+package feeds
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/turgaysozen/algotrading/config"
+	"github.com/turgaysozen/algotrading/monitoring/metrics"
+)
+
+// A connection can look healthy while one symbol silently stops updating, so every
+// exchange and symbol's last published update is tracked and a required one going quiet
+// for longer than FEED_STALE_AFTER forces that exchange's feed to reconnect, which
+// reloads its books from fresh snapshots.
+
+var (
+	updatesMu      sync.Mutex
+	lastUpdates    = make(map[string]time.Time)
+	requiredFeeds  []string
+	staleAfter     time.Duration
+	monitorStarted time.Time
+	monitorOnce    sync.Once
+)
+
+// feedKey is exchange:SYMBOL, the form used in FEED_REQUIRED_SYMBOLS.
+func feedKey(exchange, symbol string) string {
+	return strings.ToLower(exchange) + ":" + strings.ToUpper(symbol)
+}
+
+func markUpdated(exchange, symbol string) {
+	if exchange == "" || symbol == "" {
+		return
+	}
+	updatesMu.Lock()
+	lastUpdates[feedKey(exchange, symbol)] = time.Now()
+	updatesMu.Unlock()
+}
+
+// StartStalenessMonitor exports feed_last_update_age_seconds and reconnects a feed when
+// one of its entries in FEED_REQUIRED_SYMBOLS has not updated within FEED_STALE_AFTER.
+// Entries are exchange:symbol; a bare symbol means Binance.
+func StartStalenessMonitor() {
+	monitorOnce.Do(func() {
+		staleAfter = config.GetEnvDuration("FEED_STALE_AFTER", 30*time.Second)
+		for _, entry := range splitList(config.GetEnv("FEED_REQUIRED_SYMBOLS", "")) {
+			exchange, symbol, ok := strings.Cut(entry, ":")
+			if !ok {
+				exchange, symbol = Binance, entry
+			}
+			requiredFeeds = append(requiredFeeds, feedKey(exchange, symbol))
+		}
+		monitorStarted = time.Now()
+
+		go func() {
+			for range time.Tick(time.Second) {
+				checkStaleness()
+			}
+		}()
+	})
+}
+
+func checkStaleness() {
+	now := time.Now()
+
+	updatesMu.Lock()
+	for key, last := range lastUpdates {
+		exchange, symbol, _ := strings.Cut(key, ":")
+		metrics.SetFeedLastUpdateAge(exchange, symbol, now.Sub(last).Seconds())
+	}
+	updatesMu.Unlock()
+
+	stale := Stale()
+	if len(stale) == 0 {
+		return
+	}
+
+	log.Printf("Feed stale for %v (no update for %s), reconnecting", stale, staleAfter)
+	metrics.RecordError("feed_stale_reconnect")
+
+	// give the new connection a full threshold to deliver before judging it
+	exchanges := make(map[string]bool)
+	updatesMu.Lock()
+	for _, key := range stale {
+		lastUpdates[key] = now
+		exchange, _, _ := strings.Cut(key, ":")
+		exchanges[exchange] = true
+	}
+	updatesMu.Unlock()
+
+	for exchange := range exchanges {
+		reconnect(exchange)
+	}
+}
+
+// Stale returns the required exchange:symbol entries that have not updated within the
+// staleness threshold. One that never updated is measured from when the monitor started.
+func Stale() []string {
+	if staleAfter == 0 {
+		return nil
+	}
+
+	updatesMu.Lock()
+	defer updatesMu.Unlock()
+
+	var stale []string
+	for _, key := range requiredFeeds {
+		last, ok := lastUpdates[key]
+		if !ok {
+			last = monitorStarted
+		}
+		if time.Since(last) > staleAfter {
+			stale = append(stale, key)
+		}
+	}
+	sort.Strings(stale)
+	return stale
+}
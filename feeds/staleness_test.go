@@ -0,0 +1,39 @@
+package feeds
+
+import (
+	"testing"
+	"time"
+)
+
+// A required Coinbase symbol that stopped updating makes the Coinbase session reconnect, and
+// its resubscribed snapshot counts as a fresh update.
+func TestStaleSymbolReconnectsItsFeed(t *testing.T) {
+	server := newFixtureServer(t, "coinbase_resync.jsonl", "coinbase_resync.jsonl")
+	e := runFeed(t, Coinbase, server)
+	e.wait(t, 1, 0)
+
+	updatesMu.Lock()
+	requiredFeeds = []string{feedKey(Coinbase, "BTCUSD")}
+	staleAfter = time.Second
+	lastUpdates[feedKey(Coinbase, "BTCUSD")] = time.Now().Add(-time.Minute)
+	updatesMu.Unlock()
+	t.Cleanup(func() {
+		updatesMu.Lock()
+		requiredFeeds, staleAfter = nil, 0
+		clear(lastUpdates)
+		updatesMu.Unlock()
+	})
+
+	if got := Stale(); len(got) != 1 || got[0] != "coinbase:BTCUSD" {
+		t.Fatalf("Stale() = %v, want coinbase:BTCUSD", got)
+	}
+	checkStaleness()
+
+	e.wait(t, 2, 0)
+	if got := server.connections(); got != 2 {
+		t.Errorf("got %d connections, want a reconnect for the stale symbol", got)
+	}
+	if got := Stale(); len(got) != 0 {
+		t.Errorf("Stale() = %v right after the reconnect, want none", got)
+	}
+}
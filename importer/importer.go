@@ -0,0 +1,313 @@
+package importer
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/turgaysozen/algotrading/monitoring/metrics"
+)
+
+const (
+	batchSize     = 50_000
+	progressEvery = 500_000
+)
+
+// Binance public data files are named SYMBOL-KIND-YYYY-MM[-DD].zip, where KIND is
+// a kline interval (1m, 1h, ...), "aggTrades", "trades" or "bookTicker".
+var fileNamePattern = regexp.MustCompile(`^([A-Z0-9]+)-([A-Za-z0-9]+)-(\d{4}-\d{2}(?:-\d{2})?)\.(zip|csv)$`)
+
+type dataFile struct {
+	path   string
+	symbol string
+	kind   string
+	start  time.Time
+	end    time.Time // exclusive
+}
+
+type options struct {
+	symbols map[string]bool
+	kinds   map[string]bool
+	from    time.Time
+	to      time.Time
+}
+
+// Run implements the "import" subcommand:
+//
+//	algotrading import [-symbol BTCUSDT,ETHUSDT] [-type klines,aggTrades,trades,bookTicker] [-from 2024-01-01] [-to 2024-01-31] PATH...
+//
+// PATH may be a file or a directory, which is walked recursively.
+func Run(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	symbols := flags.String("symbol", "", "comma separated symbols to import (default: all)")
+	kinds := flags.String("type", "", "comma separated data types: klines, aggTrades, trades, bookTicker (default: all)")
+	from := flags.String("from", "", "first date to import, YYYY-MM-DD")
+	to := flags.String("to", "", "last date to import, YYYY-MM-DD")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return errors.New("import: no input files or directories given")
+	}
+
+	opts := options{symbols: splitSet(strings.ToUpper(*symbols)), kinds: splitSet(*kinds)}
+	if *from != "" {
+		t, err := time.Parse("2006-01-02", *from)
+		if err != nil {
+			return fmt.Errorf("import: invalid -from: %w", err)
+		}
+		opts.from = t
+	}
+	if *to != "" {
+		t, err := time.Parse("2006-01-02", *to)
+		if err != nil {
+			return fmt.Errorf("import: invalid -to: %w", err)
+		}
+		opts.to = t.AddDate(0, 0, 1)
+	}
+
+	files, err := findFiles(flags.Args(), opts)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return errors.New("import: no files match the selection")
+	}
+
+	started := time.Now()
+	var total int64
+	for i, f := range files {
+		log.Printf("Importing [%d/%d] %s", i+1, len(files), f.path)
+		rows, err := importFile(f, opts)
+		total += rows
+		if err != nil {
+			metrics.RecordError("import_file_error")
+			return fmt.Errorf("import %s: %w", f.path, err)
+		}
+		log.Printf("Imported [%d/%d] %s: %d rows", i+1, len(files), filepath.Base(f.path), rows)
+	}
+
+	log.Printf("Import finished: %d files, %d rows in %s", len(files), total, time.Since(started).Round(time.Second))
+	return nil
+}
+
+func splitSet(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			set[v] = true
+		}
+	}
+	return set
+}
+
+func findFiles(paths []string, opts options) ([]dataFile, error) {
+	var files []dataFile
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			f, ok := parseFileName(path)
+			if !ok {
+				return nil
+			}
+			if opts.selects(f) {
+				files = append(files, f)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// oldest first, so partially imported ranges are contiguous if the import stops
+	sort.Slice(files, func(i, j int) bool {
+		if !files[i].start.Equal(files[j].start) {
+			return files[i].start.Before(files[j].start)
+		}
+		return files[i].path < files[j].path
+	})
+	return files, nil
+}
+
+func parseFileName(path string) (dataFile, bool) {
+	m := fileNamePattern.FindStringSubmatch(filepath.Base(path))
+	if m == nil {
+		return dataFile{}, false
+	}
+
+	f := dataFile{path: path, symbol: m[1], kind: m[2]}
+	if len(m[3]) == len("2006-01") {
+		start, err := time.Parse("2006-01", m[3])
+		if err != nil {
+			return dataFile{}, false
+		}
+		f.start, f.end = start, start.AddDate(0, 1, 0)
+	} else {
+		start, err := time.Parse("2006-01-02", m[3])
+		if err != nil {
+			return dataFile{}, false
+		}
+		f.start, f.end = start, start.AddDate(0, 0, 1)
+	}
+	return f, true
+}
+
+// covers reports whether a row at t lies within -from and -to; a monthly or daily file can
+// straddle either.
+func (o options) covers(t time.Time) bool {
+	return (o.from.IsZero() || !t.Before(o.from)) && (o.to.IsZero() || t.Before(o.to))
+}
+
+func (o options) selects(f dataFile) bool {
+	if len(o.symbols) > 0 && !o.symbols[f.symbol] {
+		return false
+	}
+	if len(o.kinds) > 0 && !o.kinds[dataType(f.kind)] {
+		return false
+	}
+	if !o.from.IsZero() && !f.end.After(o.from) {
+		return false
+	}
+	if !o.to.IsZero() && !f.start.Before(o.to) {
+		return false
+	}
+	return true
+}
+
+func dataType(kind string) string {
+	switch kind {
+	case "aggTrades", "trades", "bookTicker":
+		return kind
+	}
+	return "klines"
+}
+
+func importFile(f dataFile, opts options) (int64, error) {
+	loader, err := newLoader(f)
+	if err != nil {
+		return 0, err
+	}
+
+	if strings.HasSuffix(f.path, ".csv") {
+		file, err := os.Open(f.path)
+		if err != nil {
+			return 0, err
+		}
+		defer file.Close()
+		return loadCSV(file, f, loader, opts)
+	}
+
+	archive, err := zip.OpenReader(f.path)
+	if err != nil {
+		return 0, err
+	}
+	defer archive.Close()
+
+	var total int64
+	for _, entry := range archive.File {
+		if !strings.HasSuffix(entry.Name, ".csv") {
+			continue
+		}
+		r, err := entry.Open()
+		if err != nil {
+			return total, err
+		}
+		rows, err := loadCSV(r, f, loader, opts)
+		r.Close()
+		total += rows
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// loadCSV streams the records opts covers into the loader in batches, so memory stays bounded
+// regardless of file size.
+func loadCSV(r io.Reader, f dataFile, l *loader, opts options) (int64, error) {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+	reader.FieldsPerRecord = -1
+
+	started := time.Now()
+	var read, written, outside int64
+	batch := make([][]interface{}, 0, batchSize)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		n, err := l.flush(batch)
+		written += n
+		batch = batch[:0]
+		return err
+	}
+
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return written, err
+		}
+		// newer dumps start with a header row
+		if line == 1 && isHeader(record) {
+			continue
+		}
+
+		row, at, err := l.convert(record)
+		if err != nil {
+			metrics.RecordDataLoss("import_row_data_loss")
+			return written, fmt.Errorf("line %d: %w", line, err)
+		}
+		if !opts.covers(at) {
+			outside++
+			continue
+		}
+		batch = append(batch, row)
+		read++
+
+		if len(batch) == batchSize {
+			if err := flush(); err != nil {
+				return written, err
+			}
+		}
+		if read%progressEvery == 0 {
+			log.Printf("  %s: %d rows read (%.0f rows/s)", filepath.Base(f.path), read, float64(read)/time.Since(started).Seconds())
+		}
+	}
+
+	if err := flush(); err != nil {
+		return written, err
+	}
+	if outside > 0 {
+		log.Printf("  %s: %d rows outside -from/-to, skipped", filepath.Base(f.path), outside)
+	}
+	if skipped := read - written; skipped > 0 {
+		log.Printf("  %s: %d rows already imported, skipped", filepath.Base(f.path), skipped)
+	}
+	return written, nil
+}
+
+func isHeader(record []string) bool {
+	if len(record) == 0 || record[0] == "" {
+		return false
+	}
+	c := record[0][0]
+	return c < '0' || c > '9'
+}
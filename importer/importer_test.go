@@ -0,0 +1,46 @@
+package importer
+
+import (
+	"testing"
+	"time"
+)
+
+// A monthly file selected by -from 2024-01-15 -to 2024-01-15 still holds the rest of January,
+// so rows are selected on their own time.
+func TestRowsOutsideFromToAreSkipped(t *testing.T) {
+	day := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	opts := options{from: day, to: day.AddDate(0, 0, 1)}
+
+	tests := []struct {
+		name   string
+		loader *loader
+		record []string
+		want   bool
+	}{
+		{"kline before -from", klineLoader("BTCUSDT", "1h"),
+			[]string{"1705273200000", "1", "1", "1", "1", "1", "1705276799999", "1", "1"}, false},
+		{"kline on the first hour", klineLoader("BTCUSDT", "1h"),
+			[]string{"1705276800000", "1", "1", "1", "1", "1", "1705280399999", "1", "1"}, true},
+		{"trade on the last millisecond", tradeLoader("BTCUSDT", "trade"),
+			[]string{"1", "1", "1", "1", "1705363199999", "true"}, true},
+		{"aggTrade after -to", tradeLoader("BTCUSDT", "aggTrade"),
+			[]string{"1", "1", "1", "1", "1", "1705363200000", "false"}, false},
+		{"bookTicker inside", bookTickerLoader("BTCUSDT"),
+			[]string{"1", "100", "1", "101", "1", "1705320000000", "1705320000000"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, at, err := tt.loader.convert(tt.record)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := opts.covers(at); got != tt.want {
+				t.Errorf("row at %s covered = %v, want %v", at.UTC(), got, tt.want)
+			}
+		})
+	}
+
+	if !(options{}).covers(day) {
+		t.Error("rows are skipped without -from and -to")
+	}
+}
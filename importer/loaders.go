@@ -0,0 +1,177 @@
+package importer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/turgaysozen/algotrading/db"
+	"github.com/turgaysozen/algotrading/models"
+	"github.com/turgaysozen/algotrading/services"
+	"github.com/turgaysozen/algotrading/utils"
+)
+
+// loader maps one Binance CSV layout onto one of our hypertables. convert returns the row
+// with the time it happened at, which -from and -to select on.
+type loader struct {
+	table      string
+	columns    []string
+	onConflict string
+	convert    func(record []string) ([]interface{}, time.Time, error)
+}
+
+func (l *loader) flush(rows [][]interface{}) (int64, error) {
+	return db.BulkUpsert(l.table, l.columns, l.onConflict, rows)
+}
+
+func newLoader(f dataFile) (*loader, error) {
+	switch dataType(f.kind) {
+	case "klines":
+		return klineLoader(f.symbol, f.kind), nil
+	case "aggTrades":
+		return tradeLoader(f.symbol, "aggTrade"), nil
+	case "trades":
+		return tradeLoader(f.symbol, "trade"), nil
+	case "bookTicker":
+		return bookTickerLoader(f.symbol), nil
+	}
+	return nil, fmt.Errorf("unsupported data type %q", f.kind)
+}
+
+// klines: open_time, open, high, low, close, volume, close_time, quote_volume, count, ...
+func klineLoader(symbol, interval string) *loader {
+	return &loader{
+		table:   "bars",
+		columns: []string{"symbol", "timeframe", "open_time", "close_time", "open", "high", "low", "close", "volume", "trade_count", "tick_count"},
+		onConflict: `ON CONFLICT (symbol, timeframe, open_time) DO UPDATE SET
+			close_time = EXCLUDED.close_time,
+			open = EXCLUDED.open,
+			high = EXCLUDED.high,
+			low = EXCLUDED.low,
+			close = EXCLUDED.close,
+			volume = EXCLUDED.volume,
+			trade_count = EXCLUDED.trade_count`,
+		convert: func(r []string) ([]interface{}, time.Time, error) {
+			if len(r) < 9 {
+				return nil, time.Time{}, fmt.Errorf("kline has %d fields, want at least 9", len(r))
+			}
+			p, err := parseFloats(r[1], r[2], r[3], r[4], r[5])
+			if err != nil {
+				return nil, time.Time{}, err
+			}
+			openTime, err := parseTimestamp(r[0])
+			if err != nil {
+				return nil, time.Time{}, err
+			}
+			closeTime, err := parseTimestamp(r[6])
+			if err != nil {
+				return nil, time.Time{}, err
+			}
+			count, err := strconv.Atoi(r[8])
+			if err != nil {
+				return nil, time.Time{}, err
+			}
+			// Binance close times are inclusive; bars store an exclusive end
+			return []interface{}{symbol, interval, time.UnixMilli(openTime), time.UnixMilli(closeTime + 1),
+				p[0], p[1], p[2], p[3], p[4], count, 0}, time.UnixMilli(openTime), nil
+		},
+	}
+}
+
+// aggTrades: agg_trade_id, price, quantity, first_trade_id, last_trade_id, transact_time, is_buyer_maker, ...
+// trades:    id, price, qty, quote_qty, time, is_buyer_maker, ...
+func tradeLoader(symbol, eventType string) *loader {
+	timeField, makerField := 5, 6
+	if eventType == "trade" {
+		timeField, makerField = 4, 5
+	}
+
+	return &loader{
+		table:      "trades",
+		columns:    []string{"symbol", "event_type", "trade_id", "price", "quantity", "is_buyer_maker", "event_time", "trade_time"},
+		onConflict: `ON CONFLICT (exchange, symbol, event_type, trade_id, trade_time) DO NOTHING`,
+		convert: func(r []string) ([]interface{}, time.Time, error) {
+			if len(r) <= makerField {
+				return nil, time.Time{}, fmt.Errorf("%s has %d fields, want at least %d", eventType, len(r), makerField+1)
+			}
+			id, err := strconv.ParseInt(r[0], 10, 64)
+			if err != nil {
+				return nil, time.Time{}, err
+			}
+			p, err := parseFloats(r[1], r[2])
+			if err != nil {
+				return nil, time.Time{}, err
+			}
+			tradeTime, err := parseTimestamp(r[timeField])
+			if err != nil {
+				return nil, time.Time{}, err
+			}
+			isBuyerMaker, err := strconv.ParseBool(strings.ToLower(r[makerField]))
+			if err != nil {
+				return nil, time.Time{}, err
+			}
+			return []interface{}{symbol, eventType, id, p[0], p[1], isBuyerMaker, tradeTime, time.UnixMilli(tradeTime)},
+				time.UnixMilli(tradeTime), nil
+		},
+	}
+}
+
+// bookTicker: update_id, best_bid_price, best_bid_qty, best_ask_price, best_ask_qty, transaction_time, event_time
+func bookTickerLoader(symbol string) *loader {
+	depthBps := services.LoadMicrostructureConfig().DepthBps
+
+	return &loader{
+		table: "order_books",
+		columns: []string{"event_type", "symbol", "event_time", "update_id", "best_bid", "best_ask",
+			"imbalance", "microprice", "spread_bps", "bid_depth", "ask_depth"},
+		onConflict: `ON CONFLICT (exchange, symbol, event_type, update_id, event_time) DO NOTHING`,
+		convert: func(r []string) ([]interface{}, time.Time, error) {
+			if len(r) < 7 {
+				return nil, time.Time{}, fmt.Errorf("bookTicker has %d fields, want 7", len(r))
+			}
+			updateID, err := strconv.ParseInt(r[0], 10, 64)
+			if err != nil {
+				return nil, time.Time{}, err
+			}
+			p, err := parseFloats(r[1], r[2], r[3], r[4])
+			if err != nil {
+				return nil, time.Time{}, err
+			}
+			eventTime, err := parseTimestamp(r[6])
+			if err != nil {
+				return nil, time.Time{}, err
+			}
+
+			bids := []models.PriceLevel{{Price: p[0], Qty: p[1]}}
+			asks := []models.PriceLevel{{Price: p[2], Qty: p[3]}}
+			m := services.ComputeMicrostructure(bids, asks, p[0], p[2], 1, depthBps)
+			return []interface{}{"bookTicker", symbol, eventTime, updateID, p[0], p[2],
+				m.Imbalance, m.Microprice, m.SpreadBps, m.BidDepth, m.AskDepth}, time.UnixMilli(eventTime), nil
+		},
+	}
+}
+
+func parseFloats(fields ...string) ([]float64, error) {
+	values := make([]float64, len(fields))
+	for i, f := range fields {
+		v, err := utils.StringToFloat64(f)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// parseTimestamp returns milliseconds; spot dumps switched to microseconds in 2025.
+func parseTimestamp(s string) (int64, error) {
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if v > 1e15 {
+		v /= 1000
+	}
+	return v, nil
+}
@@ -3,14 +3,27 @@ package main
 import (
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/turgaysozen/algotrading/account"
+	"github.com/turgaysozen/algotrading/bars"
+	"github.com/turgaysozen/algotrading/config"
 	"github.com/turgaysozen/algotrading/db"
+	"github.com/turgaysozen/algotrading/execution"
+	"github.com/turgaysozen/algotrading/feeds"
+	"github.com/turgaysozen/algotrading/importer"
 	"github.com/turgaysozen/algotrading/monitoring"
 	"github.com/turgaysozen/algotrading/monitoring/metrics"
+	"github.com/turgaysozen/algotrading/portfolio"
+	"github.com/turgaysozen/algotrading/recorder"
 	"github.com/turgaysozen/algotrading/redisclient"
+	"github.com/turgaysozen/algotrading/retry"
+	"github.com/turgaysozen/algotrading/services"
 	"github.com/turgaysozen/algotrading/wsclient"
 )
 
@@ -24,25 +37,38 @@ func init() {
 }
 
 func main() {
-	_, err := db.InitializeDB()
-	if err != nil {
-		log.Fatal("Database initialization failed:", err)
-	}
-
-	redisclient.InitRedisClient()
-
-	conn, err := wsclient.ConnectWebSocket()
-	if err != nil {
-		log.Fatal("Error connecting to WebSocket:", err)
+	if len(os.Args) > 1 {
+		var err error
+		switch os.Args[1] {
+		case "import":
+			if _, err := db.InitializeDB(); err != nil {
+				log.Fatal("Database initialization failed:", err)
+			}
+			err = importer.Run(os.Args[2:])
+		case "replay":
+			err = runReplay(os.Args[2:])
+		default:
+			log.Fatalf("Unknown command %q, expected import or replay", os.Args[1])
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
-	defer conn.Close()
 
+	// serve metrics and readiness first, so outages below are visible instead of fatal
 	go func() {
 		http.Handle("/metrics", promhttp.Handler())
 		http.HandleFunc("/healthz", monitoring.LivenessHandler)
 		http.HandleFunc("/readiness", monitoring.ReadinessHandler)
+		http.HandleFunc("/execution/orders", execution.OrdersHandler)
+		http.HandleFunc("/execution/limit-orders", execution.LimitOrdersHandler)
+		http.HandleFunc("/account/balances", account.BalancesHandler)
+		http.HandleFunc("/reports/strategies", services.StrategyPnLHandler)
+		http.HandleFunc("/portfolio", portfolio.Handler)
+		http.HandleFunc("/portfolio/risk", portfolio.RiskHandler)
 
-		log.Println("Client metrics and health checks available at http://localhost:8080/metrics, /healthz, /readiness")
+		log.Println("Client metrics and health checks available at http://localhost:8080/metrics, /healthz, /readiness, /execution/orders, /execution/limit-orders, /account/balances, /reports/strategies, /portfolio, /portfolio/risk")
 		log.Fatal(http.ListenAndServe(":8080", nil))
 	}()
 
@@ -53,7 +79,59 @@ func main() {
 		}
 	}()
 
-	go wsclient.ProcessWebSocketMessages(conn)
+	go func() {
+		for {
+			metrics.SetConnectionUp("postgres", db.Ping() == nil)
+			redisclient.RedisHealth()
+			time.Sleep(10 * time.Second)
+		}
+	}()
+
+	// the pipeline needs the database; readiness reports it unreachable until this succeeds
+	policy := retry.LoadPolicy("DB")
+	for attempt := 1; ; attempt++ {
+		if _, err := db.InitializeDB(); err == nil {
+			break
+		}
+		delay := policy.Backoff(attempt)
+		log.Printf("Database initialization failed, retrying in %s before starting the pipeline", delay.Round(time.Millisecond))
+		metrics.RecordError("db_initialization_error")
+		time.Sleep(delay)
+	}
+
+	redisclient.InitRedisClient()
+
+	bars.Init()
+	execution.Init()
+	account.Init()
+	portfolio.Init()
+	services.RecoverOrders()
+	services.InitStrategy()
+
+	if dir := os.Getenv("FEED_RECORD_DIR"); dir != "" {
+		maxBytes := int64(config.GetEnvInt("FEED_RECORD_MAX_MB", 100)) << 20
+		err := recorder.Start(dir, maxBytes, config.GetEnvDuration("FEED_RECORD_ROTATE", time.Hour))
+		if err != nil {
+			log.Println("Error starting feed recorder:", err)
+			metrics.RecordError("recorder_start_error")
+		}
+
+		// close the current recording cleanly on shutdown
+		go func() {
+			stop := make(chan os.Signal, 1)
+			signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+			<-stop
+			recorder.Stop()
+			os.Exit(0)
+		}()
+	}
+
+	if url := os.Getenv("DEPTH_SNAPSHOT_URL"); url != "" {
+		wsclient.UseLocalBooks(url)
+	}
+
+	feeds.StartStalenessMonitor()
+	feeds.Start(feeds.Publish)
 
 	go redisclient.Subscribe()
 
@@ -1,25 +1,266 @@
 package models
 
+import "github.com/turgaysozen/algotrading/decimal"
+
+// DefaultExchange is assumed for events that don't name their exchange, such as raw Binance frames.
+const DefaultExchange = "binance"
+
 type OrderBook struct {
-	EventType string          `json:"e"`
-	Symbol    string          `json:"s"`
-	EventTime int64           `json:"E"`
-	Bids      [][]interface{} `json:"b"`
-	Asks      [][]interface{} `json:"a"`
+	Exchange      string       `json:"exchange,omitempty"`
+	EventType     string       `json:"e"`
+	Symbol        string       `json:"s"`
+	EventTime     int64        `json:"E"`
+	FirstUpdateID int64        `json:"U"`
+	FinalUpdateID int64        `json:"u"`
+	Bids          []PriceLevel `json:"b"`
+	Asks          []PriceLevel `json:"a"`
 }
 
 type Order struct {
-	ID        int     `json:"id"`
-	Price     float64 `json:"price"`
-	Quantity  float64 `json:"quantity"`
-	Status    string  `json:"status"`
-	OrderType string  `json:"orderType"`
+	ID         int             `json:"id"`
+	Symbol     string          `json:"symbol"`
+	Price      decimal.Decimal `json:"price"`
+	Quantity   decimal.Decimal `json:"quantity"`
+	Fee        decimal.Decimal `json:"fee"`
+	ClosePrice decimal.Decimal `json:"closePrice"`
+	PnL        decimal.Decimal `json:"pnl"`
+	Status     string          `json:"status"`
+	OrderType  string          `json:"orderType"`
+	EventTime  int64           `json:"eventTime"` // exchange time in milliseconds of the tick that created the order
+	// BasketID links orders that are risk checked and executed together, e.g. the legs of a pairs trade.
+	BasketID string `json:"basketId,omitempty"`
+	// ClientOrderID is derived from the strategy, symbol and signal, so a resubmitted order keeps it.
+	ClientOrderID string `json:"clientOrderId,omitempty"`
+	Strategy      string `json:"strategy,omitempty"`
+	// SignalPrice is the mid the signal saw; Slippage is what filling at Price cost against it,
+	// and CloseSlippage what closing at ClosePrice cost against the mid that closed it.
+	// Fee is paid on opening and CloseFee on closing, both in the quote asset, and PnL is net of them.
+	SignalPrice   decimal.Decimal `json:"signalPrice"`
+	Slippage      decimal.Decimal `json:"slippage"`
+	CloseFee      decimal.Decimal `json:"closeFee"`
+	CloseSlippage decimal.Decimal `json:"closeSlippage"`
 }
 
 type Signal struct {
-	Type     string  `json:"type"`
-	Price    float64 `json:"price"`
-	ShortSMA float64 `json:"short_sma"`
-	LongSMA  float64 `json:"long_sma"`
-	Reason   string  `json:"reason"`
+	Symbol    string  `json:"symbol"`
+	Type      string  `json:"type"`
+	Price     float64 `json:"price"`
+	ShortSMA  float64 `json:"short_sma"`
+	LongSMA   float64 `json:"long_sma"`
+	Reason    string  `json:"reason"`
+	EventTime int64   `json:"event_time"` // exchange time in milliseconds of the tick that produced the signal
+	BasketID  string  `json:"basket_id,omitempty"`
+}
+
+// Bar is an OHLCV candle. Times are exchange event times in milliseconds;
+// time bars span [OpenTime, CloseTime), tick and volume bars span their first to last event.
+type Bar struct {
+	Symbol     string  `json:"symbol"`
+	Timeframe  string  `json:"timeframe"`
+	OpenTime   int64   `json:"openTime"`
+	CloseTime  int64   `json:"closeTime"`
+	Open       float64 `json:"open"`
+	High       float64 `json:"high"`
+	Low        float64 `json:"low"`
+	Close      float64 `json:"close"`
+	Volume     float64 `json:"volume"`
+	TradeCount int     `json:"tradeCount"`
+	TickCount  int     `json:"tickCount"`
+}
+
+// Microstructure summarises the shape of the book beyond its mid price.
+type Microstructure struct {
+	Imbalance  float64 `json:"imbalance"`  // (bid - ask) / (bid + ask) volume over the top levels, in [-1, 1]
+	Microprice float64 `json:"microprice"` // best bid and ask weighted by the opposite side's size
+	SpreadBps  float64 `json:"spreadBps"`
+	BidDepth   float64 `json:"bidDepth"` // bid quantity within the configured bps of mid
+	AskDepth   float64 `json:"askDepth"`
+	Pressure   float64 `json:"pressure"` // rolling mean of Imbalance
+}
+
+// Quote is one exchange's best bid and offer.
+type Quote struct {
+	Exchange  string
+	Bid       float64
+	BidQty    float64
+	Ask       float64
+	AskQty    float64
+	EventTime int64
+}
+
+// BBO is the consolidated best bid and offer for a symbol across exchanges.
+type BBO struct {
+	Symbol string
+	Bid    Quote // the exchange with the highest bid
+	Ask    Quote // the exchange with the lowest ask
+}
+
+// ArbitrageOpportunity is a fee-adjusted edge from buying on one exchange's ask and
+// selling on another's bid. Prices and times are those of the quotes when the edge
+// had lasted the minimum duration; ClosedAt is 0 while it is still open.
+type ArbitrageOpportunity struct {
+	ID           int64   `json:"id"`
+	Symbol       string  `json:"symbol"`
+	BuyExchange  string  `json:"buy_exchange"`
+	SellExchange string  `json:"sell_exchange"`
+	BuyPrice     float64 `json:"buy_price"`
+	SellPrice    float64 `json:"sell_price"`
+	BuyTime      int64   `json:"buy_time"`
+	SellTime     int64   `json:"sell_time"`
+	GrossBps     float64 `json:"gross_bps"`
+	NetBps       float64 `json:"net_bps"`
+	MaxNetBps    float64 `json:"max_net_bps"`
+	DetectedAt   int64   `json:"detected_at"` // when the edge first exceeded the threshold
+	OpenedAt     int64   `json:"opened_at"`   // when it had lasted the minimum duration
+	ClosedAt     int64   `json:"closed_at"`
+}
+
+// ParentOrder is an order worked by an execution algorithm through a series of child
+// orders; child fills roll up into FilledQty and AvgPrice. Times are exchange event times
+// in milliseconds and EndTime is the end of the algorithm's horizon.
+type ParentOrder struct {
+	ID           int64           `json:"id"`
+	Algo         string          `json:"algo"`
+	Symbol       string          `json:"symbol"`
+	Side         string          `json:"side"`
+	Quantity     decimal.Decimal `json:"quantity"`
+	FilledQty    decimal.Decimal `json:"filled_quantity"`
+	AvgPrice     decimal.Decimal `json:"avg_price"`
+	ArrivalPrice decimal.Decimal `json:"arrival_price"` // mid price when the order was submitted
+	LimitPrice   decimal.Decimal `json:"limit_price"`   // no child is priced through it
+	Status       string          `json:"status"`        // working, filled, expired or canceled
+	StartTime    int64           `json:"start_time"`
+	EndTime      int64           `json:"end_time"`
+	UpdatedAt    int64           `json:"updated_at"`
+	ChildCount   int             `json:"child_count"`
+	// ClientOrderID is the strategy order's, so a parent is worked once per signal
+	ClientOrderID string `json:"client_order_id,omitempty"`
+	// Fee is the total paid on the children's fills
+	Fee decimal.Decimal `json:"fee"`
+}
+
+// ChildOrder is one slice of a parent order placed on the book.
+type ChildOrder struct {
+	ID        int64           `json:"id"`
+	ParentID  int64           `json:"parent_id"`
+	Symbol    string          `json:"symbol"`
+	Side      string          `json:"side"`
+	Price     decimal.Decimal `json:"price"`
+	Quantity  decimal.Decimal `json:"quantity"`
+	FilledQty decimal.Decimal `json:"filled_quantity"`
+	Fee       decimal.Decimal `json:"fee"`
+	Status    string          `json:"status"` // working, filled, canceled or replaced
+	CreatedAt int64           `json:"created_at"`
+	UpdatedAt int64           `json:"updated_at"`
+}
+
+// LimitOrder is an order at a limit price with a time in force: GTC rests until filled or
+// canceled, IOC takes what it can and cancels the rest, FOK fills in full or not at all,
+// and POST_ONLY is rejected rather than take liquidity. A passive order joins the near
+// touch and follows it. QueueAhead is the paper simulator's estimate of the quantity
+// ahead of the order at its price.
+type LimitOrder struct {
+	ID          int64           `json:"id"`
+	Symbol      string          `json:"symbol"`
+	Side        string          `json:"side"`
+	Price       decimal.Decimal `json:"price"`
+	Quantity    decimal.Decimal `json:"quantity"`
+	FilledQty   decimal.Decimal `json:"filled_quantity"`
+	AvgPrice    decimal.Decimal `json:"avg_price"`
+	TimeInForce string          `json:"time_in_force"`
+	Passive     bool            `json:"passive"`
+	Status      string          `json:"status"` // open, partially_filled, filled, canceled, expired or rejected
+	QueueAhead  float64         `json:"queue_ahead"`
+	CreatedAt   int64           `json:"created_at"`
+	UpdatedAt   int64           `json:"updated_at"`
+	// ClientOrderID makes placing the order idempotent: a second order with it is rejected
+	ClientOrderID string `json:"client_order_id,omitempty"`
+	// Fee is the total paid on its fills, maker or taker
+	Fee decimal.Decimal `json:"fee"`
+}
+
+// Balance is one asset's holding: Free can be spent, Locked is reserved by working orders.
+type Balance struct {
+	Asset     string          `json:"asset"`
+	Free      decimal.Decimal `json:"free"`
+	Locked    decimal.Decimal `json:"locked"`
+	UpdatedAt int64           `json:"updated_at"`
+}
+
+// Fill is a trade against the book: an order opening or closing.
+type Fill struct {
+	Symbol    string          `json:"symbol"`
+	Side      string          `json:"side"`
+	Quantity  decimal.Decimal `json:"quantity"`
+	Price     decimal.Decimal `json:"price"`
+	Fee       decimal.Decimal `json:"fee"` // in the quote asset
+	EventTime int64           `json:"event_time"`
+}
+
+// BalanceDrift is a difference the reconciler found between the balance the orders table
+// implies and the one the account reports.
+type BalanceDrift struct {
+	Asset      string          `json:"asset"`
+	Expected   decimal.Decimal `json:"expected"`
+	Actual     decimal.Decimal `json:"actual"`
+	Drift      decimal.Decimal `json:"drift"`
+	Corrected  bool            `json:"corrected"`
+	DetectedAt int64           `json:"detected_at"`
+}
+
+// StrategyPnL sums a strategy's closed orders: GrossPnL on fill prices, the fees and
+// slippage paid on opening and closing, and NetPnL after fees.
+type StrategyPnL struct {
+	Strategy string          `json:"strategy"`
+	Orders   int             `json:"orders"`
+	GrossPnL decimal.Decimal `json:"gross_pnl"`
+	Fees     decimal.Decimal `json:"fees"`
+	Slippage decimal.Decimal `json:"slippage"`
+	NetPnL   decimal.Decimal `json:"net_pnl"`
+}
+
+// AssetValue is one asset's holding valued in a portfolio's reporting currency at Price, the
+// asset's rate in it. Weight is its share of the portfolio's equity.
+type AssetValue struct {
+	Asset    string          `json:"asset"`
+	Quantity decimal.Decimal `json:"quantity"`
+	Price    decimal.Decimal `json:"price"`
+	Value    decimal.Decimal `json:"value"`
+	Weight   float64         `json:"weight"`
+}
+
+// Portfolio values every balance in Currency. Exposure counts the assets that aren't
+// Currency or pegged to it: gross sums their absolute values, net nets shorts against longs.
+// Assets without a rate are listed in Unpriced and left out of the totals.
+type Portfolio struct {
+	Currency      string          `json:"currency"`
+	Equity        decimal.Decimal `json:"equity"`
+	GrossExposure decimal.Decimal `json:"gross_exposure"`
+	NetExposure   decimal.Decimal `json:"net_exposure"`
+	Assets        []AssetValue    `json:"assets"`
+	Unpriced      []string        `json:"unpriced,omitempty"`
+	Time          int64           `json:"time"`
+}
+
+// RiskReport is the portfolio's one-bar risk over the last Observations bar returns: Value at
+// Risk and Expected Shortfall at Confidence, historical and parametric, in Currency. Symbols
+// are the series the exposed assets are modelled with and Correlations their return
+// correlation matrix in the same order. Concentration is the Herfindahl index of the
+// exposure shares, from 1/n when spread evenly to 1 for a single asset.
+type RiskReport struct {
+	Currency      string      `json:"currency"`
+	Timeframe     string      `json:"timeframe"`
+	Confidence    float64     `json:"confidence"`
+	Observations  int         `json:"observations"`
+	HistoricalVaR float64     `json:"historical_var"`
+	HistoricalES  float64     `json:"historical_es"`
+	ParametricVaR float64     `json:"parametric_var"`
+	ParametricES  float64     `json:"parametric_es"`
+	Concentration float64     `json:"concentration"`
+	Symbols       []string    `json:"symbols"`
+	Correlations  [][]float64 `json:"correlations"`
+	// Unmodelled lists exposed assets without enough bars in a cash-quoted symbol; their
+	// exposure is left out of VaR and ES.
+	Unmodelled []string `json:"unmodelled,omitempty"`
+	Time       int64    `json:"time"`
 }
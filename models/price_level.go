@@ -0,0 +1,132 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/turgaysozen/algotrading/utils"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// PriceLevel is a single bid or ask level, parsed once from Binance's ["price", "qty"] string pair.
+type PriceLevel struct {
+	Price float64
+	Qty   float64
+}
+
+// RejectMalformedPrice is the ticks_rejected_total reason for a level that can't be parsed.
+const RejectMalformedPrice = "malformed_price"
+
+// PriceLevelError is returned when a level can't be parsed into a price and a quantity.
+type PriceLevelError struct {
+	Raw string
+	Err error
+}
+
+func (e *PriceLevelError) Error() string {
+	return fmt.Sprintf("malformed price level %s: %v", e.Raw, e.Err)
+}
+
+func (e *PriceLevelError) Unwrap() error {
+	return e.Err
+}
+
+func (l *PriceLevel) UnmarshalJSON(data []byte) error {
+	var pair []string
+	if err := json.Unmarshal(data, &pair); err != nil {
+		return &PriceLevelError{Raw: string(data), Err: err}
+	}
+	if len(pair) != 2 {
+		return &PriceLevelError{Raw: string(data), Err: fmt.Errorf("expected 2 fields, got %d", len(pair))}
+	}
+
+	price, err := utils.StringToFloat64(pair[0])
+	if err != nil {
+		return &PriceLevelError{Raw: string(data), Err: err}
+	}
+	qty, err := utils.StringToFloat64(pair[1])
+	if err != nil {
+		return &PriceLevelError{Raw: string(data), Err: err}
+	}
+
+	l.Price = price
+	l.Qty = qty
+	return nil
+}
+
+// MarshalJSON writes the level back in Binance's string pair format so JSON round trips are lossless.
+func (l PriceLevel) MarshalJSON() ([]byte, error) {
+	return json.Marshal([2]string{
+		strconv.FormatFloat(l.Price, 'f', -1, 64),
+		strconv.FormatFloat(l.Qty, 'f', -1, 64),
+	})
+}
+
+// EncodeMsgpack writes the level as a 2 element [price, qty] float array.
+func (l PriceLevel) EncodeMsgpack(enc *msgpack.Encoder) error {
+	if err := enc.EncodeArrayLen(2); err != nil {
+		return err
+	}
+	if err := enc.EncodeFloat64(l.Price); err != nil {
+		return err
+	}
+	return enc.EncodeFloat64(l.Qty)
+}
+
+// DecodeMsgpack accepts both float pairs and the string pairs published by schema version 1 producers.
+func (l *PriceLevel) DecodeMsgpack(dec *msgpack.Decoder) error {
+	n, err := dec.DecodeArrayLen()
+	if err != nil {
+		return &PriceLevelError{Raw: "msgpack", Err: err}
+	}
+	if n != 2 {
+		return &PriceLevelError{Raw: "msgpack", Err: fmt.Errorf("expected 2 fields, got %d", n)}
+	}
+
+	var values [2]float64
+	for i := range values {
+		v, err := dec.DecodeInterface()
+		if err != nil {
+			return &PriceLevelError{Raw: "msgpack", Err: err}
+		}
+		switch val := v.(type) {
+		case float64:
+			values[i] = val
+		case float32:
+			values[i] = float64(val)
+		case string:
+			values[i], err = utils.StringToFloat64(val)
+			if err != nil {
+				return &PriceLevelError{Raw: val, Err: err}
+			}
+		default:
+			return &PriceLevelError{Raw: fmt.Sprint(v), Err: fmt.Errorf("unexpected type %T", v)}
+		}
+	}
+
+	l.Price = values[0]
+	l.Qty = values[1]
+	return nil
+}
+
+// BestBid returns the highest priced bid with quantity; zero quantity levels are removals in
+// diff streams. ok is false when no level has quantity.
+func BestBid(bids []PriceLevel) (best PriceLevel, ok bool) {
+	for _, bid := range bids {
+		if bid.Qty > 0 && (!ok || bid.Price > best.Price) {
+			best, ok = bid, true
+		}
+	}
+	return best, ok
+}
+
+// BestAsk returns the lowest priced ask with quantity; ok is false when no level has quantity.
+func BestAsk(asks []PriceLevel) (best PriceLevel, ok bool) {
+	for _, ask := range asks {
+		if ask.Qty > 0 && (!ok || ask.Price < best.Price) {
+			best, ok = ask, true
+		}
+	}
+	return best, ok
+}
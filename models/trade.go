@@ -0,0 +1,72 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/turgaysozen/algotrading/utils"
+)
+
+// Trade is a Binance @trade or @aggTrade event, or the same trade normalised by another exchange's
+// feed adapter. For aggTrade, TradeID holds the aggregate trade ID.
+type Trade struct {
+	Exchange     string  `json:"exchange,omitempty"`
+	EventType    string  `json:"e"`
+	Symbol       string  `json:"s"`
+	EventTime    int64   `json:"E"`
+	TradeID      int64   `json:"t"`
+	Price        float64 `json:"p"`
+	Quantity     float64 `json:"q"`
+	TradeTime    int64   `json:"T"`
+	IsBuyerMaker bool    `json:"m"`
+}
+
+// UnmarshalJSON parses Binance's string prices and quantities once and maps aggTrade's "a" ID onto TradeID.
+// Plain numbers are accepted as well so trades round trip through the JSON bus encoding.
+func (t *Trade) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Exchange     string          `json:"exchange"`
+		EventType    string          `json:"e"`
+		Symbol       string          `json:"s"`
+		EventTime    int64           `json:"E"`
+		TradeID      *int64          `json:"t"`
+		AggTradeID   *int64          `json:"a"`
+		Price        json.RawMessage `json:"p"`
+		Quantity     json.RawMessage `json:"q"`
+		TradeTime    int64           `json:"T"`
+		IsBuyerMaker bool            `json:"m"`
+		// Binance's "M" (always ignore) would otherwise overwrite "m" case-insensitively
+		Ignore bool `json:"M"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	price, err := utils.StringToFloat64(strings.Trim(string(raw.Price), `"`))
+	if err != nil {
+		return fmt.Errorf("trade price: %w", err)
+	}
+	qty, err := utils.StringToFloat64(strings.Trim(string(raw.Quantity), `"`))
+	if err != nil {
+		return fmt.Errorf("trade quantity: %w", err)
+	}
+
+	*t = Trade{
+		Exchange:     raw.Exchange,
+		EventType:    raw.EventType,
+		Symbol:       raw.Symbol,
+		EventTime:    raw.EventTime,
+		Price:        price,
+		Quantity:     qty,
+		TradeTime:    raw.TradeTime,
+		IsBuyerMaker: raw.IsBuyerMaker,
+	}
+	switch {
+	case raw.AggTradeID != nil:
+		t.TradeID = *raw.AggTradeID
+	case raw.TradeID != nil:
+		t.TradeID = *raw.TradeID
+	}
+	return nil
+}
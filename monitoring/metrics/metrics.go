@@ -9,6 +9,7 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/shirou/gopsutil/cpu"
+	"github.com/turgaysozen/algotrading/clock"
 )
 
 var (
@@ -56,6 +57,13 @@ var (
 		[]string{"error_type"},
 	)
 
+	legacyPayloads = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "codec_legacy_payloads_total",
+			Help: "Bus payloads decoded as legacy headerless JSON",
+		},
+	)
+
 	dataLoss = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "dataloss_error_count",
@@ -64,11 +72,306 @@ var (
 		[]string{"data_loss_type"},
 	)
 
+	ticksRejected = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ticks_rejected_total",
+			Help: "Total number of order book ticks rejected by validation",
+		},
+		[]string{"reason"},
+	)
+
+	ticksSuppressed = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ticks_suppressed_total",
+			Help: "Total number of ticks held back by the outlier filter",
+		},
+		[]string{"exchange", "symbol", "reason"},
+	)
+
+	bookMicrostructure = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "book_microstructure",
+			Help: "Latest order book microstructure analytics per symbol",
+		},
+		[]string{"symbol", "metric"},
+	)
+
+	feedLastUpdateAge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "feed_last_update_age_seconds",
+			Help: "Seconds since the feed last delivered an update per exchange and symbol",
+		},
+		[]string{"exchange", "symbol"},
+	)
+
+	connectionUp = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "connection_up",
+			Help: "Whether the connection to a dependency is up (1) or down (0)",
+		},
+		[]string{"component"},
+	)
+
+	reconnectAttempts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "reconnect_attempts_total",
+			Help: "Failed connection attempts per dependency",
+		},
+		[]string{"component"},
+	)
+
+	feedConnectionLatency = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "feed_connection_latency_seconds",
+			Help: "Smoothed delay between exchange event time and receipt per feed connection",
+		},
+		[]string{"connection"},
+	)
+
+	feedConnectionEvents = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "feed_connection_events_total",
+			Help: "Events received per feed connection, by whether it delivered them first",
+		},
+		[]string{"connection", "delivery"},
+	)
+
+	feedConnectionMissed = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "feed_connection_missed_updates_total",
+			Help: "Update and trade IDs skipped in a feed connection's own sequence",
+		},
+		[]string{"connection"},
+	)
+
+	feedConnectionLeader = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "feed_connection_leader",
+			Help: "1 for the feed connection delivering most events first",
+		},
+		[]string{"connection"},
+	)
+
+	crossExchangeSpread = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cross_exchange_spread_bps",
+			Help: "Fee-adjusted edge of buying on one exchange and selling on another",
+		},
+		[]string{"symbol", "buy_exchange", "sell_exchange"},
+	)
+
+	arbitrageOpportunities = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "arbitrage_opportunities_total",
+			Help: "Cross-exchange opportunities that lasted the minimum duration",
+		},
+		[]string{"symbol"},
+	)
+
+	pairsSpreadZScore = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "pairs_spread_zscore",
+			Help: "Z-score of each pair's hedged log price spread over its rolling window",
+		},
+		[]string{"pair"},
+	)
+
+	pairsHedgeRatio = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "pairs_hedge_ratio",
+			Help: "Rolling regression hedge ratio of each pair's first leg on its second",
+		},
+		[]string{"pair"},
+	)
+
+	riskRejections = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "risk_rejections_total",
+			Help: "Orders and baskets rejected by pre-trade risk checks, by reason",
+		},
+		[]string{"reason"},
+	)
+
+	executionActiveParents = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "execution_active_parent_orders",
+			Help: "Parent orders currently worked by each execution algorithm",
+		},
+		[]string{"algo"},
+	)
+
+	executionFillRatio = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "execution_parent_fill_ratio",
+			Help: "Filled fraction of the latest parent order per algorithm and symbol",
+		},
+		[]string{"algo", "symbol"},
+	)
+
+	executionChildOrders = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "execution_child_orders_total",
+			Help: "Child orders by final status: filled, canceled or replaced",
+		},
+		[]string{"algo", "status"},
+	)
+
+	executionSlippage = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "execution_slippage_bps",
+			Help: "Average fill price of the last finished parent order against its arrival mid; positive is adverse",
+		},
+		[]string{"algo", "symbol"},
+	)
+
+	limitOrders = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "limit_orders_total",
+			Help: "Finished limit orders by time in force and final status",
+		},
+		[]string{"time_in_force", "status"},
+	)
+
+	limitOrderReplacements = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "limit_order_replacements_total",
+			Help: "Limit orders canceled and replaced, by amendment or passive re-pricing",
+		},
+		[]string{"reason"},
+	)
+
+	accountBalance = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "account_balance",
+			Help: "Balance of each asset, free or locked by working orders",
+		},
+		[]string{"asset", "state"},
+	)
+
+	balanceDrift = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "balance_drift",
+			Help: "Account balance minus the balance the orders table implies, per asset",
+		},
+		[]string{"asset"},
+	)
+
+	balanceDrifts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "balance_drifts_total",
+			Help: "Confirmed drifts between the orders table and the account, per asset",
+		},
+		[]string{"asset"},
+	)
+
+	reconciliationAlert = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "balance_reconciliation_alert",
+			Help: "1 while the last reconciliation found the orders table and the account diverging",
+		},
+	)
+
+	duplicateOrders = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "duplicate_orders_total",
+			Help: "Orders rejected because their client order ID was already submitted, by where it was caught",
+		},
+		[]string{"stage"},
+	)
+
+	recoveredOrders = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "recovered_orders_total",
+			Help: "Orders left working by a previous run and resolved at start-up, by kind",
+		},
+		[]string{"kind"},
+	)
+
+	strategyFees = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "strategy_fees",
+			Help: "Fees paid on orders closed since start-up per strategy, net of rebates, in the quote asset",
+		},
+		[]string{"strategy"},
+	)
+
+	strategySlippage = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "strategy_slippage",
+			Help: "Slippage against the signal mid of orders closed since start-up per strategy, in the quote asset",
+		},
+		[]string{"strategy"},
+	)
+
+	strategyNetPnL = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "strategy_net_pnl",
+			Help: "PnL after fees of orders closed since start-up per strategy, in the quote asset",
+		},
+		[]string{"strategy"},
+	)
+
+	portfolioEquity = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "portfolio_equity",
+			Help: "Value of every priced balance in the reporting currency",
+		},
+		[]string{"currency"},
+	)
+
+	portfolioExposure = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "portfolio_exposure",
+			Help: "Gross or net value of non-cash assets in the reporting currency",
+		},
+		[]string{"currency", "type"},
+	)
+
+	portfolioWeight = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "portfolio_asset_weight",
+			Help: "Share of portfolio equity held in each asset",
+		},
+		[]string{"asset"},
+	)
+
+	portfolioUnpriced = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "portfolio_unpriced_assets",
+			Help: "Assets held without a rate to the reporting currency, left out of equity",
+		},
+	)
+
+	portfolioRisk = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "portfolio_risk",
+			Help: "One-bar Value at Risk or Expected Shortfall of the portfolio in the reporting currency, by method",
+		},
+		[]string{"currency", "method", "measure"},
+	)
+
+	portfolioConcentration = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "portfolio_concentration",
+			Help: "Herfindahl index of each asset's share of gross exposure",
+		},
+	)
+
+	symbolCorrelation = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "symbol_correlation",
+			Help: "Correlation of bar returns between two symbols the portfolio risk is modelled with",
+		},
+		[]string{"symbol_a", "symbol_b"},
+	)
+
 	activeTimers   = make(map[string]time.Time)
 	activeTimersMu sync.Mutex
 	latencySums    = make(map[string]float64)
 	latencyCounts  = make(map[string]int)
 	latencyMutex   = sync.Mutex{}
+
+	clk clock.Clock = clock.Real{}
 )
 
 func init() {
@@ -79,13 +382,57 @@ func init() {
 		cpuUsage,
 		memoryUsage,
 		errors,
+		legacyPayloads,
 		dataLoss,
+		ticksRejected,
+		ticksSuppressed,
+		bookMicrostructure,
+		feedLastUpdateAge,
+		connectionUp,
+		reconnectAttempts,
+		feedConnectionLatency,
+		feedConnectionEvents,
+		feedConnectionMissed,
+		feedConnectionLeader,
+		crossExchangeSpread,
+		arbitrageOpportunities,
+		pairsSpreadZScore,
+		pairsHedgeRatio,
+		riskRejections,
+		executionActiveParents,
+		executionFillRatio,
+		executionChildOrders,
+		executionSlippage,
+		limitOrders,
+		limitOrderReplacements,
+		accountBalance,
+		balanceDrift,
+		balanceDrifts,
+		reconciliationAlert,
+		duplicateOrders,
+		recoveredOrders,
+		strategyFees,
+		strategySlippage,
+		strategyNetPnL,
+		portfolioEquity,
+		portfolioExposure,
+		portfolioWeight,
+		portfolioUnpriced,
+		portfolioRisk,
+		portfolioConcentration,
+		symbolCorrelation,
 	)
 }
 
+// SetClock replaces the clock latency timers are measured with. Replays keep the wall clock
+// so latencies stay real processing times.
+func SetClock(c clock.Clock) {
+	clk = c
+}
+
 func SetStartTime(metricType string) {
 	activeTimersMu.Lock()
-	activeTimers[metricType] = time.Now()
+	activeTimers[metricType] = clk.Now()
 	activeTimersMu.Unlock()
 }
 
@@ -101,7 +448,7 @@ func RecordLatency(metricType string) {
 		return
 	}
 
-	latency := time.Since(startTime).Seconds()
+	latency := clk.Now().Sub(startTime).Seconds()
 
 	switch metricType {
 	case "orderbook_avg":
@@ -142,6 +489,174 @@ func RecordError(errorType string) {
 	errors.WithLabelValues(errorType).Inc()
 }
 
+func RecordLegacyPayload() {
+	legacyPayloads.Inc()
+}
+
 func RecordDataLoss(dataLossType string) {
 	dataLoss.WithLabelValues(dataLossType).Inc()
 }
+
+func RecordTickRejection(reason string) {
+	ticksRejected.WithLabelValues(reason).Inc()
+}
+
+func RecordTickSuppressed(exchange, symbol, reason string) {
+	ticksSuppressed.WithLabelValues(exchange, symbol, reason).Inc()
+}
+
+func SetMicrostructure(symbol string, imbalance, microprice, spreadBps, bidDepth, askDepth, pressure float64) {
+	bookMicrostructure.WithLabelValues(symbol, "imbalance").Set(imbalance)
+	bookMicrostructure.WithLabelValues(symbol, "microprice").Set(microprice)
+	bookMicrostructure.WithLabelValues(symbol, "spread_bps").Set(spreadBps)
+	bookMicrostructure.WithLabelValues(symbol, "bid_depth").Set(bidDepth)
+	bookMicrostructure.WithLabelValues(symbol, "ask_depth").Set(askDepth)
+	bookMicrostructure.WithLabelValues(symbol, "pressure").Set(pressure)
+}
+
+func SetFeedLastUpdateAge(exchange, symbol string, seconds float64) {
+	feedLastUpdateAge.WithLabelValues(exchange, symbol).Set(seconds)
+}
+
+func SetConnectionUp(component string, up bool) {
+	value := 0.0
+	if up {
+		value = 1
+	}
+	connectionUp.WithLabelValues(component).Set(value)
+}
+
+func RecordReconnectAttempt(component string) {
+	reconnectAttempts.WithLabelValues(component).Inc()
+}
+
+func SetFeedConnectionLatency(connection string, seconds float64) {
+	feedConnectionLatency.WithLabelValues(connection).Set(seconds)
+}
+
+func RecordFeedEvent(connection string, first bool) {
+	delivery := "duplicate"
+	if first {
+		delivery = "first"
+	}
+	feedConnectionEvents.WithLabelValues(connection, delivery).Inc()
+}
+
+func RecordFeedMissedUpdates(connection string, count float64) {
+	feedConnectionMissed.WithLabelValues(connection).Add(count)
+}
+
+func SetFeedLeader(connection string, leading bool) {
+	value := 0.0
+	if leading {
+		value = 1
+	}
+	feedConnectionLeader.WithLabelValues(connection).Set(value)
+}
+
+func SetCrossExchangeSpread(symbol, buyExchange, sellExchange string, netBps float64) {
+	crossExchangeSpread.WithLabelValues(symbol, buyExchange, sellExchange).Set(netBps)
+}
+
+func RecordArbitrageOpportunity(symbol string) {
+	arbitrageOpportunities.WithLabelValues(symbol).Inc()
+}
+
+func SetPairsSpread(pair string, zScore, hedgeRatio float64) {
+	pairsSpreadZScore.WithLabelValues(pair).Set(zScore)
+	pairsHedgeRatio.WithLabelValues(pair).Set(hedgeRatio)
+}
+
+func RecordRiskRejection(reason string) {
+	riskRejections.WithLabelValues(reason).Inc()
+}
+
+func SetActiveParentOrders(algo string, count int) {
+	executionActiveParents.WithLabelValues(algo).Set(float64(count))
+}
+
+func SetParentFillRatio(algo, symbol string, ratio float64) {
+	executionFillRatio.WithLabelValues(algo, symbol).Set(ratio)
+}
+
+func RecordChildOrder(algo, status string) {
+	executionChildOrders.WithLabelValues(algo, status).Inc()
+}
+
+func SetExecutionSlippage(algo, symbol string, bps float64) {
+	executionSlippage.WithLabelValues(algo, symbol).Set(bps)
+}
+
+func RecordLimitOrder(timeInForce, status string) {
+	limitOrders.WithLabelValues(timeInForce, status).Inc()
+}
+
+func RecordLimitOrderReplacement(reason string) {
+	limitOrderReplacements.WithLabelValues(reason).Inc()
+}
+
+func SetAccountBalance(asset string, free, locked float64) {
+	accountBalance.WithLabelValues(asset, "free").Set(free)
+	accountBalance.WithLabelValues(asset, "locked").Set(locked)
+}
+
+func SetBalanceDrift(asset string, drift float64) {
+	balanceDrift.WithLabelValues(asset).Set(drift)
+}
+
+func RecordBalanceDrift(asset string) {
+	balanceDrifts.WithLabelValues(asset).Inc()
+}
+
+func SetReconciliationAlert(diverged bool) {
+	value := 0.0
+	if diverged {
+		value = 1
+	}
+	reconciliationAlert.Set(value)
+}
+
+func RecordDuplicateOrder(stage string) {
+	duplicateOrders.WithLabelValues(stage).Inc()
+}
+
+func RecordRecoveredOrder(kind string) {
+	recoveredOrders.WithLabelValues(kind).Inc()
+}
+
+func RecordStrategyClose(strategy string, fees, slippage, netPnL float64) {
+	strategyFees.WithLabelValues(strategy).Add(fees)
+	strategySlippage.WithLabelValues(strategy).Add(slippage)
+	strategyNetPnL.WithLabelValues(strategy).Add(netPnL)
+}
+
+func SetPortfolio(currency string, equity, gross, net float64, weights map[string]float64, unpriced int) {
+	portfolioEquity.WithLabelValues(currency).Set(equity)
+	portfolioExposure.WithLabelValues(currency, "gross").Set(gross)
+	portfolioExposure.WithLabelValues(currency, "net").Set(net)
+	// assets no longer held drop out instead of keeping their last weight
+	portfolioWeight.Reset()
+	for asset, weight := range weights {
+		portfolioWeight.WithLabelValues(asset).Set(weight)
+	}
+	portfolioUnpriced.Set(float64(unpriced))
+}
+
+func SetPortfolioRisk(currency, method string, valueAtRisk, expectedShortfall float64) {
+	portfolioRisk.WithLabelValues(currency, method, "var").Set(valueAtRisk)
+	portfolioRisk.WithLabelValues(currency, method, "es").Set(expectedShortfall)
+}
+
+func SetPortfolioConcentration(hhi float64) {
+	portfolioConcentration.Set(hhi)
+}
+
+// ResetSymbolCorrelations drops the pairs of the previous estimate, so symbols no longer
+// modelled don't keep their last correlation.
+func ResetSymbolCorrelations() {
+	symbolCorrelation.Reset()
+}
+
+func SetSymbolCorrelation(symbolA, symbolB string, correlation float64) {
+	symbolCorrelation.WithLabelValues(symbolA, symbolB).Set(correlation)
+}
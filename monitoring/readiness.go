@@ -3,15 +3,16 @@ package monitoring
 import (
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/turgaysozen/algotrading/db"
+	"github.com/turgaysozen/algotrading/feeds"
 	"github.com/turgaysozen/algotrading/redisclient"
-	"github.com/turgaysozen/algotrading/wsclient"
 )
 
 func ReadinessHandler(w http.ResponseWriter, r *http.Request) {
-	err := db.Database.Ping()
+	err := db.Ping()
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		fmt.Fprintf(w, `{"status": "not ready", "reason": "database unreachable"}`)
@@ -25,9 +26,15 @@ func ReadinessHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !wsclient.Connected {
+	if down := feeds.Down(); len(down) > 0 {
 		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprintf(w, `{"status": "not ready", "reason": "WebSocket unreachable"}`)
+		fmt.Fprintf(w, `{"status": "not ready", "reason": "WebSocket unreachable for %s"}`, strings.Join(down, ","))
+		return
+	}
+
+	if stale := feeds.Stale(); len(stale) > 0 {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, `{"status": "not ready", "reason": "feed stale for %s"}`, strings.Join(stale, ","))
 		return
 	}
 
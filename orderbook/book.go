@@ -0,0 +1,224 @@
+package orderbook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/turgaysozen/algotrading/models"
+)
+
+var (
+	ErrNotSynced   = errors.New("order book not synced")
+	ErrSequenceGap = errors.New("order book sequence gap")
+)
+
+// Snapshot is Binance's REST depth snapshot.
+type Snapshot struct {
+	LastUpdateID int64               `json:"lastUpdateId"`
+	Bids         []models.PriceLevel `json:"bids"`
+	Asks         []models.PriceLevel `json:"asks"`
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+func FetchSnapshot(baseURL, symbol string, limit int) (Snapshot, error) {
+	query := url.Values{}
+	query.Set("symbol", strings.ToUpper(symbol))
+	query.Set("limit", strconv.Itoa(limit))
+
+	resp, err := httpClient.Get(baseURL + "?" + query.Encode())
+	if err != nil {
+		return Snapshot{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Snapshot{}, fmt.Errorf("depth snapshot for %s: %s", symbol, resp.Status)
+	}
+
+	var snapshot Snapshot
+	if err := json.NewDecoder(resp.Body).Decode(&snapshot); err != nil {
+		return Snapshot{}, err
+	}
+	return snapshot, nil
+}
+
+// Book is a local copy of one symbol's order book, kept in sync by applying diff
+// updates on top of a snapshot in update ID order, as Binance documents.
+type Book struct {
+	mu           sync.Mutex
+	bids         *side
+	asks         *side
+	lastUpdateID int64
+	synced       bool
+}
+
+func New() *Book {
+	return &Book{}
+}
+
+func (b *Book) Synced() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.synced
+}
+
+func (b *Book) LastUpdateID() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.lastUpdateID
+}
+
+// Reset drops the book, so nothing is applied until the next snapshot.
+func (b *Book) Reset() {
+	b.mu.Lock()
+	b.synced = false
+	b.bids, b.asks = nil, nil
+	b.mu.Unlock()
+}
+
+func (b *Book) LoadSnapshot(s Snapshot) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.bids = newSide(true, len(s.Bids))
+	b.asks = newSide(false, len(s.Asks))
+	b.bids.set(s.Bids)
+	b.asks.set(s.Asks)
+	b.lastUpdateID = s.LastUpdateID
+	b.synced = true
+}
+
+// Apply applies a diff update. It returns false without an error for updates the book
+// already contains, which also makes it safe to feed the same update twice. An update
+// that starts after the next expected ID resets the book and returns ErrSequenceGap.
+func (b *Book) Apply(update models.OrderBook) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.synced {
+		return false, ErrNotSynced
+	}
+	if update.FinalUpdateID <= b.lastUpdateID {
+		return false, nil
+	}
+	if update.FirstUpdateID > b.lastUpdateID+1 {
+		expected := b.lastUpdateID + 1
+		b.synced = false
+		b.bids, b.asks = nil, nil
+		return false, fmt.Errorf("%w: expected update %d, got %d-%d", ErrSequenceGap, expected, update.FirstUpdateID, update.FinalUpdateID)
+	}
+
+	b.bids.set(update.Bids)
+	b.asks.set(update.Asks)
+	b.lastUpdateID = update.FinalUpdateID
+	return true, nil
+}
+
+// Update applies levels from exchanges whose sequence rules are checked by their feed
+// adapter rather than by update IDs.
+func (b *Book) Update(bids, asks []models.PriceLevel) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.synced {
+		return ErrNotSynced
+	}
+	b.bids.set(bids)
+	b.asks.set(asks)
+	return nil
+}
+
+// Trim drops every level beyond the best n on each side, for feeds that only maintain
+// a book of a fixed depth.
+func (b *Book) Trim(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.synced {
+		b.bids.trim(n)
+		b.asks.trim(n)
+	}
+}
+
+// Top returns the best n bids (highest first) and asks (lowest first).
+func (b *Book) Top(n int) ([]models.PriceLevel, []models.PriceLevel) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.synced {
+		return []models.PriceLevel{}, []models.PriceLevel{}
+	}
+	return b.bids.top(n), b.asks.top(n)
+}
+
+// side is one side of the book: quantities by price, and the prices kept sorted best first
+// as levels come and go, so reading the top never sorts the whole side.
+type side struct {
+	qty        map[float64]float64
+	prices     []float64
+	descending bool
+}
+
+func newSide(descending bool, size int) *side {
+	return &side{qty: make(map[float64]float64, size), prices: make([]float64, 0, size), descending: descending}
+}
+
+// search returns where price is, or would be inserted, in prices.
+func (s *side) search(price float64) int {
+	if s.descending {
+		return sort.Search(len(s.prices), func(i int) bool { return s.prices[i] <= price })
+	}
+	return sort.Search(len(s.prices), func(i int) bool { return s.prices[i] >= price })
+}
+
+// set writes absolute quantities; a zero quantity removes the level.
+func (s *side) set(levels []models.PriceLevel) {
+	for _, l := range levels {
+		_, exists := s.qty[l.Price]
+		if l.Qty == 0 {
+			if exists {
+				delete(s.qty, l.Price)
+				i := s.search(l.Price)
+				s.prices = append(s.prices[:i], s.prices[i+1:]...)
+			}
+			continue
+		}
+		if !exists {
+			i := s.search(l.Price)
+			s.prices = append(s.prices, 0)
+			copy(s.prices[i+1:], s.prices[i:])
+			s.prices[i] = l.Price
+		}
+		s.qty[l.Price] = l.Qty
+	}
+}
+
+func (s *side) trim(n int) {
+	if len(s.prices) <= n {
+		return
+	}
+	for _, p := range s.prices[n:] {
+		delete(s.qty, p)
+	}
+	s.prices = s.prices[:n]
+}
+
+func (s *side) top(n int) []models.PriceLevel {
+	if n > len(s.prices) {
+		n = len(s.prices)
+	}
+	levels := make([]models.PriceLevel, n)
+	for i, p := range s.prices[:n] {
+		levels[i] = models.PriceLevel{Price: p, Qty: s.qty[p]}
+	}
+	return levels
+}
@@ -0,0 +1,47 @@
+package portfolio
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Handler serves /portfolio: GET returns the balances valued in the reporting currency at the
+// latest rates, with equity, exposure and each asset's weight.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	p, err := Value()
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprintf(w, `{"error": %q}`, err.Error())
+		return
+	}
+	json.NewEncoder(w).Encode(p)
+}
+
+// RiskHandler serves /portfolio/risk: GET returns the portfolio's VaR and ES, the correlation
+// matrix of the symbols it is modelled with and its concentration.
+func RiskHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	report, err := Risk()
+	if err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, `{"error": %q}`, err.Error())
+		return
+	}
+	json.NewEncoder(w).Encode(report)
+}
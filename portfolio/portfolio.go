@@ -0,0 +1,222 @@
+package portfolio
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/turgaysozen/algotrading/account"
+	"github.com/turgaysozen/algotrading/clock"
+	"github.com/turgaysozen/algotrading/config"
+	"github.com/turgaysozen/algotrading/db"
+	"github.com/turgaysozen/algotrading/decimal"
+	"github.com/turgaysozen/algotrading/models"
+	"github.com/turgaysozen/algotrading/monitoring/metrics"
+)
+
+// The portfolio values every balance the account holds, whatever it is quoted in, in one
+// reporting currency. Open positions are in the balances already: a buy holds its base asset
+// and a sell is short of it.
+
+type Config struct {
+	Currency         string
+	SnapshotInterval time.Duration
+	// MaxRateAge ignores rates this much older than the newest one, so a symbol the feeds
+	// stopped quoting doesn't value holdings at a stale price.
+	MaxRateAge time.Duration
+	// Pegs values assets at 1 in the asset they're pegged to while no rate between them is quoted.
+	Pegs map[string]string
+
+	// RiskTimeframe bars over RiskLookback are the return history VaR and correlations are
+	// estimated from, refreshed every RiskInterval.
+	RiskTimeframe string
+	RiskLookback  time.Duration
+	RiskInterval  time.Duration
+	VaRConfidence float64
+	// VaRMethod, historical or parametric, is the VaR pre-trade checks budget
+	VaRMethod string
+}
+
+// DefaultPegs are the stablecoins and fiat valued 1:1 with USDT; PORTFOLIO_PEGS adds to them.
+var DefaultPegs = map[string]string{"USD": "USDT", "USDC": "USDT", "FDUSD": "USDT", "BUSD": "USDT"}
+
+func LoadConfig() Config {
+	pegs := make(map[string]string, len(DefaultPegs))
+	for asset, peg := range DefaultPegs {
+		pegs[asset] = peg
+	}
+	for asset, peg := range config.GetEnvMap("PORTFOLIO_PEGS") {
+		pegs[asset] = peg
+	}
+	c := Config{
+		Currency:         config.GetEnv("PORTFOLIO_CURRENCY", "USDT"),
+		SnapshotInterval: config.GetEnvDuration("PORTFOLIO_SNAPSHOT_INTERVAL", time.Minute),
+		MaxRateAge:       config.GetEnvDuration("PORTFOLIO_MAX_RATE_AGE", 5*time.Minute),
+		Pegs:             pegs,
+		RiskTimeframe:    config.GetEnv("RISK_TIMEFRAME", "1h"),
+		RiskLookback:     config.GetEnvDuration("RISK_LOOKBACK", 30*24*time.Hour),
+		RiskInterval:     config.GetEnvDuration("RISK_INTERVAL", 5*time.Minute),
+		VaRConfidence:    config.GetEnvFloat("RISK_VAR_CONFIDENCE", 0.99),
+		VaRMethod:        config.GetEnv("RISK_VAR_METHOD", Historical),
+	}
+	if c.VaRConfidence <= 0 || c.VaRConfidence >= 1 {
+		log.Printf("Invalid RISK_VAR_CONFIDENCE %v, using 0.99", c.VaRConfidence)
+		metrics.RecordError("portfolio_config_invalid")
+		c.VaRConfidence = 0.99
+	}
+	if c.VaRMethod != Historical && c.VaRMethod != Parametric {
+		log.Printf("Invalid RISK_VAR_METHOD %q, using %s", c.VaRMethod, Historical)
+		metrics.RecordError("portfolio_config_invalid")
+		c.VaRMethod = Historical
+	}
+	return c
+}
+
+var (
+	cfgOnce      sync.Once
+	cfg          Config
+	snapshotOnce sync.Once
+	clk          clock.Clock = clock.Real{}
+
+	// latest is the last valuation, which pre-trade checks project orders onto
+	latestMu sync.Mutex
+	latest   *models.Portfolio
+)
+
+func getConfig() Config {
+	cfgOnce.Do(func() { cfg = LoadConfig() })
+	return cfg
+}
+
+// SetClock replaces the wall clock valuations and the risk lookback are measured on.
+func SetClock(c clock.Clock) {
+	clk = c
+}
+
+// Init starts snapshotting the portfolio every PORTFOLIO_SNAPSHOT_INTERVAL and refreshing its
+// risk every RISK_INTERVAL.
+func Init() {
+	snapshotOnce.Do(func() {
+		c := getConfig()
+		log.Printf("Valuing the portfolio in %s every %s", c.Currency, c.SnapshotInterval)
+		go func() {
+			for range time.Tick(c.SnapshotInterval) {
+				Snapshot()
+			}
+		}()
+
+		// estimated once up front so pre-trade checks have a model from the first order
+		RefreshRisk()
+		go func() {
+			for range time.Tick(c.RiskInterval) {
+				RefreshRisk()
+			}
+		}()
+	})
+}
+
+// Value returns the account's balances valued in the reporting currency at the latest rates.
+func Value() (models.Portfolio, error) {
+	c := getConfig()
+	balances, err := account.Balances()
+	if err != nil {
+		return models.Portfolio{}, err
+	}
+	p := value(c, balances, liveGraph(c), clk.Now().UnixMilli())
+	latestMu.Lock()
+	latest = &p
+	latestMu.Unlock()
+	return p, nil
+}
+
+// latestValue returns the last valuation Value made; ok is false before the first one.
+func latestValue() (models.Portfolio, bool) {
+	latestMu.Lock()
+	defer latestMu.Unlock()
+	if latest == nil {
+		return models.Portfolio{}, false
+	}
+	return *latest, true
+}
+
+func value(c Config, balances map[string]models.Balance, g graph, now int64) models.Portfolio {
+	p := models.Portfolio{Currency: c.Currency, Time: now}
+	cash := cashAssets(c)
+
+	assets := make([]string, 0, len(balances))
+	for asset := range balances {
+		assets = append(assets, asset)
+	}
+	sort.Strings(assets)
+
+	for _, asset := range assets {
+		quantity := balances[asset].Free.Add(balances[asset].Locked)
+		if quantity.IsZero() {
+			continue
+		}
+		price, ok := g.rateTo(asset, c.Currency)
+		if !ok {
+			p.Unpriced = append(p.Unpriced, asset)
+			continue
+		}
+		v := models.AssetValue{Asset: asset, Quantity: quantity, Price: decimal.FromFloat(price)}
+		v.Value = quantity.Mul(v.Price)
+		p.Equity = p.Equity.Add(v.Value)
+		if !cash[asset] {
+			p.GrossExposure = p.GrossExposure.Add(v.Value.Abs())
+			p.NetExposure = p.NetExposure.Add(v.Value)
+		}
+		p.Assets = append(p.Assets, v)
+	}
+
+	// weights are undefined without positive equity, so they stay zero
+	if p.Equity.Sign() > 0 {
+		equity := p.Equity.Float64()
+		for i := range p.Assets {
+			p.Assets[i].Weight = p.Assets[i].Value.Float64() / equity
+		}
+	}
+	return p
+}
+
+// cashAssets are the reporting currency and the assets pegged to it, directly or through
+// another peg; they carry no exposure.
+func cashAssets(c Config) map[string]bool {
+	cash := map[string]bool{c.Currency: true}
+	for changed := true; changed; {
+		changed = false
+		for asset, peg := range c.Pegs {
+			if cash[asset] != cash[peg] {
+				cash[asset], cash[peg] = true, true
+				changed = true
+			}
+		}
+	}
+	return cash
+}
+
+// Snapshot values the portfolio, exports it and stores it in portfolio_snapshots.
+func Snapshot() {
+	p, err := Value()
+	if err != nil {
+		log.Printf("Error reading balances for the portfolio: %v", err)
+		metrics.RecordError("portfolio_balance_error")
+		return
+	}
+
+	weights := make(map[string]float64, len(p.Assets))
+	for _, a := range p.Assets {
+		weights[a.Asset] = a.Weight
+	}
+	metrics.SetPortfolio(p.Currency, p.Equity.Float64(), p.GrossExposure.Float64(), p.NetExposure.Float64(), weights, len(p.Unpriced))
+
+	if len(p.Unpriced) > 0 {
+		log.Printf("No rate to %s for %s, left out of the portfolio", p.Currency, strings.Join(p.Unpriced, ", "))
+	}
+	if err := db.SavePortfolioSnapshot(p); err != nil {
+		return
+	}
+	log.Printf("Portfolio: equity %s %s, gross exposure %s, net exposure %s", p.Equity, p.Currency, p.GrossExposure, p.NetExposure)
+}
@@ -0,0 +1,120 @@
+package portfolio
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/turgaysozen/algotrading/config"
+)
+
+// Rates are the latest mid of every symbol the feeds quote, on any exchange. An asset without
+// a direct rate to the reporting currency is converted through others, e.g. SOL to USDT
+// through SOLBTC and BTCUSDT.
+
+type rate struct {
+	base, quote string
+	mid         float64
+	eventTime   int64
+}
+
+var (
+	ratesMu sync.Mutex
+	rates   = make(map[string]rate)
+)
+
+// maxHops is the most symbols a conversion goes through.
+const maxHops = 3
+
+// UpdateRate records symbol's latest bid and ask; symbols without a known quote asset are ignored.
+func UpdateRate(symbol string, bid, ask float64, eventTime int64) {
+	base, quote, ok := config.SplitSymbol(symbol)
+	if !ok || bid <= 0 || ask <= 0 {
+		return
+	}
+	ratesMu.Lock()
+	defer ratesMu.Unlock()
+	if r, ok := rates[symbol]; ok && r.eventTime > eventTime {
+		return
+	}
+	rates[symbol] = rate{base: base, quote: quote, mid: (bid + ask) / 2, eventTime: eventTime}
+}
+
+type edge struct {
+	to   string
+	rate float64
+}
+
+// graph holds, for each asset, what one unit of it is worth in each asset it trades against.
+type graph map[string][]edge
+
+func (g graph) add(from, to string, rate float64) {
+	g[from] = append(g[from], edge{to: to, rate: rate})
+}
+
+// liveGraph links the assets of every rate no older than maxAge before the newest one, and the
+// pegged assets that have no rate between them at 1.
+func liveGraph(cfg Config) graph {
+	ratesMu.Lock()
+	var newest int64
+	for _, r := range rates {
+		if r.eventTime > newest {
+			newest = r.eventTime
+		}
+	}
+	g := make(graph)
+	quoted := make(map[[2]string]bool)
+	for _, r := range rates {
+		if newest-r.eventTime > cfg.MaxRateAge.Milliseconds() {
+			continue
+		}
+		g.add(r.base, r.quote, r.mid)
+		g.add(r.quote, r.base, 1/r.mid)
+		quoted[[2]string{r.base, r.quote}] = true
+		quoted[[2]string{r.quote, r.base}] = true
+	}
+	ratesMu.Unlock()
+
+	for asset, peg := range cfg.Pegs {
+		if !quoted[[2]string{asset, peg}] {
+			g.add(asset, peg, 1)
+			g.add(peg, asset, 1)
+		}
+	}
+	// sorted so an asset reachable along several equally short paths always takes the same one
+	for asset := range g {
+		edges := g[asset]
+		sort.Slice(edges, func(i, j int) bool { return edges[i].to < edges[j].to })
+	}
+	return g
+}
+
+// rateTo returns how much currency one unit of asset is worth along the path through the
+// fewest symbols; ok is false when currency isn't reachable within maxHops.
+func (g graph) rateTo(asset, currency string) (float64, bool) {
+	if asset == currency {
+		return 1, true
+	}
+	type step struct {
+		asset string
+		rate  float64
+	}
+	visited := map[string]bool{asset: true}
+	frontier := []step{{asset: asset, rate: 1}}
+	for hop := 0; hop < maxHops && len(frontier) > 0; hop++ {
+		var next []step
+		for _, s := range frontier {
+			for _, e := range g[s.asset] {
+				if visited[e.to] {
+					continue
+				}
+				if e.to == currency {
+					return s.rate * e.rate, true
+				}
+				visited[e.to] = true
+				next = append(next, step{asset: e.to, rate: s.rate * e.rate})
+			}
+		}
+		frontier = next
+	}
+	return 0, false
+}
@@ -0,0 +1,431 @@
+package portfolio
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/turgaysozen/algotrading/config"
+	"github.com/turgaysozen/algotrading/db"
+	"github.com/turgaysozen/algotrading/models"
+	"github.com/turgaysozen/algotrading/monitoring/metrics"
+)
+
+// Portfolio risk is estimated from the stored bars: every exposed asset is modelled with the
+// returns of a symbol quoting it in cash, and a position's one-bar PnL is its value in the
+// reporting currency times its asset's return. Historical VaR replays the joint returns of
+// the lookback; parametric VaR assumes them normal with the sample mean and covariance.
+
+// VaR methods, as in RISK_VAR_METHOD.
+const (
+	Historical = "historical"
+	Parametric = "parametric"
+)
+
+// minObservations returns an asset needs before it is modelled.
+const minObservations = 30
+
+// model holds the aligned bar returns of every modelled asset and their moments.
+type model struct {
+	assets  []string
+	symbols []string
+	// returns[t][i] is asset i's return over bar t
+	returns [][]float64
+	mean    []float64
+	cov     [][]float64
+}
+
+var (
+	modelMu sync.Mutex
+	current *model
+)
+
+// RefreshRisk re-estimates the model from the stored bars, then exports the portfolio's risk.
+func RefreshRisk() {
+	c := getConfig()
+	m, err := buildModel(c, clk.Now())
+	if err != nil {
+		log.Printf("Error estimating portfolio risk: %v", err)
+		metrics.RecordError("portfolio_risk_error")
+		return
+	}
+	modelMu.Lock()
+	current = m
+	modelMu.Unlock()
+
+	report, err := Risk()
+	if err != nil {
+		log.Printf("Error reading balances for portfolio risk: %v", err)
+		metrics.RecordError("portfolio_balance_error")
+		return
+	}
+	metrics.SetPortfolioRisk(report.Currency, Historical, report.HistoricalVaR, report.HistoricalES)
+	metrics.SetPortfolioRisk(report.Currency, Parametric, report.ParametricVaR, report.ParametricES)
+	metrics.SetPortfolioConcentration(report.Concentration)
+	metrics.ResetSymbolCorrelations()
+	for i := range report.Symbols {
+		for j := i + 1; j < len(report.Symbols); j++ {
+			metrics.SetSymbolCorrelation(report.Symbols[i], report.Symbols[j], report.Correlations[i][j])
+		}
+	}
+	log.Printf("Portfolio risk over %d %s bars at %.1f%%: historical VaR %.2f, ES %.2f, parametric VaR %.2f, ES %.2f %s",
+		report.Observations, report.Timeframe, report.Confidence*100, report.HistoricalVaR, report.HistoricalES,
+		report.ParametricVaR, report.ParametricES, report.Currency)
+}
+
+// buildModel picks a series for every asset quoted in cash, preferring symbols quoted in the
+// reporting currency itself, and aligns their returns on the bars they all have.
+func buildModel(c Config, now time.Time) (*model, error) {
+	since := now.Add(-c.RiskLookback)
+	symbols, err := db.GetBarSymbols(c.RiskTimeframe, since)
+	if err != nil {
+		return nil, err
+	}
+
+	cash := cashAssets(c)
+	series := make(map[string]string)
+	for _, preferred := range []bool{true, false} {
+		for _, symbol := range symbols {
+			base, quote, ok := config.SplitSymbol(symbol)
+			if !ok || cash[base] || !cash[quote] || (quote == c.Currency) != preferred {
+				continue
+			}
+			if _, ok := series[base]; !ok {
+				series[base] = symbol
+			}
+		}
+	}
+
+	closes := make(map[string]map[int64]float64)
+	var times map[int64]bool
+	m := &model{}
+	assets := make([]string, 0, len(series))
+	for asset := range series {
+		assets = append(assets, asset)
+	}
+	sort.Strings(assets)
+	for _, asset := range assets {
+		bars, err := db.GetBarsSince(series[asset], c.RiskTimeframe, since)
+		if err != nil {
+			return nil, err
+		}
+		if len(bars) <= minObservations {
+			continue
+		}
+		byTime := make(map[int64]float64, len(bars))
+		for _, bar := range bars {
+			byTime[bar.OpenTime] = bar.Close
+		}
+		// the common bars are kept only while enough remain, so one short history can't cut the rest
+		common := make(map[int64]bool)
+		for t := range byTime {
+			if times == nil || times[t] {
+				common[t] = true
+			}
+		}
+		if len(common) <= minObservations {
+			continue
+		}
+		times = common
+		closes[asset] = byTime
+		m.assets = append(m.assets, asset)
+		m.symbols = append(m.symbols, series[asset])
+	}
+
+	aligned := make([]int64, 0, len(times))
+	for t := range times {
+		aligned = append(aligned, t)
+	}
+	sort.Slice(aligned, func(i, j int) bool { return aligned[i] < aligned[j] })
+
+	for k := 1; k < len(aligned); k++ {
+		r := make([]float64, len(m.assets))
+		for i, asset := range m.assets {
+			r[i] = closes[asset][aligned[k]]/closes[asset][aligned[k-1]] - 1
+		}
+		m.returns = append(m.returns, r)
+	}
+	m.estimate()
+	return m, nil
+}
+
+// estimate computes the sample mean and covariance of the returns.
+func (m *model) estimate() {
+	n, k := len(m.returns), len(m.assets)
+	m.mean = make([]float64, k)
+	m.cov = make([][]float64, k)
+	for i := range m.cov {
+		m.cov[i] = make([]float64, k)
+	}
+	if n < 2 {
+		return
+	}
+	for _, r := range m.returns {
+		for i := range r {
+			m.mean[i] += r[i] / float64(n)
+		}
+	}
+	for _, r := range m.returns {
+		for i := 0; i < k; i++ {
+			for j := i; j < k; j++ {
+				m.cov[i][j] += (r[i] - m.mean[i]) * (r[j] - m.mean[j]) / float64(n-1)
+			}
+		}
+	}
+	for i := 0; i < k; i++ {
+		for j := 0; j < i; j++ {
+			m.cov[i][j] = m.cov[j][i]
+		}
+	}
+}
+
+// weights returns exposures as a vector in the model's asset order, with the exposed assets
+// the model doesn't cover.
+func (m *model) weights(exposures map[string]float64) ([]float64, []string) {
+	w := make([]float64, len(m.assets))
+	covered := make(map[string]bool, len(m.assets))
+	for i, asset := range m.assets {
+		w[i] = exposures[asset]
+		covered[asset] = true
+	}
+	var unmodelled []string
+	for asset, v := range exposures {
+		if !covered[asset] && v != 0 {
+			unmodelled = append(unmodelled, asset)
+		}
+	}
+	sort.Strings(unmodelled)
+	return w, unmodelled
+}
+
+// historical returns the VaR and ES of the losses w would have made over the model's bars.
+func (m *model) historical(w []float64, confidence float64) (float64, float64) {
+	if len(m.returns) == 0 {
+		return 0, 0
+	}
+	losses := make([]float64, len(m.returns))
+	for t, r := range m.returns {
+		for i := range w {
+			losses[t] -= w[i] * r[i]
+		}
+	}
+	sort.Float64s(losses)
+	index := int(math.Ceil(confidence*float64(len(losses)))) - 1
+	index = min(max(index, 0), len(losses)-1)
+
+	var tail float64
+	for _, loss := range losses[index:] {
+		tail += loss
+	}
+	return losses[index], tail / float64(len(losses)-index)
+}
+
+// parametric returns the VaR and ES of w's loss assuming normal returns.
+func (m *model) parametric(w []float64, confidence float64) (float64, float64) {
+	var mean, variance float64
+	for i := range w {
+		mean += w[i] * m.mean[i]
+		for j := range w {
+			variance += w[i] * w[j] * m.cov[i][j]
+		}
+	}
+	sigma := math.Sqrt(math.Max(variance, 0))
+	z := math.Sqrt2 * math.Erfinv(2*confidence-1)
+	density := math.Exp(-z*z/2) / math.Sqrt(2*math.Pi)
+	return -mean + z*sigma, -mean + sigma*density/(1-confidence)
+}
+
+func (m *model) correlations() [][]float64 {
+	k := len(m.assets)
+	corr := make([][]float64, k)
+	for i := range corr {
+		corr[i] = make([]float64, k)
+		for j := range corr[i] {
+			switch {
+			case i == j:
+				corr[i][j] = 1
+			case m.cov[i][i] > 0 && m.cov[j][j] > 0:
+				corr[i][j] = m.cov[i][j] / math.Sqrt(m.cov[i][i]*m.cov[j][j])
+			}
+		}
+	}
+	return corr
+}
+
+// concentration returns the Herfindahl index of the exposures' shares of gross exposure.
+func concentration(exposures map[string]float64) float64 {
+	var gross float64
+	for _, v := range exposures {
+		gross += math.Abs(v)
+	}
+	if gross == 0 {
+		return 0
+	}
+	var hhi float64
+	for _, v := range exposures {
+		share := math.Abs(v) / gross
+		hhi += share * share
+	}
+	return hhi
+}
+
+// exposures returns the value of every non-cash asset in p.
+func exposures(c Config, p models.Portfolio) map[string]float64 {
+	cash := cashAssets(c)
+	values := make(map[string]float64)
+	for _, a := range p.Assets {
+		if !cash[a.Asset] {
+			values[a.Asset] = a.Value.Float64()
+		}
+	}
+	return values
+}
+
+func currentModel() (*model, error) {
+	modelMu.Lock()
+	defer modelMu.Unlock()
+	if current == nil {
+		return nil, fmt.Errorf("portfolio risk hasn't been estimated yet")
+	}
+	return current, nil
+}
+
+// Risk returns the current portfolio's VaR, ES, correlations and concentration.
+func Risk() (models.RiskReport, error) {
+	c := getConfig()
+	m, err := currentModel()
+	if err != nil {
+		return models.RiskReport{}, err
+	}
+	p, err := Value()
+	if err != nil {
+		return models.RiskReport{}, err
+	}
+
+	values := exposures(c, p)
+	w, unmodelled := m.weights(values)
+	unmodelled = append(unmodelled, p.Unpriced...)
+	sort.Strings(unmodelled)
+	report := models.RiskReport{
+		Currency:      c.Currency,
+		Timeframe:     c.RiskTimeframe,
+		Confidence:    c.VaRConfidence,
+		Observations:  len(m.returns),
+		Concentration: concentration(values),
+		Symbols:       m.symbols,
+		Correlations:  m.correlations(),
+		Unmodelled:    unmodelled,
+		Time:          p.Time,
+	}
+	report.HistoricalVaR, report.HistoricalES = m.historical(w, c.VaRConfidence)
+	report.ParametricVaR, report.ParametricES = m.parametric(w, c.VaRConfidence)
+	return report, nil
+}
+
+// Projection is the portfolio's VaR by RISK_VAR_METHOD and each exposed asset's share of
+// equity, before and after a set of orders fills.
+type Projection struct {
+	VaR              float64
+	ProjectedVaR     float64
+	Weights          map[string]float64
+	ProjectedWeights map[string]float64
+	// Unmodelled lists the assets the VaR can't account for: ones the orders trade without an
+	// estimate or a rate to value them at, and holdings left unpriced by the valuation
+	Unmodelled []string
+}
+
+// Project values orders at the latest rates and adds them to the portfolio as last valued by
+// the snapshots or the risk refresh, so a check doesn't wait on the exchange's balances; ok
+// is false before the first risk estimate or valuation.
+func Project(orders []models.Order) (Projection, bool) {
+	c := getConfig()
+	m, err := currentModel()
+	if err != nil {
+		return Projection{}, false
+	}
+	p, ok := latestValue()
+	if !ok {
+		return Projection{}, false
+	}
+
+	before := exposures(c, p)
+	after := make(map[string]float64, len(before))
+	for asset, v := range before {
+		after[asset] = v
+	}
+
+	cash := cashAssets(c)
+	g := liveGraph(c)
+	unmodelled := make(map[string]bool)
+	for _, asset := range p.Unpriced {
+		unmodelled[asset] = true
+	}
+	traded := make(map[string]bool)
+	for _, order := range orders {
+		base, quote, ok := config.SplitSymbol(order.Symbol)
+		if !ok {
+			unmodelled[order.Symbol] = true
+			continue
+		}
+		traded[base], traded[quote] = true, true
+		baseRate, baseOK := g.rateTo(base, c.Currency)
+		quoteRate, quoteOK := g.rateTo(quote, c.Currency)
+		if !baseOK || !quoteOK {
+			log.Printf("No rate to %s for %s, left out of the projected portfolio", c.Currency, order.Symbol)
+			unmodelled[order.Symbol] = true
+			continue
+		}
+		value := order.Quantity.Float64() * baseRate
+		notional := order.Quantity.Mul(order.Price).Float64() * quoteRate
+		if order.OrderType == "sell" {
+			value, notional = -value, -notional
+		}
+		after[base] += value
+		if !cash[quote] {
+			after[quote] -= notional
+		}
+	}
+
+	wBefore, _ := m.weights(before)
+	wAfter, uncovered := m.weights(after)
+	for _, asset := range uncovered {
+		if traded[asset] {
+			unmodelled[asset] = true
+		}
+	}
+	projection := Projection{
+		VaR:              pickVaR(m, c, wBefore),
+		ProjectedVaR:     pickVaR(m, c, wAfter),
+		Weights:          shares(before, p.Equity.Float64()),
+		ProjectedWeights: shares(after, p.Equity.Float64()),
+	}
+	for asset := range unmodelled {
+		projection.Unmodelled = append(projection.Unmodelled, asset)
+	}
+	sort.Strings(projection.Unmodelled)
+	return projection, true
+}
+
+// shares returns each exposure's absolute value as a share of equity, none without positive equity.
+func shares(exposures map[string]float64, equity float64) map[string]float64 {
+	weights := make(map[string]float64, len(exposures))
+	if equity <= 0 {
+		return weights
+	}
+	for asset, v := range exposures {
+		weights[asset] = math.Abs(v) / equity
+	}
+	return weights
+}
+
+func pickVaR(m *model, c Config, w []float64) float64 {
+	if c.VaRMethod == Parametric {
+		v, _ := m.parametric(w, c.VaRConfidence)
+		return v
+	}
+	v, _ := m.historical(w, c.VaRConfidence)
+	return v
+}
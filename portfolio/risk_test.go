@@ -0,0 +1,135 @@
+package portfolio
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/turgaysozen/algotrading/decimal"
+	"github.com/turgaysozen/algotrading/models"
+)
+
+// btcReturns lose 10% and 2% on two of four bars, so a 100 long's losses sort to -5, -2, 2, 10.
+var btcReturns = [][]float64{{-0.1}, {0.05}, {0.02}, {-0.02}}
+
+func TestHistorical(t *testing.T) {
+	tests := []struct {
+		name            string
+		returns         [][]float64
+		w               []float64
+		confidence      float64
+		wantVaR, wantES float64
+	}{
+		{"long at 75%", btcReturns, []float64{100}, 0.75, 2, 6},
+		{"long at 99% takes the worst bar", btcReturns, []float64{100}, 0.99, 10, 10},
+		{"short loses on the rallies", btcReturns, []float64{-100}, 0.75, 2, 3.5},
+		{"flat", btcReturns, []float64{0}, 0.75, 0, 0},
+		{"no bars", nil, []float64{100}, 0.99, 0, 0},
+		{"offsetting assets", [][]float64{{-0.1, -0.1}, {0.05, 0.05}}, []float64{100, -100}, 0.99, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &model{returns: tt.returns}
+			v, es := m.historical(tt.w, tt.confidence)
+			if !near(v, tt.wantVaR) || !near(es, tt.wantES) {
+				t.Errorf("historical = %v, %v, want %v, %v", v, es, tt.wantVaR, tt.wantES)
+			}
+		})
+	}
+}
+
+func TestParametric(t *testing.T) {
+	tests := []struct {
+		name            string
+		mean            []float64
+		cov             [][]float64
+		w               []float64
+		wantVaR, wantES float64
+	}{
+		{"2% volatility", []float64{0}, [][]float64{{0.0004}}, []float64{100}, 3.919928, 4.675606},
+		{"positive drift lowers the loss", []float64{0.001}, [][]float64{{0.0004}}, []float64{100}, 3.819928, 4.575606},
+		{"hedged by a correlated short", []float64{0, 0}, [][]float64{{0.0004, 0.0001}, {0.0001, 0.0009}},
+			[]float64{100, -50}, 4.490842, 5.356579},
+		{"flat", []float64{0}, [][]float64{{0.0004}}, []float64{0}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &model{mean: tt.mean, cov: tt.cov}
+			v, es := m.parametric(tt.w, 0.975)
+			if !near(v, tt.wantVaR) || !near(es, tt.wantES) {
+				t.Errorf("parametric = %v, %v, want %v, %v", v, es, tt.wantVaR, tt.wantES)
+			}
+		})
+	}
+}
+
+func TestProject(t *testing.T) {
+	cfgOnce.Do(func() {})
+	savedCfg := cfg
+	cfg = Config{Currency: "USDT", MaxRateAge: time.Minute, Pegs: DefaultPegs, VaRConfidence: 0.75, VaRMethod: Historical}
+	m := &model{assets: []string{"BTC"}, symbols: []string{"BTCUSDT"}, returns: btcReturns}
+	m.estimate()
+	modelMu.Lock()
+	current = m
+	modelMu.Unlock()
+	t.Cleanup(func() {
+		cfg = savedCfg
+		modelMu.Lock()
+		current = nil
+		modelMu.Unlock()
+		latestMu.Lock()
+		latest = nil
+		latestMu.Unlock()
+	})
+
+	UpdateRate("BTCUSDT", 99.99, 100.01, 1704067200000)
+	UpdateRate("ETHUSDT", 9.99, 10.01, 1704067200000)
+
+	order := func(symbol, side string) models.Order {
+		return models.Order{Symbol: symbol, OrderType: side, Quantity: decimal.FromInt(1), Price: decimal.FromInt(100)}
+	}
+	tests := []struct {
+		name               string
+		orders             []models.Order
+		unpriced           []string
+		wantVaR, wantAfter float64
+		btcWeightAfter     float64
+		unmodelled         []string
+	}{
+		{"buy doubles the long", []models.Order{order("BTCUSDT", "buy")}, nil, 2, 4, 0.02, nil},
+		{"sell flattens it", []models.Order{order("BTCUSDT", "sell")}, nil, 2, 0, 0, nil},
+		{"unmodelled asset", []models.Order{order("ETHUSDT", "buy")}, nil, 2, 2, 0.01, []string{"ETH"}},
+		{"unknown quote", []models.Order{order("BTCXYZ", "buy")}, nil, 2, 2, 0.01, []string{"BTCXYZ"}},
+		{"unpriced holding", []models.Order{order("BTCUSDT", "buy")}, []string{"DOGE"}, 2, 4, 0.02, []string{"DOGE"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			latestMu.Lock()
+			latest = &models.Portfolio{Currency: "USDT", Equity: decimal.FromInt(10000), Unpriced: tt.unpriced,
+				Assets: []models.AssetValue{
+					{Asset: "BTC", Quantity: decimal.FromInt(1), Price: decimal.FromInt(100), Value: decimal.FromInt(100)},
+					{Asset: "USDT", Quantity: decimal.FromInt(9900), Price: decimal.FromInt(1), Value: decimal.FromInt(9900)},
+				}}
+			latestMu.Unlock()
+
+			projection, ok := Project(tt.orders)
+			if !ok {
+				t.Fatal("no projection")
+			}
+			if !near(projection.VaR, tt.wantVaR) || !near(projection.ProjectedVaR, tt.wantAfter) {
+				t.Errorf("VaR %v to %v, want %v to %v", projection.VaR, projection.ProjectedVaR, tt.wantVaR, tt.wantAfter)
+			}
+			if got := projection.ProjectedWeights["BTC"]; !near(got, tt.btcWeightAfter) {
+				t.Errorf("projected BTC weight = %v, want %v", got, tt.btcWeightAfter)
+			}
+			if !reflect.DeepEqual(projection.Unmodelled, tt.unmodelled) {
+				t.Errorf("unmodelled = %v, want %v", projection.Unmodelled, tt.unmodelled)
+			}
+		})
+	}
+}
+
+func near(got, want float64) bool {
+	return math.Abs(got-want) < 1e-6
+}
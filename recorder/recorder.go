@@ -0,0 +1,192 @@
+package recorder
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/turgaysozen/algotrading/monitoring/metrics"
+)
+
+// Recording files are gzip compressed and hold a magic header followed by frames of
+// [receive time unix nanos int64][length uint32][raw frame], all big endian.
+const (
+	fileMagic     = "ALGOREC1"
+	fileExtension = ".rec.gz"
+	queueSize     = 10_000
+	flushInterval = time.Second
+)
+
+type frame struct {
+	received time.Time
+	data     []byte
+}
+
+var (
+	mu      sync.Mutex
+	queue   chan frame
+	stopped chan struct{}
+)
+
+// Start begins recording raw frames into dir, rotating files after maxBytes of
+// uncompressed frames or rotateEvery, whichever comes first.
+func Start(dir string, maxBytes int64, rotateEvery time.Duration) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if queue != nil {
+		return fmt.Errorf("recorder already running")
+	}
+
+	queue = make(chan frame, queueSize)
+	stopped = make(chan struct{})
+	go run(dir, maxBytes, rotateEvery, queue, stopped)
+
+	log.Println("Recording raw feed frames to", dir)
+	return nil
+}
+
+// Record queues a raw frame with its receive time. It never blocks the feed:
+// when the writer falls behind the frame is dropped and counted as data loss.
+func Record(data []byte) {
+	mu.Lock()
+	defer mu.Unlock()
+	if queue == nil {
+		return
+	}
+
+	select {
+	case queue <- frame{received: time.Now(), data: data}:
+	default:
+		metrics.RecordDataLoss("recorder_queue_full")
+	}
+}
+
+// Stop flushes queued frames and closes the current file.
+func Stop() {
+	mu.Lock()
+	q, done := queue, stopped
+	queue = nil
+	mu.Unlock()
+	if q == nil {
+		return
+	}
+
+	close(q)
+	<-done
+}
+
+type segment struct {
+	file    *os.File
+	buf     *bufio.Writer
+	gz      *gzip.Writer
+	opened  time.Time
+	written int64
+}
+
+func run(dir string, maxBytes int64, rotateEvery time.Duration, q <-chan frame, done chan<- struct{}) {
+	defer close(done)
+
+	var current *segment
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case f, ok := <-q:
+			if !ok {
+				current.close()
+				return
+			}
+
+			if current != nil && (current.written >= maxBytes || time.Since(current.opened) >= rotateEvery) {
+				current.close()
+				current = nil
+			}
+			if current == nil {
+				var err error
+				current, err = openSegment(dir, f.received)
+				if err != nil {
+					log.Println("Error opening recording file:", err)
+					metrics.RecordError("recorder_open_error")
+					metrics.RecordDataLoss("recorder_frame_data_loss")
+					continue
+				}
+			}
+
+			if err := current.write(f); err != nil {
+				log.Println("Error writing recording frame:", err)
+				metrics.RecordError("recorder_write_error")
+				metrics.RecordDataLoss("recorder_frame_data_loss")
+			}
+		case <-ticker.C:
+			// bound what a crash can lose without flushing on every frame
+			if current != nil {
+				if err := current.flush(); err != nil {
+					metrics.RecordError("recorder_write_error")
+				}
+			}
+		}
+	}
+}
+
+func openSegment(dir string, opened time.Time) (*segment, error) {
+	name := filepath.Join(dir, "feed-"+opened.UTC().Format("20060102T150405.000000000Z")+fileExtension)
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := bufio.NewWriter(file)
+	gz := gzip.NewWriter(buf)
+	if _, err := gz.Write([]byte(fileMagic)); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	log.Println("Recording to", name)
+	return &segment{file: file, buf: buf, gz: gz, opened: opened}, nil
+}
+
+func (s *segment) write(f frame) error {
+	var header [12]byte
+	binary.BigEndian.PutUint64(header[:8], uint64(f.received.UnixNano()))
+	binary.BigEndian.PutUint32(header[8:], uint32(len(f.data)))
+	if _, err := s.gz.Write(header[:]); err != nil {
+		return err
+	}
+	if _, err := s.gz.Write(f.data); err != nil {
+		return err
+	}
+	s.written += int64(len(header) + len(f.data))
+	return nil
+}
+
+func (s *segment) flush() error {
+	if err := s.gz.Flush(); err != nil {
+		return err
+	}
+	return s.buf.Flush()
+}
+
+func (s *segment) close() {
+	if s == nil {
+		return
+	}
+	if err := s.gz.Close(); err != nil {
+		log.Println("Error closing recording file:", err)
+	}
+	if err := s.buf.Flush(); err != nil {
+		log.Println("Error closing recording file:", err)
+	}
+	s.file.Close()
+}
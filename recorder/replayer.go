@@ -0,0 +1,119 @@
+package recorder
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Frame is one recorded raw WebSocket message.
+type Frame struct {
+	Received time.Time
+	Data     []byte
+}
+
+// Replay feeds every frame in paths to handler in recording order. Directories are
+// expanded to the recording files they contain. speed scales the original gaps
+// between frames: 1 is real time, 10 is ten times faster and 0 is as fast as possible.
+func Replay(paths []string, speed float64, handler func(Frame)) (int, error) {
+	files, err := recordingFiles(paths)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	var previous time.Time
+	for _, name := range files {
+		err := ReadFile(name, func(f Frame) error {
+			if speed > 0 && !previous.IsZero() {
+				if gap := f.Received.Sub(previous); gap > 0 {
+					time.Sleep(time.Duration(float64(gap) / speed))
+				}
+			}
+			previous = f.Received
+
+			handler(f)
+			count++
+			return nil
+		})
+		if err != nil {
+			return count, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return count, nil
+}
+
+// ReadFile calls fn with every frame in a recording file, stopping at the first error fn returns.
+// A truncated last frame, left by a crash, ends the file without an error.
+func ReadFile(name string, fn func(Frame) error) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(bufio.NewReader(file))
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	magic := make([]byte, len(fileMagic))
+	if _, err := io.ReadFull(gz, magic); err != nil || string(magic) != fileMagic {
+		return errors.New("not a feed recording")
+	}
+
+	var header [12]byte
+	for {
+		if _, err := io.ReadFull(gz, header[:]); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return nil
+			}
+			return err
+		}
+
+		data := make([]byte, binary.BigEndian.Uint32(header[8:]))
+		if _, err := io.ReadFull(gz, data); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return nil
+			}
+			return err
+		}
+
+		f := Frame{Received: time.Unix(0, int64(binary.BigEndian.Uint64(header[:8]))), Data: data}
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+}
+
+func recordingFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			if strings.HasSuffix(path, fileExtension) {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// names embed the UTC open time, so lexical order is recording order
+	sort.Slice(files, func(i, j int) bool { return filepath.Base(files[i]) < filepath.Base(files[j]) })
+	return files, nil
+}
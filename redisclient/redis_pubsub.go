@@ -2,81 +2,81 @@ package redisclient
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"log"
 	"os"
-	"time"
 
 	"github.com/redis/go-redis/v9"
+	"github.com/turgaysozen/algotrading/codec"
 	"github.com/turgaysozen/algotrading/models"
 	"github.com/turgaysozen/algotrading/monitoring/metrics"
+	"github.com/turgaysozen/algotrading/retry"
 	"github.com/turgaysozen/algotrading/services"
 )
 
 var ctx = context.Background()
-var connected bool = false
 var redisClient *redis.Client
+var busContentType byte
 
+// symbolQueueSize is how far a symbol's worker can fall behind before Subscribe waits for it.
+const symbolQueueSize = 1024
+
+// symbolQueues holds one worker queue per symbol, used only by Subscribe's goroutine.
+var symbolQueues = make(map[string]chan func())
+
+// NewRedisClient waits for Redis according to the REDIS_RETRY_* policy. If the policy gives
+// up, the client is still returned: go-redis reconnects on its own once Redis is back,
+// and until then readiness reports Redis as unreachable.
 func NewRedisClient() *redis.Client {
 	redisHost := os.Getenv("REDIS_HOST")
 	redisPort := os.Getenv("REDIS_PORT")
 
-	addr := redisHost + ":" + redisPort
-	maxRetries := 5
-	retryCount := 0
-
-	var client *redis.Client
+	client := redis.NewClient(&redis.Options{
+		Addr: redisHost + ":" + redisPort,
+	})
 
-	for {
-		client = redis.NewClient(&redis.Options{
-			Addr: addr,
-		})
-
-		_, err := client.Ping(context.Background()).Result()
+	err := retry.Do("redis", retry.LoadPolicy("REDIS"), func() error {
+		_, err := client.Ping(ctx).Result()
 		if err != nil {
-			connected = false
-			retryCount++
-			log.Printf("Error connecting to Redis, retrying %d/%d... %v", retryCount, maxRetries, err)
 			metrics.RecordError("redis_connection_error")
-			client.Close()
-			if retryCount >= maxRetries {
-				log.Fatal("Max retry attempts reached, giving up.")
-				metrics.RecordDataLoss("redis_connection_max_retries")
-			}
-			time.Sleep(5 * time.Second)
-			continue
 		}
-
-		if !connected {
-			log.Println("Connected to Redis")
-			connected = true
-		}
-
-		break
+		return err
+	})
+	if err != nil {
+		metrics.RecordDataLoss("redis_connection_max_retries")
+		return client
 	}
 
+	log.Println("Connected to Redis")
 	return client
 }
 
 func InitRedisClient() {
 	if redisClient == nil {
+		busContentType = codec.ContentTypeFromString(os.Getenv("BUS_ENCODING"))
 		redisClient = NewRedisClient()
 	}
 }
 
 func RedisHealth() error {
+	if redisClient == nil {
+		return errors.New("redis client not initialized")
+	}
+
 	_, err := redisClient.Ping(context.Background()).Result()
 	if err != nil {
 		log.Println("Error pinging Redis:", err)
+		metrics.SetConnectionUp("redis", false)
 		return err
 	}
+	metrics.SetConnectionUp("redis", true)
 	return nil
 }
 
 func Publish(channel string, message interface{}) {
 	InitRedisClient()
 
-	data, err := json.Marshal(message)
+	data, err := codec.Encode(busContentType, message)
 	if err != nil {
 		log.Println("Error serializing object:", err)
 		metrics.RecordError("redis_serialization_error")
@@ -93,18 +93,51 @@ func Publish(channel string, message interface{}) {
 func Subscribe() {
 	InitRedisClient()
 
-	sub := redisClient.Subscribe(ctx, "order_book")
+	sub := redisClient.Subscribe(ctx, "order_book", "trades")
 
 	ch := sub.Channel()
 	for msg := range ch {
-		var orderBook models.OrderBook
-		err := json.Unmarshal([]byte(msg.Payload), &orderBook)
-		if err != nil {
-			log.Println("Error unmarshalling Redis message:", err)
-			metrics.RecordError("redis_unmarshal_error")
-			continue
+		switch msg.Channel {
+		case "trades":
+			var trade models.Trade
+			err := codec.Decode([]byte(msg.Payload), &trade)
+			if err != nil {
+				log.Println("Error unmarshalling Redis trade message:", err)
+				metrics.RecordError("redis_unmarshal_error")
+				continue
+			}
+
+			dispatch(trade.Symbol, func() { services.ProcessTrade(trade) })
+		default:
+			var orderBook models.OrderBook
+			err := codec.Decode([]byte(msg.Payload), &orderBook)
+			if err != nil {
+				log.Println("Error unmarshalling Redis message:", err)
+				var levelErr *models.PriceLevelError
+				if errors.As(err, &levelErr) {
+					metrics.RecordTickRejection(models.RejectMalformedPrice)
+				}
+				metrics.RecordError("redis_unmarshal_error")
+				continue
+			}
+
+			dispatch(orderBook.Symbol, func() { services.ProcessOrderBook(orderBook) })
 		}
+	}
+}
 
-		go services.ProcessOrderBook(orderBook)
+// dispatch hands process to symbol's worker, which runs a symbol's books and trades one at a
+// time in the order they were published, while different symbols are processed in parallel.
+func dispatch(symbol string, process func()) {
+	queue, ok := symbolQueues[symbol]
+	if !ok {
+		queue = make(chan func(), symbolQueueSize)
+		symbolQueues[symbol] = queue
+		go func() {
+			for process := range queue {
+				process()
+			}
+		}()
 	}
+	queue <- process
 }
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/turgaysozen/algotrading/bars"
+	"github.com/turgaysozen/algotrading/clock"
+	"github.com/turgaysozen/algotrading/db"
+	"github.com/turgaysozen/algotrading/execution"
+	"github.com/turgaysozen/algotrading/recorder"
+	"github.com/turgaysozen/algotrading/services"
+	"github.com/turgaysozen/algotrading/wsclient"
+)
+
+// runReplay implements the "replay" subcommand. Recorded frames go through the same
+// decoding and strategy code as live frames, but inline and in order instead of via
+// Redis, into an in-memory store rather than the database, so replaying the same recording
+// always yields the same signals and orders and never touches live ones.
+func runReplay(args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	speed := flags.Float64("speed", 0, "1 replays in real time, 10 ten times faster, 0 as fast as possible")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return errors.New("replay: no recording files or directories given")
+	}
+
+	store, frames, err := replay(flags.Args(), *speed)
+	log.Printf("Replayed %d frames: %d signals, %d orders", frames, len(store.Signals()), len(store.Orders()))
+	report, _ := store.GetStrategyPnL()
+	for _, r := range report {
+		log.Printf("Strategy %s: %d orders, gross PnL %s, fees %s, net PnL %s", r.Strategy, r.Orders, r.GrossPnL, r.Fees, r.NetPnL)
+	}
+	return err
+}
+
+// replay runs the recordings in paths through the strategy and returns the store holding
+// what it saved and how many frames it replayed.
+func replay(paths []string, speed float64) (*db.Memory, int, error) {
+	store := db.NewMemory()
+	db.Use(store)
+
+	bars.Init()
+	execution.Init()
+	services.InitStrategy()
+
+	// books are rebuilt from the recorded snapshot frames, never from the exchange
+	if os.Getenv("DEPTH_SNAPSHOT_URL") != "" {
+		wsclient.UseLocalBooks("")
+	}
+
+	// stamp anything without an exchange time with the original receive time, not today's
+	sim := clock.NewSimulated(time.Time{})
+	services.SetClock(sim)
+
+	frames, err := recorder.Replay(paths, speed, func(f recorder.Frame) {
+		sim.Set(f.Received)
+		wsclient.HandleMessage(f.Data, services.ProcessOrderBook, services.ProcessTrade)
+		// bar strategies see each frame's closed bars before the next frame moves the book
+		bars.Flush()
+	})
+	return store, frames, err
+}
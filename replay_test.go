@@ -0,0 +1,73 @@
+package main
+
+import "testing"
+
+// testdata/btcusdt.rec.gz holds 600 BTCUSDT depth updates 100ms apart, the mid following one
+// sine wave period around 30000 every 300 updates, and a trade every 50 updates.
+func TestReplayRecording(t *testing.T) {
+	t.Setenv("DEPTH_SNAPSHOT_URL", "")
+	t.Setenv("STRATEGY_TIMEFRAME", "tick")
+	t.Setenv("STRATEGY_EXCHANGE", "binance")
+	t.Setenv("EXECUTION_ALGO", "")
+	t.Setenv("ORDER_TYPE", "market")
+	t.Setenv("FEES", "")
+	t.Setenv("FEES_SYMBOLS", "")
+	t.Setenv("PAIRS", "")
+	t.Setenv("RISK_MAX_ORDER_NOTIONAL", "0")
+
+	store, frames, err := replay([]string{"testdata/btcusdt.rec.gz"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frames != 612 {
+		t.Errorf("replayed %d frames, want 612", frames)
+	}
+
+	wantSignals := []struct {
+		typ       string
+		price     float64
+		eventTime int64
+	}{
+		{"NO Signal", 29743.39, 1704067219900},
+		{"BUY Signal!", 29734.14, 1704067224800},
+		{"SELL Signal!", 29968.64, 1704067245500},
+	}
+	signals := store.Signals()
+	if len(signals) != len(wantSignals) {
+		t.Fatalf("got %d signals, want %d: %+v", len(signals), len(wantSignals), signals)
+	}
+	for i, want := range wantSignals {
+		got := signals[i]
+		if got.Symbol != "BTCUSDT" || got.Type != want.typ || got.Price != want.price || got.EventTime != want.eventTime {
+			t.Errorf("signal %d = %s %s at %v, %d; want %s at %v, %d", i, got.Symbol, got.Type, got.Price, got.EventTime,
+				want.typ, want.price, want.eventTime)
+		}
+	}
+
+	// market orders fill half the 1.00 spread away from the mid and each signal closes the previous order
+	wantOrders := []struct {
+		clientOrderID string
+		side          string
+		price         string
+		status        string
+		closePrice    string
+		pnl           string
+	}{
+		{"sma-BTCUSDT-1704067219900", "sell", "29742.89", "closed", "29734.64", "-51.22753"},
+		{"sma-BTCUSDT-1704067224800", "buy", "29734.64", "closed", "29968.14", "173.79722"},
+		{"sma-BTCUSDT-1704067245500", "sell", "29968.14", "open", "0", "0"},
+	}
+	orders := store.Orders()
+	if len(orders) != len(wantOrders) {
+		t.Fatalf("got %d orders, want %d: %+v", len(orders), len(wantOrders), orders)
+	}
+	for i, want := range wantOrders {
+		got := orders[i]
+		if got.ClientOrderID != want.clientOrderID || got.OrderType != want.side || got.Status != want.status ||
+			got.Price.String() != want.price || got.ClosePrice.String() != want.closePrice || got.PnL.String() != want.pnl {
+			t.Errorf("order %d = %s %s at %s, %s at %s, PnL %s; want %s %s at %s, %s at %s, PnL %s", i,
+				got.ClientOrderID, got.OrderType, got.Price, got.Status, got.ClosePrice, got.PnL,
+				want.clientOrderID, want.side, want.price, want.status, want.closePrice, want.pnl)
+		}
+	}
+}
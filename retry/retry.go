@@ -0,0 +1,78 @@
+package retry
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/turgaysozen/algotrading/config"
+	"github.com/turgaysozen/algotrading/monitoring/metrics"
+)
+
+// Policy is the reconnect policy shared by the WebSocket, Redis and Postgres clients:
+// exponential backoff from Initial up to Max, randomised by Jitter so restarted
+// instances don't reconnect in lockstep. MaxAttempts 0 retries forever.
+type Policy struct {
+	Initial     time.Duration
+	Max         time.Duration
+	Multiplier  float64
+	Jitter      float64 // fraction of the delay added or removed at random
+	MaxAttempts int
+}
+
+// LoadPolicy reads <prefix>_RETRY_* settings, falling back to the shared RETRY_* settings.
+func LoadPolicy(prefix string) Policy {
+	return Policy{
+		Initial:     config.GetEnvDuration(prefix+"_RETRY_INITIAL", config.GetEnvDuration("RETRY_INITIAL", time.Second)),
+		Max:         config.GetEnvDuration(prefix+"_RETRY_MAX", config.GetEnvDuration("RETRY_MAX", time.Minute)),
+		Multiplier:  config.GetEnvFloat(prefix+"_RETRY_MULTIPLIER", config.GetEnvFloat("RETRY_MULTIPLIER", 2)),
+		Jitter:      config.GetEnvFloat(prefix+"_RETRY_JITTER", config.GetEnvFloat("RETRY_JITTER", 0.2)),
+		MaxAttempts: config.GetEnvInt(prefix+"_RETRY_MAX_ATTEMPTS", config.GetEnvInt("RETRY_MAX_ATTEMPTS", 0)),
+	}
+}
+
+// maxBackoff caps the delay when Max is unset, before the growing exponent overflows a time.Duration.
+const maxBackoff = time.Hour
+
+// Backoff returns the delay after the given failed attempt, counting from 1. It never exceeds
+// Max (or maxBackoff) before jitter, Jitter is clamped to [0, 1] and the delay is never negative.
+func (p Policy) Backoff(attempt int) time.Duration {
+	limit := float64(maxBackoff)
+	if p.Max > 0 && p.Max < maxBackoff {
+		limit = float64(p.Max)
+	}
+
+	delay := float64(p.Initial) * math.Pow(math.Max(p.Multiplier, 1), float64(max(attempt-1, 0)))
+	delay = math.Max(math.Min(delay, limit), 0)
+	if jitter := math.Min(p.Jitter, 1); jitter > 0 {
+		delay *= 1 + jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(delay)
+}
+
+// Do calls connect until it succeeds or the policy gives up, keeping the
+// connection_up gauge for component current and counting every failed attempt.
+func Do(component string, p Policy, connect func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := connect()
+		if err == nil {
+			metrics.SetConnectionUp(component, true)
+			return nil
+		}
+
+		metrics.SetConnectionUp(component, false)
+		metrics.RecordReconnectAttempt(component)
+
+		if p.MaxAttempts > 0 && attempt >= p.MaxAttempts {
+			log.Printf("Error connecting to %s, giving up after %d attempts: %v", component, attempt, err)
+			metrics.RecordError(component + "_connection_max_retries")
+			return fmt.Errorf("%s: giving up after %d attempts: %w", component, attempt, err)
+		}
+
+		delay := p.Backoff(attempt)
+		log.Printf("Error connecting to %s (attempt %d), retrying in %s: %v", component, attempt, delay.Round(time.Millisecond), err)
+		time.Sleep(delay)
+	}
+}
@@ -0,0 +1,31 @@
+package retry
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffBounds(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   Policy
+		attempt  int
+		min, max time.Duration
+	}{
+		{"first attempt", Policy{Initial: time.Second, Max: time.Minute, Multiplier: 2}, 1, time.Second, time.Second},
+		{"capped at Max", Policy{Initial: time.Second, Max: time.Minute, Multiplier: 2}, 10, time.Minute, time.Minute},
+		{"huge exponent without Max", Policy{Initial: time.Second, Multiplier: 2}, 5000, maxBackoff, maxBackoff},
+		{"jitter above 1", Policy{Initial: time.Second, Max: time.Second, Multiplier: 2, Jitter: 5}, 1, 0, 2 * time.Second},
+		{"negative initial", Policy{Initial: -time.Second, Max: time.Minute, Multiplier: 2, Jitter: 0.5}, 3, 0, 0},
+		{"attempt 0", Policy{Initial: time.Second, Max: time.Minute, Multiplier: 2}, 0, time.Second, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				if got := tt.policy.Backoff(tt.attempt); got < tt.min || got > tt.max {
+					t.Fatalf("Backoff(%d) = %s, want within [%s, %s]", tt.attempt, got, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/turgaysozen/algotrading/config"
+	"github.com/turgaysozen/algotrading/costs"
+	"github.com/turgaysozen/algotrading/db"
+	"github.com/turgaysozen/algotrading/models"
+	"github.com/turgaysozen/algotrading/monitoring/metrics"
+)
+
+// Every validated book from every exchange updates a consolidated best bid/offer per
+// symbol. For each ordered pair of exchanges the edge of buying on one's ask and selling
+// on the other's bid is computed net of both taker fees; an edge at or above MinEdgeBps
+// that lasts MinDuration of event time becomes an opportunity, stored when it opens and
+// updated with its peak and end when it closes.
+
+type ArbitrageConfig struct {
+	MinEdgeBps  float64
+	MinDuration time.Duration
+	// MaxQuoteAge ignores quotes this much older than the newest one, so a silent exchange can't fake an edge.
+	MaxQuoteAge time.Duration
+	// TakerFeeBps overrides the fee schedule's taker rate by exchange
+	TakerFeeBps map[string]float64
+	// SymbolAliases merges quotes for equivalent symbols, e.g. BTCUSD into BTCUSDT.
+	SymbolAliases map[string]string
+}
+
+func LoadArbitrageConfig() ArbitrageConfig {
+	return ArbitrageConfig{
+		MinEdgeBps:    config.GetEnvFloat("ARB_MIN_EDGE_BPS", 5),
+		MinDuration:   config.GetEnvDuration("ARB_MIN_DURATION", 500*time.Millisecond),
+		MaxQuoteAge:   config.GetEnvDuration("ARB_MAX_QUOTE_AGE", 2*time.Second),
+		TakerFeeBps:   config.GetEnvFloatMap("ARB_TAKER_FEE_BPS"),
+		SymbolAliases: config.GetEnvMap("ARB_SYMBOL_ALIASES"),
+	}
+}
+
+func (c ArbitrageConfig) feeBps(exchange, symbol string) float64 {
+	if fee, ok := c.TakerFeeBps[exchange]; ok {
+		return fee
+	}
+	return costs.For(exchange, symbol).TakerBps
+}
+
+var (
+	arbitrageMu         sync.Mutex
+	arbitrageConfig     ArbitrageConfig
+	arbitrageConfigOnce sync.Once
+	quotes              = make(map[string]map[string]models.Quote)      // symbol, exchange
+	opportunities       = make(map[string]*models.ArbitrageOpportunity) // symbol|buy|sell
+	arbitrageHandlers   []func(models.ArbitrageOpportunity)
+)
+
+// OnArbitrage registers handler to be called when an opportunity opens and again when it closes.
+func OnArbitrage(handler func(models.ArbitrageOpportunity)) {
+	hooksMu.Lock()
+	arbitrageHandlers = append(arbitrageHandlers, handler)
+	hooksMu.Unlock()
+}
+
+func notifyArbitrage(o models.ArbitrageOpportunity) {
+	hooksMu.RLock()
+	defer hooksMu.RUnlock()
+	for _, handler := range arbitrageHandlers {
+		handler(o)
+	}
+}
+
+// getArbitrageConfig loads the configuration on first use.
+func getArbitrageConfig() ArbitrageConfig {
+	arbitrageConfigOnce.Do(func() {
+		arbitrageConfig = LoadArbitrageConfig()
+	})
+	return arbitrageConfig
+}
+
+// ConsolidatedBBO returns the best bid and best ask across exchanges with a fresh quote for symbol.
+func ConsolidatedBBO(symbol string) (models.BBO, bool) {
+	cfg := getArbitrageConfig()
+
+	arbitrageMu.Lock()
+	defer arbitrageMu.Unlock()
+
+	fresh := freshQuotes(quotes[symbol], cfg.MaxQuoteAge)
+	if len(fresh) == 0 {
+		return models.BBO{}, false
+	}
+
+	bbo := models.BBO{Symbol: symbol, Bid: fresh[0], Ask: fresh[0]}
+	for _, q := range fresh[1:] {
+		if q.Bid > bbo.Bid.Bid {
+			bbo.Bid = q
+		}
+		if q.Ask < bbo.Ask.Ask {
+			bbo.Ask = q
+		}
+	}
+	return bbo, true
+}
+
+// freshQuotes returns the quotes no older than maxAge before the newest, sorted by exchange.
+func freshQuotes(byExchange map[string]models.Quote, maxAge time.Duration) []models.Quote {
+	var newest int64
+	for _, q := range byExchange {
+		if q.EventTime > newest {
+			newest = q.EventTime
+		}
+	}
+
+	var fresh []models.Quote
+	for _, q := range byExchange {
+		if newest-q.EventTime <= maxAge.Milliseconds() {
+			fresh = append(fresh, q)
+		}
+	}
+	sort.Slice(fresh, func(i, j int) bool { return fresh[i].Exchange < fresh[j].Exchange })
+	return fresh
+}
+
+// updateCrossExchange records a validated tick and opens or closes the opportunities it affects.
+func updateCrossExchange(orderBook models.OrderBook) {
+	cfg := getArbitrageConfig()
+	// the quantities must be those of the levels quoted, not of whichever level the feed sent first
+	bid, _ := models.BestBid(orderBook.Bids)
+	ask, _ := models.BestAsk(orderBook.Asks)
+
+	symbol := orderBook.Symbol
+	if alias, ok := cfg.SymbolAliases[symbol]; ok {
+		symbol = alias
+	}
+
+	var opened, closed []models.ArbitrageOpportunity
+
+	arbitrageMu.Lock()
+	byExchange, ok := quotes[symbol]
+	if !ok {
+		byExchange = make(map[string]models.Quote)
+		quotes[symbol] = byExchange
+	}
+	byExchange[orderBook.Exchange] = models.Quote{
+		Exchange:  orderBook.Exchange,
+		Bid:       bid.Price,
+		BidQty:    bid.Qty,
+		Ask:       ask.Price,
+		AskQty:    ask.Qty,
+		EventTime: orderBook.EventTime,
+	}
+
+	evaluated := make(map[string]bool)
+	fresh := freshQuotes(byExchange, cfg.MaxQuoteAge)
+	for _, buy := range fresh {
+		for _, sell := range fresh {
+			if buy.Exchange == sell.Exchange {
+				continue
+			}
+
+			evaluated[symbol+"|"+buy.Exchange+"|"+sell.Exchange] = true
+			gross := (sell.Bid - buy.Ask) / buy.Ask * 10_000
+			net := gross - cfg.feeBps(buy.Exchange, symbol) - cfg.feeBps(sell.Exchange, symbol)
+			metrics.SetCrossExchangeSpread(symbol, buy.Exchange, sell.Exchange, net)
+
+			if o := trackOpportunity(symbol, buy, sell, gross, net, orderBook.EventTime, cfg); o != nil {
+				if o.ClosedAt == 0 {
+					opened = append(opened, *o)
+				} else {
+					closed = append(closed, *o)
+				}
+			}
+		}
+	}
+
+	// pairs with a leg that went stale can no longer be traded
+	for key, o := range opportunities {
+		if o.Symbol != symbol || evaluated[key] {
+			continue
+		}
+		delete(opportunities, key)
+		if o.OpenedAt != 0 {
+			o.ClosedAt = orderBook.EventTime
+			closed = append(closed, *o)
+		}
+	}
+	arbitrageMu.Unlock()
+
+	for _, o := range opened {
+		id, err := db.SaveArbitrageOpportunity(o)
+		if err == nil {
+			setOpportunityID(o, id)
+			o.ID = id
+		}
+		metrics.RecordArbitrageOpportunity(o.Symbol)
+		log.Printf("Arbitrage opportunity %s: buy %s @ %.8f, sell %s @ %.8f, net %.2f bps",
+			o.Symbol, o.BuyExchange, o.BuyPrice, o.SellExchange, o.SellPrice, o.NetBps)
+		notifyArbitrage(o)
+	}
+	for _, o := range closed {
+		if o.ID != 0 {
+			db.CloseArbitrageOpportunity(o)
+		}
+		log.Printf("Arbitrage opportunity %s %s->%s closed after %dms, peak net %.2f bps",
+			o.Symbol, o.BuyExchange, o.SellExchange, o.ClosedAt-o.OpenedAt, o.MaxNetBps)
+		notifyArbitrage(o)
+	}
+}
+
+// trackOpportunity advances the state of one buy/sell pair and returns the opportunity
+// when it opens or closes. Callers hold arbitrageMu.
+func trackOpportunity(symbol string, buy, sell models.Quote, gross, net float64, now int64, cfg ArbitrageConfig) *models.ArbitrageOpportunity {
+	key := symbol + "|" + buy.Exchange + "|" + sell.Exchange
+	o, tracking := opportunities[key]
+
+	if net < cfg.MinEdgeBps {
+		if !tracking {
+			return nil
+		}
+		delete(opportunities, key)
+		if o.OpenedAt == 0 {
+			// never lasted long enough to count
+			return nil
+		}
+		o.ClosedAt = now
+		return o
+	}
+
+	if !tracking {
+		opportunities[key] = &models.ArbitrageOpportunity{
+			Symbol:       symbol,
+			BuyExchange:  buy.Exchange,
+			SellExchange: sell.Exchange,
+			MaxNetBps:    net,
+			DetectedAt:   now,
+		}
+		return nil
+	}
+
+	if net > o.MaxNetBps {
+		o.MaxNetBps = net
+	}
+	if o.OpenedAt != 0 || time.Duration(now-o.DetectedAt)*time.Millisecond < cfg.MinDuration {
+		return nil
+	}
+
+	o.BuyPrice, o.BuyTime = buy.Ask, buy.EventTime
+	o.SellPrice, o.SellTime = sell.Bid, sell.EventTime
+	o.GrossBps, o.NetBps = gross, net
+	o.OpenedAt = now
+	copied := *o
+	return &copied
+}
+
+// setOpportunityID stores the database ID on the tracked opportunity so closing it can update the row.
+func setOpportunityID(o models.ArbitrageOpportunity, id int64) {
+	arbitrageMu.Lock()
+	defer arbitrageMu.Unlock()
+
+	tracked, ok := opportunities[o.Symbol+"|"+o.BuyExchange+"|"+o.SellExchange]
+	if ok && tracked.OpenedAt == o.OpenedAt {
+		tracked.ID = id
+	}
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"errors"
+	"log"
+
+	"github.com/turgaysozen/algotrading/account"
+	"github.com/turgaysozen/algotrading/db"
+	"github.com/turgaysozen/algotrading/decimal"
+	"github.com/turgaysozen/algotrading/models"
+	"github.com/turgaysozen/algotrading/monitoring/metrics"
+)
+
+// A basket is a set of orders that only makes sense as a whole, such as the two legs of
+// a pairs trade: the legs pass risk checks together and are stored in one transaction
+// with the signals that produced them. Legs always fill in full at the touch: working them
+// with EXECUTION_ALGO or ORDER_TYPE=limit could leave one leg filled and the other not, so
+// pairs trading is turned off when either is set (see initPairs).
+
+// executeBasket risk checks and saves orders with their signals, returning the orders with
+// their IDs. Nothing is saved if any leg fails.
+func executeBasket(signals []models.Signal, orders []models.Order) ([]models.Order, bool) {
+	if err := checkRisk(orders); err != nil {
+		rejectOrders(err)
+		return nil, false
+	}
+
+	ids, err := db.SaveBasket(signals, orders)
+	if errors.Is(err, db.ErrDuplicateOrder) {
+		log.Printf("Basket %s is already stored, skipping the duplicate", orders[0].BasketID)
+		metrics.RecordDuplicateOrder("orders")
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("Error saving basket: %v", err)
+		metrics.RecordError("basket_save_error")
+		metrics.RecordDataLoss("basket_save_data_loss")
+		return nil, false
+	}
+
+	for _, signal := range signals {
+		notifySignal(signal)
+	}
+	saved := make([]models.Order, len(orders))
+	for i, order := range orders {
+		order.ID = ids[i]
+		saved[i] = order
+		recordOpened(order)
+	}
+	return saved, true
+}
+
+// closeBasket closes every leg with a market order against its mid in closePrices, with the
+// exit signals, in one transaction.
+func closeBasket(signals []models.Signal, orders []models.Order, closePrices map[string]float64, closeTime int64) bool {
+	closed := make([]models.Order, len(orders))
+	total := decimal.Zero
+	for i, order := range orders {
+		closed[i] = closeAt(order, closePrices[order.Symbol])
+		total = total.Add(closed[i].PnL)
+	}
+
+	err := db.CloseBasket(signals, closed, closeTime)
+	if errors.Is(err, db.ErrOrderNotOpen) {
+		log.Printf("Basket %s was already closed", orders[0].BasketID)
+		return true
+	}
+	if err != nil {
+		log.Printf("Error closing basket: %v", err)
+		metrics.RecordError("basket_close_error")
+		return false
+	}
+	for _, order := range closed {
+		account.RecordFill(closingFill(order, closeTime))
+		recordClosed(order)
+	}
+
+	for _, signal := range signals {
+		notifySignal(signal)
+	}
+	log.Printf("Closed basket %s, PnL: %s", orders[0].BasketID, total)
+	return true
+}
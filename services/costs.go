@@ -0,0 +1,75 @@
+package services
+
+import (
+	"github.com/turgaysozen/algotrading/config"
+	"github.com/turgaysozen/algotrading/costs"
+	"github.com/turgaysozen/algotrading/decimal"
+	"github.com/turgaysozen/algotrading/execution"
+	"github.com/turgaysozen/algotrading/models"
+	"github.com/turgaysozen/algotrading/monitoring/metrics"
+)
+
+// Orders executed directly are market orders: they fill at the far touch of the strategy
+// exchange's latest book and pay its taker fee, in live, paper and replay alike. The signal
+// keeps the mid it saw, so the difference is recorded as the order's slippage.
+
+// takerPrice is what a market order on side fills at: the best ask for a buy and the best
+// bid for a sell, or mid before symbol's first book.
+func takerPrice(symbol, side string, mid float64) decimal.Decimal {
+	price := mid
+	if bid, ask, ok := execution.Touch(symbol); ok {
+		price = bid
+		if side == "buy" {
+			price = ask
+		}
+	}
+	return decimal.FromFloat(price).Round(config.PrecisionFor(symbol).Price)
+}
+
+func takerFee(symbol string, price, quantity decimal.Decimal) decimal.Decimal {
+	return costs.Fee(strategyExchange, symbol, costs.Taker, price.Mul(quantity))
+}
+
+// withCosts fills order at price with fee, recording its slippage against the signal's mid.
+func withCosts(order models.Order, price, fee decimal.Decimal) models.Order {
+	order.Price = price
+	order.Fee = fee
+	order.Slippage = costs.Slippage(order.OrderType, order.SignalPrice, price, order.Quantity)
+	return order
+}
+
+func closingSide(order models.Order) string {
+	if order.OrderType == "sell" {
+		return "buy"
+	}
+	return "sell"
+}
+
+// closeAt closes order with a market order against the book mid came from, setting the close
+// price, the taker fee on it, its slippage against mid and the PnL net of both sides' fees.
+func closeAt(order models.Order, mid float64) models.Order {
+	side := closingSide(order)
+	order.ClosePrice = takerPrice(order.Symbol, side, mid)
+	order.CloseFee = takerFee(order.Symbol, order.ClosePrice, order.Quantity)
+	closeMid := decimal.FromFloat(mid).Round(config.PrecisionFor(order.Symbol).Price)
+	order.CloseSlippage = costs.Slippage(side, closeMid, order.ClosePrice, order.Quantity)
+	order.PnL = calculatePnL(order, order.ClosePrice, order.CloseFee)
+	order.Status = "closed"
+	return order
+}
+
+// closingFill is the fill that closed order: the opposite side for its quantity.
+func closingFill(order models.Order, closeTime int64) models.Fill {
+	return models.Fill{Symbol: order.Symbol, Side: closingSide(order), Quantity: order.Quantity,
+		Price: order.ClosePrice, Fee: order.CloseFee, EventTime: closeTime}
+}
+
+// recordClosed exports a closed order's fees, slippage on both sides and net PnL under its strategy.
+func recordClosed(order models.Order) {
+	strategy := order.Strategy
+	if strategy == "" {
+		strategy = "unknown"
+	}
+	metrics.RecordStrategyClose(strategy, order.Fee.Add(order.CloseFee).Float64(), order.Slippage.Add(order.CloseSlippage).Float64(),
+		order.PnL.Float64())
+}
@@ -0,0 +1,25 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/turgaysozen/algotrading/decimal"
+	"github.com/turgaysozen/algotrading/execution"
+	"github.com/turgaysozen/algotrading/models"
+)
+
+// Closing a long sells at the best bid, so selling below the mid that closed it is close slippage.
+func TestCloseAtRecordsCloseSlippage(t *testing.T) {
+	execution.OnBook("SLIPUSDT", []models.PriceLevel{{Price: 100, Qty: 5}}, []models.PriceLevel{{Price: 101, Qty: 5}}, 1704067200000)
+
+	order := models.Order{Symbol: "SLIPUSDT", OrderType: "buy", Status: "open", Price: decimal.FromInt(99),
+		Quantity: decimal.FromInt(2)}
+	closed := closeAt(order, 100.5)
+
+	if closed.ClosePrice != decimal.FromInt(100) {
+		t.Errorf("close price = %s, want the 100 bid", closed.ClosePrice)
+	}
+	if closed.CloseSlippage != decimal.FromInt(1) {
+		t.Errorf("close slippage = %s, want 1 for selling 2 at 0.5 below mid", closed.CloseSlippage)
+	}
+}
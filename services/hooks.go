@@ -0,0 +1,45 @@
+package services
+
+import (
+	"sync"
+
+	"github.com/turgaysozen/algotrading/models"
+)
+
+// Hooks let replays and test harnesses observe exactly which signals and orders the strategy produced.
+
+var (
+	hooksMu        sync.RWMutex
+	signalHandlers []func(models.Signal)
+	orderHandlers  []func(models.Order)
+)
+
+// OnSignal registers handler to be called with every saved signal.
+func OnSignal(handler func(models.Signal)) {
+	hooksMu.Lock()
+	signalHandlers = append(signalHandlers, handler)
+	hooksMu.Unlock()
+}
+
+// OnOrder registers handler to be called with every saved order.
+func OnOrder(handler func(models.Order)) {
+	hooksMu.Lock()
+	orderHandlers = append(orderHandlers, handler)
+	hooksMu.Unlock()
+}
+
+func notifySignal(signal models.Signal) {
+	hooksMu.RLock()
+	defer hooksMu.RUnlock()
+	for _, handler := range signalHandlers {
+		handler(signal)
+	}
+}
+
+func notifyOrder(order models.Order) {
+	hooksMu.RLock()
+	defer hooksMu.RUnlock()
+	for _, handler := range orderHandlers {
+		handler(order)
+	}
+}
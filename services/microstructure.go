@@ -0,0 +1,150 @@
+package services
+
+import (
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/turgaysozen/algotrading/config"
+	"github.com/turgaysozen/algotrading/models"
+	"github.com/turgaysozen/algotrading/monitoring/metrics"
+)
+
+type MicrostructureConfig struct {
+	Levels         int
+	DepthBps       float64
+	PressureWindow int
+}
+
+func LoadMicrostructureConfig() MicrostructureConfig {
+	cfg := MicrostructureConfig{
+		Levels:         config.GetEnvInt("MICROSTRUCTURE_LEVELS", 10),
+		DepthBps:       config.GetEnvFloat("MICROSTRUCTURE_DEPTH_BPS", 10),
+		PressureWindow: config.GetEnvInt("MICROSTRUCTURE_PRESSURE_WINDOW", 100),
+	}
+	if cfg.PressureWindow < 1 {
+		log.Printf("Invalid MICROSTRUCTURE_PRESSURE_WINDOW %d, using 100", cfg.PressureWindow)
+		metrics.RecordError("microstructure_config_invalid")
+		cfg.PressureWindow = 100
+	}
+	return cfg
+}
+
+var microstructureConfig MicrostructureConfig
+var microstructureConfigOnce sync.Once
+var microstructureMap sync.Map
+var pressureMap sync.Map
+
+// rollingMean keeps an O(1) mean over the last size values.
+type rollingMean struct {
+	mu     sync.Mutex
+	values []float64
+	size   int
+	next   int
+	sum    float64
+}
+
+func (r *rollingMean) add(v float64) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.values) < r.size {
+		r.values = append(r.values, v)
+	} else {
+		r.sum -= r.values[r.next]
+		r.values[r.next] = v
+		r.next = (r.next + 1) % r.size
+	}
+	r.sum += v
+	return r.sum / float64(len(r.values))
+}
+
+// LatestMicrostructure returns the most recent book analytics for symbol, for use as strategy inputs.
+func LatestMicrostructure(symbol string) (models.Microstructure, bool) {
+	value, ok := microstructureMap.Load(symbol)
+	if !ok {
+		return models.Microstructure{}, false
+	}
+	return value.(models.Microstructure), true
+}
+
+// updateMicrostructure computes the analytics for a validated tick, stores them and exports the gauges.
+func updateMicrostructure(orderBook models.OrderBook, bid, ask float64) models.Microstructure {
+	m := computeMicrostructure(orderBook, bid, ask)
+
+	value, _ := pressureMap.LoadOrStore(orderBook.Symbol, &rollingMean{size: microstructureConfig.PressureWindow})
+	m.Pressure = value.(*rollingMean).add(m.Imbalance)
+
+	microstructureMap.Store(orderBook.Symbol, m)
+	metrics.SetMicrostructure(orderBook.Symbol, m.Imbalance, m.Microprice, m.SpreadBps, m.BidDepth, m.AskDepth, m.Pressure)
+	return m
+}
+
+// computeMicrostructure computes the analytics for a validated tick without the rolling pressure.
+func computeMicrostructure(orderBook models.OrderBook, bid, ask float64) models.Microstructure {
+	microstructureConfigOnce.Do(func() {
+		microstructureConfig = LoadMicrostructureConfig()
+	})
+	cfg := microstructureConfig
+
+	return ComputeMicrostructure(orderBook.Bids, orderBook.Asks, bid, ask, cfg.Levels, cfg.DepthBps)
+}
+
+// ComputeMicrostructure derives imbalance over the top levels, microprice, spread and depth within depthBps of mid.
+// Levels with zero quantity are removals and are ignored.
+func ComputeMicrostructure(bids, asks []models.PriceLevel, bid, ask float64, levels int, depthBps float64) models.Microstructure {
+	mid := (bid + ask) / 2
+	m := models.Microstructure{
+		Microprice: mid,
+		SpreadBps:  (ask - bid) / mid * 10000,
+	}
+
+	bidLevels := liveLevels(bids, func(a, b float64) bool { return a > b })
+	askLevels := liveLevels(asks, func(a, b float64) bool { return a < b })
+
+	bidVolume := topVolume(bidLevels, levels)
+	askVolume := topVolume(askLevels, levels)
+	if total := bidVolume + askVolume; total > 0 {
+		m.Imbalance = (bidVolume - askVolume) / total
+	}
+
+	if len(bidLevels) > 0 && len(askLevels) > 0 {
+		bidQty, askQty := bidLevels[0].Qty, askLevels[0].Qty
+		m.Microprice = (bidLevels[0].Price*askQty + askLevels[0].Price*bidQty) / (bidQty + askQty)
+	}
+
+	band := mid * depthBps / 10000
+	for _, l := range bidLevels {
+		if l.Price < mid-band {
+			break
+		}
+		m.BidDepth += l.Qty
+	}
+	for _, l := range askLevels {
+		if l.Price > mid+band {
+			break
+		}
+		m.AskDepth += l.Qty
+	}
+
+	return m
+}
+
+func liveLevels(levels []models.PriceLevel, better func(a, b float64) bool) []models.PriceLevel {
+	live := make([]models.PriceLevel, 0, len(levels))
+	for _, l := range levels {
+		if l.Qty > 0 {
+			live = append(live, l)
+		}
+	}
+	sort.Slice(live, func(i, j int) bool { return better(live[i].Price, live[j].Price) })
+	return live
+}
+
+func topVolume(levels []models.PriceLevel, n int) float64 {
+	var volume float64
+	for i := 0; i < len(levels) && i < n; i++ {
+		volume += levels[i].Qty
+	}
+	return volume
+}
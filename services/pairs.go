@@ -0,0 +1,352 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/turgaysozen/algotrading/config"
+	"github.com/turgaysozen/algotrading/db"
+	"github.com/turgaysozen/algotrading/decimal"
+	"github.com/turgaysozen/algotrading/execution"
+	"github.com/turgaysozen/algotrading/models"
+	"github.com/turgaysozen/algotrading/monitoring/metrics"
+)
+
+// The pairs strategy trades the spread between two symbols, e.g. BTCUSDT against ETHUSDT.
+// A rolling regression of the first leg's log price on the hedge leg's gives the hedge
+// ratio, and the z-score of the latest residual against the window drives the position:
+// at PAIRS_ENTRY_Z the first leg is sold and the hedge bought (at -PAIRS_ENTRY_Z the
+// reverse), and once the z-score is back within PAIRS_EXIT_Z both legs are closed.
+// Entries and exits are baskets, so the legs are risk checked and stored together.
+
+type PairsConfig struct {
+	Pairs  [][2]string // first leg, hedge leg
+	Window int
+	EntryZ float64
+	ExitZ  float64
+	// Quantity is the first leg's size; the hedge leg is sized to the hedge ratio times its notional.
+	Quantity float64
+}
+
+// LoadPairsConfig reads PAIRS as comma separated leg:hedge symbols, e.g. BTCUSDT:ETHUSDT.
+func LoadPairsConfig() PairsConfig {
+	cfg := PairsConfig{
+		Window:   config.GetEnvInt("PAIRS_WINDOW", config.MaxPriceCount),
+		EntryZ:   config.GetEnvFloat("PAIRS_ENTRY_Z", 2),
+		ExitZ:    config.GetEnvFloat("PAIRS_EXIT_Z", 0.5),
+		Quantity: config.GetEnvFloat("PAIRS_QUANTITY", 1),
+	}
+	for leg, hedge := range config.GetEnvMap("PAIRS") {
+		cfg.Pairs = append(cfg.Pairs, [2]string{leg, hedge})
+	}
+	sort.Slice(cfg.Pairs, func(i, j int) bool { return cfg.Pairs[i][0] < cfg.Pairs[j][0] })
+	return cfg
+}
+
+type pairState struct {
+	mu         sync.Mutex
+	name       string
+	leg, hedge string
+	prices     map[string]float64
+	regression *rollingRegression
+	// position is 1 when long the spread (long the first leg), -1 when short and 0 when flat
+	position int
+	open     []models.Order
+}
+
+var (
+	pairsConfig   PairsConfig
+	pairsBySymbol = make(map[string][]*pairState)
+)
+
+// initPairs sets up the configured pairs; called from InitStrategy.
+func initPairs() {
+	pairsConfig = LoadPairsConfig()
+	pairsBySymbol = make(map[string][]*pairState)
+
+	if len(pairsConfig.Pairs) > 0 && (execution.Enabled() || execution.LimitOrdersEnabled()) {
+		log.Println("PAIRS can't run with EXECUTION_ALGO or ORDER_TYPE=limit, baskets only fill in full at the touch; pairs trading disabled")
+		metrics.RecordError("pairs_config_invalid")
+		pairsConfig.Pairs = nil
+	}
+
+	for _, legs := range pairsConfig.Pairs {
+		p := &pairState{
+			name:       legs[0] + "/" + legs[1],
+			leg:        legs[0],
+			hedge:      legs[1],
+			prices:     make(map[string]float64),
+			regression: newRollingRegression(pairsConfig.Window),
+		}
+		pairsBySymbol[p.leg] = append(pairsBySymbol[p.leg], p)
+		pairsBySymbol[p.hedge] = append(pairsBySymbol[p.hedge], p)
+		log.Println("Pairs strategy trading", p.name)
+	}
+	restorePairs()
+}
+
+// restorePairs picks the open baskets of the configured pairs back up from the store, so a
+// restart exits the position it was holding instead of entering another one beside it.
+func restorePairs() {
+	if len(pairsConfig.Pairs) == 0 {
+		return
+	}
+	orders, err := db.GetOpenBasketOrders()
+	if err != nil {
+		log.Printf("Error restoring pairs positions: %v", err)
+		metrics.RecordError("pairs_restore_error")
+		return
+	}
+
+	baskets := make(map[string][]models.Order)
+	var ids []string
+	for _, order := range orders {
+		if _, ok := baskets[order.BasketID]; !ok {
+			ids = append(ids, order.BasketID)
+		}
+		baskets[order.BasketID] = append(baskets[order.BasketID], order)
+	}
+
+	for _, id := range ids {
+		for _, p := range pairsBySymbol[baskets[id][0].Symbol] {
+			if !strings.HasPrefix(id, "pairs-"+p.leg+"-"+p.hedge+"-") {
+				continue
+			}
+			if p.open != nil {
+				log.Printf("Pairs %s has more than one open basket, exiting %s first", p.name, p.open[0].BasketID)
+				metrics.RecordError("pairs_restore_duplicate")
+				continue
+			}
+			for _, order := range baskets[id] {
+				if order.Symbol != p.leg {
+					continue
+				}
+				p.position = -1
+				if order.OrderType == "buy" {
+					p.position = 1
+				}
+			}
+			p.open = baskets[id]
+			log.Printf("Restored pairs %s position %d from basket %s", p.name, p.position, id)
+		}
+	}
+}
+
+// runPairs feeds one price into every pair symbol is a leg of; eventTime stamps the resulting signals and orders.
+func runPairs(symbol string, price float64, eventTime int64) {
+	for _, p := range pairsBySymbol[symbol] {
+		p.update(symbol, price, eventTime)
+	}
+}
+
+func (p *pairState) update(symbol string, price float64, eventTime int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.prices[symbol] = price
+	legPrice, hedgePrice := p.prices[p.leg], p.prices[p.hedge]
+	if legPrice <= 0 || hedgePrice <= 0 {
+		return
+	}
+
+	p.regression.Add(math.Log(hedgePrice), math.Log(legPrice))
+	hedgeRatio, zScore, ok := p.regression.Fit()
+	if !ok {
+		return
+	}
+	metrics.SetPairsSpread(p.name, zScore, hedgeRatio)
+
+	switch {
+	case p.position == 0 && zScore >= pairsConfig.EntryZ:
+		p.enter(-1, hedgeRatio, zScore, eventTime)
+	case p.position == 0 && zScore <= -pairsConfig.EntryZ:
+		p.enter(1, hedgeRatio, zScore, eventTime)
+	case p.position != 0 && float64(p.position)*zScore >= -pairsConfig.ExitZ:
+		p.exit(zScore, eventTime)
+	}
+}
+
+// enter opens a basket long (direction 1) or short (-1) the spread. Callers hold p.mu.
+func (p *pairState) enter(direction int, hedgeRatio, zScore float64, eventTime int64) {
+	basketID := fmt.Sprintf("pairs-%s-%s-%d", p.leg, p.hedge, eventTime)
+	reason := fmt.Sprintf("Pairs entry: %s spread z-score %.2f, hedge ratio %.4f", p.name, zScore, hedgeRatio)
+
+	legPrice, hedgePrice := p.prices[p.leg], p.prices[p.hedge]
+	hedgeQuantity := math.Abs(hedgeRatio) * pairsConfig.Quantity * legPrice / hedgePrice
+	hedgeDirection := -direction
+	if hedgeRatio < 0 {
+		hedgeDirection = direction
+	}
+
+	var signals []models.Signal
+	var orders []models.Order
+	for _, leg := range []struct {
+		symbol    string
+		direction int
+		quantity  float64
+	}{
+		{p.leg, direction, pairsConfig.Quantity},
+		{p.hedge, hedgeDirection, hedgeQuantity},
+	} {
+		signalType, orderType := "SELL Signal!", "sell"
+		if leg.direction > 0 {
+			signalType, orderType = "BUY Signal!", "buy"
+		}
+		precision := config.PrecisionFor(leg.symbol)
+		quantity := decimal.FromFloat(leg.quantity).Round(precision.Quantity)
+		price := takerPrice(leg.symbol, orderType, p.prices[leg.symbol])
+
+		signals = append(signals, models.Signal{
+			Symbol:    leg.symbol,
+			Type:      signalType,
+			Price:     p.prices[leg.symbol],
+			Reason:    reason,
+			EventTime: eventTime,
+			BasketID:  basketID,
+		})
+		orders = append(orders, withCosts(models.Order{
+			Symbol:        leg.symbol,
+			Quantity:      quantity,
+			Status:        "open",
+			OrderType:     orderType,
+			EventTime:     eventTime,
+			BasketID:      basketID,
+			ClientOrderID: execution.ClientOrderID("pairs-"+p.leg+"-"+p.hedge, leg.symbol, eventTime),
+			Strategy:      "pairs",
+			SignalPrice:   decimal.FromFloat(p.prices[leg.symbol]).Round(precision.Price),
+		}, price, takerFee(leg.symbol, price, quantity)))
+	}
+
+	saved, ok := executeBasket(signals, orders)
+	if !ok {
+		return
+	}
+	p.position = direction
+	p.open = saved
+	log.Println(reason)
+}
+
+// exit closes both legs of the open basket. Callers hold p.mu.
+func (p *pairState) exit(zScore float64, eventTime int64) {
+	reason := fmt.Sprintf("Pairs exit: %s spread z-score %.2f", p.name, zScore)
+
+	var signals []models.Signal
+	for _, order := range p.open {
+		signalType := "SELL Signal!"
+		if order.OrderType == "sell" {
+			signalType = "BUY Signal!"
+		}
+		signals = append(signals, models.Signal{
+			Symbol:    order.Symbol,
+			Type:      signalType,
+			Price:     p.prices[order.Symbol],
+			Reason:    reason,
+			EventTime: eventTime,
+			BasketID:  order.BasketID,
+		})
+	}
+
+	// on failure the basket stays open and the exit is retried on the next price
+	if !closeBasket(signals, p.open, p.prices, eventTime) {
+		return
+	}
+	p.position = 0
+	p.open = nil
+	log.Println(reason)
+}
+
+// rollingRegression fits y = alpha + beta*x over the last period samples with running sums,
+// so each sample costs O(1). Samples are taken relative to the first one to keep the sums
+// small, and like SMA the sums are recomputed from the window once per period.
+type rollingRegression struct {
+	xs, ys  []float64
+	period  int
+	x0, y0  float64
+	started bool
+
+	sx, sy, sxx, sxy, syy float64
+	sinceResum            int
+}
+
+func newRollingRegression(period int) *rollingRegression {
+	return &rollingRegression{
+		xs:     make([]float64, 0, period),
+		ys:     make([]float64, 0, period),
+		period: period,
+	}
+}
+
+func (r *rollingRegression) Add(x, y float64) {
+	if !r.started {
+		r.x0, r.y0, r.started = x, y, true
+	}
+	x, y = x-r.x0, y-r.y0
+
+	r.xs = append(r.xs, x)
+	r.ys = append(r.ys, y)
+	r.sx += x
+	r.sy += y
+	r.sxx += x * x
+	r.sxy += x * y
+	r.syy += y * y
+
+	if len(r.xs) > r.period {
+		ox, oy := r.xs[0], r.ys[0]
+		r.sx -= ox
+		r.sy -= oy
+		r.sxx -= ox * ox
+		r.sxy -= ox * oy
+		r.syy -= oy * oy
+		r.xs = r.xs[1:]
+		r.ys = r.ys[1:]
+	}
+
+	r.sinceResum++
+	if r.sinceResum >= r.period {
+		r.resum()
+	}
+}
+
+// Fit returns the slope and the z-score of the latest sample's residual; ok is false until
+// the window is full or while either series is flat.
+func (r *rollingRegression) Fit() (beta, zScore float64, ok bool) {
+	if len(r.xs) < r.period || r.period < 2 {
+		return 0, 0, false
+	}
+
+	n := float64(len(r.xs))
+	meanX, meanY := r.sx/n, r.sy/n
+	varX := r.sxx/n - meanX*meanX
+	varY := r.syy/n - meanY*meanY
+	cov := r.sxy/n - meanX*meanY
+	if varX <= 0 {
+		return 0, 0, false
+	}
+
+	beta = cov / varX
+	residualVar := varY - beta*cov
+	if residualVar <= 0 {
+		return beta, 0, false
+	}
+
+	alpha := meanY - beta*meanX
+	x, y := r.xs[len(r.xs)-1], r.ys[len(r.ys)-1]
+	return beta, (y - alpha - beta*x) / math.Sqrt(residualVar), true
+}
+
+func (r *rollingRegression) resum() {
+	r.sx, r.sy, r.sxx, r.sxy, r.syy = 0, 0, 0, 0, 0
+	for i, x := range r.xs {
+		y := r.ys[i]
+		r.sx += x
+		r.sy += y
+		r.sxx += x * x
+		r.sxy += x * y
+		r.syy += y * y
+	}
+	r.sinceResum = 0
+}
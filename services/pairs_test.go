@@ -0,0 +1,52 @@
+package services
+
+import (
+	"os"
+	"testing"
+
+	"github.com/turgaysozen/algotrading/db"
+	"github.com/turgaysozen/algotrading/execution"
+	"github.com/turgaysozen/algotrading/models"
+)
+
+func TestInitPairsRestoresOpenBasket(t *testing.T) {
+	t.Setenv("PAIRS", "BTCUSDT:ETHUSDT")
+	store := db.NewMemory()
+	db.Use(store)
+	t.Cleanup(func() { db.Use(db.NewMemory()) })
+
+	basketID := "pairs-BTCUSDT-ETHUSDT-1704067200000"
+	_, err := store.SaveBasket(nil, []models.Order{
+		{Symbol: "BTCUSDT", OrderType: "sell", Status: "open", BasketID: basketID, EventTime: 1704067200000},
+		{Symbol: "ETHUSDT", OrderType: "buy", Status: "open", BasketID: basketID, EventTime: 1704067200000},
+		{Symbol: "BTCUSDT", OrderType: "buy", Status: "open", EventTime: 1704067200000},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	initPairs()
+	p := pairsBySymbol["BTCUSDT"][0]
+	if p.position != -1 || len(p.open) != 2 || p.open[0].BasketID != basketID {
+		t.Errorf("restored position %d with %+v, want short the spread with both legs of %s", p.position, p.open, basketID)
+	}
+	if pairsBySymbol["ETHUSDT"][0] != p {
+		t.Error("hedge leg isn't routed to the same pair")
+	}
+}
+
+// Baskets can't be worked by an execution algorithm, so configuring one turns pairs off.
+func TestInitPairsDisabledWithExecutionAlgo(t *testing.T) {
+	t.Setenv("PAIRS", "BTCUSDT:ETHUSDT")
+	t.Setenv("EXECUTION_ALGO", "twap")
+	execution.Init()
+	t.Cleanup(func() {
+		os.Unsetenv("EXECUTION_ALGO")
+		execution.Init()
+	})
+
+	initPairs()
+	if len(pairsConfig.Pairs) != 0 || len(pairsBySymbol) != 0 {
+		t.Errorf("pairs %v running with EXECUTION_ALGO=twap, want none", pairsConfig.Pairs)
+	}
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"log"
+	"strings"
+
+	"github.com/turgaysozen/algotrading/decimal"
+	"github.com/turgaysozen/algotrading/execution"
+	"github.com/turgaysozen/algotrading/models"
+	"github.com/turgaysozen/algotrading/monitoring/metrics"
+)
+
+// RecoverOrders resolves the parent and limit orders a previous run left working and stores
+// what they filled as strategy orders under their client order IDs, so an order that was
+// already stored before the restart is skipped as a duplicate. Called once at start-up.
+func RecoverOrders() {
+	parents, limits, err := execution.Recover()
+	if err != nil {
+		log.Printf("Error recovering working orders: %v", err)
+		metrics.RecordError("order_recovery_error")
+	}
+
+	for _, p := range parents {
+		storeRecoveredOrder(models.Order{Symbol: p.Symbol, OrderType: p.Side, EventTime: p.StartTime,
+			ClientOrderID: p.ClientOrderID, SignalPrice: p.ArrivalPrice}, p.FilledQty, p.AvgPrice, p.Fee)
+	}
+	// a limit order doesn't keep the signal's mid, so its slippage is measured against its own price
+	for _, o := range limits {
+		storeRecoveredOrder(models.Order{Symbol: o.Symbol, OrderType: o.Side, EventTime: o.CreatedAt,
+			ClientOrderID: o.ClientOrderID, SignalPrice: o.Price}, o.FilledQty, o.AvgPrice, o.Fee)
+	}
+}
+
+// storeRecoveredOrder stores a strategy order's fills; orders placed by hand have no client
+// order ID and no strategy order to store. The strategy is the client order ID's prefix.
+func storeRecoveredOrder(order models.Order, filledQty, avgPrice, fee decimal.Decimal) {
+	if order.ClientOrderID == "" || filledQty.Sign() == 0 {
+		return
+	}
+	order.Strategy, _, _ = strings.Cut(order.ClientOrderID, "-")
+	order.Status = "open"
+	storeExecutedOrder(order, filledQty, avgPrice, fee)
+}
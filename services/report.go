@@ -0,0 +1,29 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/turgaysozen/algotrading/db"
+)
+
+// StrategyPnLHandler serves /reports/strategies: GET returns every strategy's closed orders
+// with their gross PnL, fees, slippage and net PnL.
+func StrategyPnLHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	report, err := db.GetStrategyPnL()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, `{"error": %q}`, err.Error())
+		return
+	}
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/turgaysozen/algotrading/config"
+	"github.com/turgaysozen/algotrading/decimal"
+	"github.com/turgaysozen/algotrading/models"
+	"github.com/turgaysozen/algotrading/monitoring/metrics"
+	"github.com/turgaysozen/algotrading/portfolio"
+)
+
+// Risk rejection reasons, used as the reason label of risk_rejections_total.
+const (
+	RiskInvalidOrder      = "invalid_order"
+	RiskMaxOrderNotional  = "max_order_notional"
+	RiskMaxBasketNotional = "max_basket_notional"
+	RiskVaRBudget         = "var_budget"
+	RiskUnmodelledAsset   = "unmodelled_asset"
+	RiskUnavailable       = "risk_unavailable"
+	RiskMaxAssetWeight    = "max_asset_weight"
+)
+
+// RiskError describes why a set of orders was rejected before execution.
+type RiskError struct {
+	Reason string
+	Detail string
+}
+
+func (e *RiskError) Error() string {
+	return fmt.Sprintf("rejected by risk checks: %s (%s)", e.Reason, e.Detail)
+}
+
+// RiskConfig holds the pre-trade limits; a zero limit is not enforced.
+type RiskConfig struct {
+	MaxOrderNotional  decimal.Decimal
+	MaxBasketNotional decimal.Decimal
+	// VaRBudget caps the portfolio's VaR in the reporting currency once the orders fill
+	VaRBudget float64
+	// MaxAssetWeight caps any asset's exposure as a share of equity once the orders fill
+	MaxAssetWeight float64
+}
+
+func LoadRiskConfig() RiskConfig {
+	return RiskConfig{
+		MaxOrderNotional:  decimal.FromFloat(config.GetEnvFloat("RISK_MAX_ORDER_NOTIONAL", 0)),
+		MaxBasketNotional: decimal.FromFloat(config.GetEnvFloat("RISK_MAX_BASKET_NOTIONAL", 0)),
+		VaRBudget:         config.GetEnvFloat("RISK_VAR_BUDGET", 0),
+		MaxAssetWeight:    config.GetEnvFloat("RISK_MAX_ASSET_WEIGHT", 0),
+	}
+}
+
+var (
+	riskConfig     RiskConfig
+	riskConfigOnce sync.Once
+)
+
+// checkRisk approves orders as a whole: a single failing leg rejects every order, so a
+// basket is never executed partially.
+func checkRisk(orders []models.Order) error {
+	riskConfigOnce.Do(func() {
+		riskConfig = LoadRiskConfig()
+	})
+
+	total := decimal.Zero
+	for _, order := range orders {
+		if order.Price.Sign() <= 0 || order.Quantity.Sign() <= 0 {
+			return &RiskError{Reason: RiskInvalidOrder,
+				Detail: fmt.Sprintf("%s %s price %s quantity %s", order.OrderType, order.Symbol, order.Price, order.Quantity)}
+		}
+
+		notional := order.Price.Mul(order.Quantity)
+		// Mul saturates, so a notional at Max overflowed
+		if notional == decimal.Max {
+			return &RiskError{Reason: RiskInvalidOrder,
+				Detail: fmt.Sprintf("%s %s notional out of range", order.OrderType, order.Symbol)}
+		}
+		if riskConfig.MaxOrderNotional.Sign() > 0 && notional.Cmp(riskConfig.MaxOrderNotional) > 0 {
+			return &RiskError{Reason: RiskMaxOrderNotional,
+				Detail: fmt.Sprintf("%s notional %s above %s", order.Symbol, notional, riskConfig.MaxOrderNotional)}
+		}
+		total = total.Add(notional)
+	}
+
+	if len(orders) > 1 && riskConfig.MaxBasketNotional.Sign() > 0 && total.Cmp(riskConfig.MaxBasketNotional) > 0 {
+		return &RiskError{Reason: RiskMaxBasketNotional,
+			Detail: fmt.Sprintf("basket notional %s above %s", total, riskConfig.MaxBasketNotional)}
+	}
+	return checkPortfolioRisk(orders)
+}
+
+// checkPortfolioRisk rejects orders that would leave the portfolio's VaR above its budget or an
+// asset above its share of equity. Orders that reduce the VaR or the asset's weight pass, so a
+// portfolio already over a limit can always trade back under it. The checks fail closed: until
+// there is a risk estimate and a valuation every order is rejected, and with a VaR budget so is
+// an order in an asset the estimate doesn't cover.
+func checkPortfolioRisk(orders []models.Order) error {
+	if riskConfig.VaRBudget <= 0 && riskConfig.MaxAssetWeight <= 0 {
+		return nil
+	}
+	projection, ok := portfolio.Project(orders)
+	if !ok {
+		return &RiskError{Reason: RiskUnavailable, Detail: "no portfolio risk estimate or valuation yet"}
+	}
+
+	if riskConfig.VaRBudget > 0 && len(projection.Unmodelled) > 0 {
+		return &RiskError{Reason: RiskUnmodelledAsset,
+			Detail: fmt.Sprintf("no VaR estimate for %s", strings.Join(projection.Unmodelled, ", "))}
+	}
+
+	if riskConfig.VaRBudget > 0 && projection.ProjectedVaR > riskConfig.VaRBudget && projection.ProjectedVaR > projection.VaR {
+		return &RiskError{Reason: RiskVaRBudget,
+			Detail: fmt.Sprintf("portfolio VaR %.2f above budget %.2f, from %.2f", projection.ProjectedVaR, riskConfig.VaRBudget, projection.VaR)}
+	}
+
+	if riskConfig.MaxAssetWeight > 0 {
+		assets := make([]string, 0, len(projection.ProjectedWeights))
+		for asset := range projection.ProjectedWeights {
+			assets = append(assets, asset)
+		}
+		sort.Strings(assets)
+		for _, asset := range assets {
+			weight := projection.ProjectedWeights[asset]
+			if weight > riskConfig.MaxAssetWeight && weight > projection.Weights[asset] {
+				return &RiskError{Reason: RiskMaxAssetWeight,
+					Detail: fmt.Sprintf("%s weight %.4f above %.4f", asset, weight, riskConfig.MaxAssetWeight)}
+			}
+		}
+	}
+	return nil
+}
+
+// rejectOrders logs and counts a failed risk check.
+func rejectOrders(err error) {
+	log.Println("Skipping orders:", err)
+	var riskErr *RiskError
+	if errors.As(err, &riskErr) {
+		metrics.RecordRiskRejection(riskErr.Reason)
+	}
+}
@@ -0,0 +1,30 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/turgaysozen/algotrading/decimal"
+	"github.com/turgaysozen/algotrading/models"
+)
+
+// Without a risk estimate the portfolio limits can't be checked, so orders are rejected
+// rather than let through unchecked.
+func TestCheckRiskFailsClosedWithoutEstimate(t *testing.T) {
+	riskConfigOnce.Do(func() {})
+	saved := riskConfig
+	t.Cleanup(func() { riskConfig = saved })
+
+	order := models.Order{Symbol: "BTCUSDT", OrderType: "buy", Price: decimal.FromInt(30000), Quantity: decimal.FromInt(1)}
+
+	riskConfig = RiskConfig{}
+	if err := checkRisk([]models.Order{order}); err != nil {
+		t.Fatalf("without limits: %v", err)
+	}
+
+	riskConfig = RiskConfig{VaRBudget: 1000}
+	var riskErr *RiskError
+	if err := checkRisk([]models.Order{order}); !errors.As(err, &riskErr) || riskErr.Reason != RiskUnavailable {
+		t.Errorf("with a VaR budget and no estimate: %v, want %s", err, RiskUnavailable)
+	}
+}
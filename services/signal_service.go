@@ -29,6 +29,8 @@ type SMA struct {
 	window []float64
 	period int
 	sum    float64
+	// updates since the running sum was last recomputed from the window
+	sinceResum int
 }
 
 func NewSMA(period int) *SMA {
@@ -48,9 +50,25 @@ func (s *SMA) AddPrice(price float64) float64 {
 		s.window = s.window[1:]
 	}
 
+	// the running sum adds and subtracts forever, so re-sum once per period
+	// to bound float drift while keeping the amortised cost O(1)
+	s.sinceResum++
+	if s.sinceResum >= s.period {
+		s.resum()
+	}
+
 	if len(s.window) < s.period {
 		return 0
 	}
 
 	return s.sum / float64(s.period)
 }
+
+func (s *SMA) resum() {
+	var sum float64
+	for _, p := range s.window {
+		sum += p
+	}
+	s.sum = sum
+	s.sinceResum = 0
+}
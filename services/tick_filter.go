@@ -0,0 +1,198 @@
+package services
+
+import (
+	"log"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/turgaysozen/algotrading/config"
+	"github.com/turgaysozen/algotrading/monitoring/metrics"
+)
+
+// Suppression reasons, used as the reason label of ticks_suppressed_total.
+const (
+	SuppressWideSpread = "wide_spread"
+	SuppressOutlier    = "outlier"
+)
+
+const madScale = 1.4826 // makes MAD a consistent estimator of the standard deviation
+
+type TickFilterConfig struct {
+	Mode         string // "mad", "pct" or "off"
+	Window       int
+	MADThreshold float64
+	JumpPct      float64
+	MaxSpreadBps float64
+	// ConfirmTicks is how many following ticks must agree with a suspicious price before
+	// it is treated as a genuine move. Zero drops every suspicious tick and keeps it out of
+	// the window, so the reference only ever moves with normal ticks.
+	ConfirmTicks int
+}
+
+func LoadTickFilterConfig() TickFilterConfig {
+	cfg := TickFilterConfig{
+		Mode:         config.GetEnv("TICK_FILTER_MODE", "mad"),
+		Window:       config.GetEnvInt("TICK_FILTER_WINDOW", 50),
+		MADThreshold: config.GetEnvFloat("TICK_FILTER_MAD_THRESHOLD", 6),
+		JumpPct:      config.GetEnvFloat("TICK_FILTER_JUMP_PCT", 2),
+		MaxSpreadBps: config.GetEnvFloat("TICK_FILTER_MAX_SPREAD_BPS", 50),
+		ConfirmTicks: config.GetEnvInt("TICK_FILTER_CONFIRM_TICKS", 3),
+	}
+	if cfg.Window < 1 {
+		log.Printf("Invalid TICK_FILTER_WINDOW %d, using 50", cfg.Window)
+		metrics.RecordError("tick_filter_config_invalid")
+		cfg.Window = 50
+	}
+	if cfg.ConfirmTicks < 0 {
+		log.Printf("Invalid TICK_FILTER_CONFIRM_TICKS %d, using 0", cfg.ConfirmTicks)
+		metrics.RecordError("tick_filter_config_invalid")
+		cfg.ConfirmTicks = 0
+	}
+	return cfg
+}
+
+// TickFilter suppresses fat-finger prints and stale-book artefacts for one symbol on one
+// exchange. Suspicious mid prices are quarantined until ConfirmTicks later ticks either
+// confirm the new level or the price returns to normal.
+type TickFilter struct {
+	mu       sync.Mutex
+	cfg      TickFilterConfig
+	exchange string
+	symbol   string
+	window   []float64
+	// quarantine holds at most ConfirmTicks suspicious prices
+	quarantine []float64
+}
+
+func NewTickFilter(exchange, symbol string, cfg TickFilterConfig) *TickFilter {
+	return &TickFilter{
+		cfg:      cfg,
+		exchange: exchange,
+		symbol:   symbol,
+		window:   make([]float64, 0, cfg.Window),
+	}
+}
+
+var tickFilterConfig TickFilterConfig
+var tickFilterConfigOnce sync.Once
+var tickFilterMap sync.Map
+
+func getTickFilter(exchange, symbol string) *TickFilter {
+	tickFilterConfigOnce.Do(func() {
+		tickFilterConfig = LoadTickFilterConfig()
+	})
+	if value, ok := tickFilterMap.Load(exchange + ":" + symbol); ok {
+		return value.(*TickFilter)
+	}
+	value, _ := tickFilterMap.LoadOrStore(exchange+":"+symbol, NewTickFilter(exchange, symbol, tickFilterConfig))
+	return value.(*TickFilter)
+}
+
+// Allow reports whether the tick may reach the strategy, logging and counting it when it may not.
+func (f *TickFilter) Allow(bid, ask float64) bool {
+	if f.cfg.Mode == "off" {
+		return true
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	mid := (bid + ask) / 2
+
+	if f.cfg.MaxSpreadBps > 0 {
+		spreadBps := (ask - bid) / mid * 10000
+		if spreadBps > f.cfg.MaxSpreadBps {
+			f.suppress(SuppressWideSpread, mid, "spread %.1f bps above %.1f", spreadBps, f.cfg.MaxSpreadBps)
+			return false
+		}
+	}
+
+	if !f.isSuspicious(mid) {
+		f.quarantine = f.quarantine[:0]
+		f.accept(mid)
+		return true
+	}
+
+	if f.cfg.ConfirmTicks == 0 {
+		f.suppress(SuppressOutlier, mid, "dropped")
+		return false
+	}
+
+	if len(f.quarantine) > 0 && !withinPct(mid, f.quarantine[len(f.quarantine)-1], f.cfg.JumpPct) {
+		// a different outlier: restart the confirmation
+		f.quarantine = f.quarantine[:0]
+	}
+	f.quarantine = append(f.quarantine, mid)
+
+	if len(f.quarantine) > f.cfg.ConfirmTicks {
+		log.Printf("Tick filter %s %s: price move to %.2f confirmed by %d ticks, resetting reference window",
+			f.exchange, f.symbol, mid, f.cfg.ConfirmTicks)
+		f.window = append(f.window[:0], f.quarantine...)
+		f.quarantine = f.quarantine[:0]
+		return true
+	}
+
+	f.suppress(SuppressOutlier, mid, "quarantined %d/%d", len(f.quarantine), f.cfg.ConfirmTicks)
+	return false
+}
+
+func (f *TickFilter) isSuspicious(mid float64) bool {
+	if len(f.window) == 0 {
+		return false
+	}
+
+	switch f.cfg.Mode {
+	case "pct":
+		return !withinPct(mid, f.window[len(f.window)-1], f.cfg.JumpPct)
+	default:
+		// the median and MAD need a few samples to mean anything
+		if len(f.window) < 10 {
+			return false
+		}
+		median, mad := medianAndMAD(f.window)
+		// floored at JumpPct, so a quiet market's tiny MAD doesn't flag ordinary moves
+		threshold := math.Max(f.cfg.MADThreshold*madScale*mad, median*f.cfg.JumpPct/100)
+		return math.Abs(mid-median) > threshold
+	}
+}
+
+func (f *TickFilter) accept(mid float64) {
+	f.window = append(f.window, mid)
+	if len(f.window) > f.cfg.Window {
+		f.window = f.window[1:]
+	}
+}
+
+func (f *TickFilter) suppress(reason string, mid float64, format string, args ...interface{}) {
+	args = append([]interface{}{f.exchange, f.symbol, reason, mid}, args...)
+	log.Printf("Tick filter %s %s: suppressed tick (%s) mid=%.2f: "+format, args...)
+	metrics.RecordTickSuppressed(f.exchange, f.symbol, reason)
+}
+
+func withinPct(value, reference, pct float64) bool {
+	if reference == 0 {
+		return false
+	}
+	return math.Abs(value-reference)/reference*100 <= pct
+}
+
+func medianAndMAD(values []float64) (float64, float64) {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	median := middle(sorted)
+
+	for i, v := range sorted {
+		sorted[i] = math.Abs(v - median)
+	}
+	sort.Float64s(sorted)
+	return median, middle(sorted)
+}
+
+func middle(sorted []float64) float64 {
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
@@ -0,0 +1,63 @@
+package services
+
+import "testing"
+
+func testFilterConfig(confirmTicks int) TickFilterConfig {
+	return TickFilterConfig{Mode: "mad", Window: 20, MADThreshold: 6, JumpPct: 2, MaxSpreadBps: 50, ConfirmTicks: confirmTicks}
+}
+
+func feed(f *TickFilter, mid float64) bool {
+	return f.Allow(mid-0.01, mid+0.01)
+}
+
+func TestTickFilterQuietMarketMove(t *testing.T) {
+	f := NewTickFilter("binance", "BTCUSDT", testFilterConfig(3))
+	for i := 0; i < 20; i++ {
+		feed(f, 100+float64(i%2)*0.001)
+	}
+	// a tiny MAD must not flag an ordinary 0.5% move
+	if !feed(f, 100.5) {
+		t.Fatal("ordinary move suppressed in a quiet market")
+	}
+	if feed(f, 150) {
+		t.Fatal("50% jump allowed")
+	}
+}
+
+func TestTickFilterConfirmZeroDrops(t *testing.T) {
+	f := NewTickFilter("binance", "BTCUSDT", testFilterConfig(0))
+	for i := 0; i < 20; i++ {
+		feed(f, 100)
+	}
+	for i := 0; i < 40; i++ {
+		if feed(f, 110) {
+			t.Fatalf("suppressed tick %d allowed with TICK_FILTER_CONFIRM_TICKS=0", i)
+		}
+	}
+	for _, mid := range f.window {
+		if mid != 100 {
+			t.Fatalf("suppressed price %.2f entered the reference window", mid)
+		}
+	}
+	if len(f.quarantine) != 0 {
+		t.Fatalf("quarantine grew to %d", len(f.quarantine))
+	}
+	if !feed(f, 100) {
+		t.Fatal("normal tick suppressed after the dropped ones")
+	}
+}
+
+func TestTickFilterConfirmsMove(t *testing.T) {
+	f := NewTickFilter("binance", "BTCUSDT", testFilterConfig(3))
+	for i := 0; i < 20; i++ {
+		feed(f, 100)
+	}
+	for i := 0; i < 3; i++ {
+		if feed(f, 110) {
+			t.Fatalf("tick %d of the move allowed before confirmation", i)
+		}
+	}
+	if !feed(f, 110) || len(f.quarantine) != 0 {
+		t.Fatal("move not confirmed after 3 ticks")
+	}
+}
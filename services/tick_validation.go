@@ -0,0 +1,73 @@
+package services
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/turgaysozen/algotrading/models"
+)
+
+// Rejection reasons, used as the reason label of ticks_rejected_total.
+const (
+	RejectEmptyBook     = "empty_book"
+	RejectZeroPrice     = "zero_price"
+	RejectNegativePrice = "negative_price"
+	RejectNaNPrice      = "nan_price"
+	RejectInfinitePrice = "infinite_price"
+	RejectCrossedBook   = "crossed_book"
+	// RejectMalformedPrice is recorded where the book is decoded, before it reaches ValidateTick.
+	RejectMalformedPrice = models.RejectMalformedPrice
+)
+
+// TickError describes why an order book tick was rejected before reaching the strategy.
+type TickError struct {
+	Symbol string
+	Reason string
+	Bid    float64
+	Ask    float64
+}
+
+func (e *TickError) Error() string {
+	return fmt.Sprintf("rejected tick for %s: %s (bid=%v ask=%v)", e.Symbol, e.Reason, e.Bid, e.Ask)
+}
+
+// ValidateTick checks every level price and the resulting top of book, returning the best bid and ask when valid.
+func ValidateTick(orderBook models.OrderBook) (float64, float64, error) {
+	if len(orderBook.Bids) == 0 || len(orderBook.Asks) == 0 {
+		return 0, 0, &TickError{Symbol: orderBook.Symbol, Reason: RejectEmptyBook}
+	}
+
+	for _, levels := range [][]models.PriceLevel{orderBook.Bids, orderBook.Asks} {
+		for _, level := range levels {
+			if reason := checkPrice(level.Price); reason != "" {
+				return 0, 0, &TickError{Symbol: orderBook.Symbol, Reason: reason, Bid: level.Price, Ask: level.Price}
+			}
+		}
+	}
+
+	bid := GetBestBidPrice(orderBook.Bids)
+	ask := GetBestAskPrice(orderBook.Asks)
+	// a side holding only zero quantity removals has no price
+	if bid == 0 || ask == 0 {
+		return 0, 0, &TickError{Symbol: orderBook.Symbol, Reason: RejectEmptyBook, Bid: bid, Ask: ask}
+	}
+	if bid >= ask {
+		return 0, 0, &TickError{Symbol: orderBook.Symbol, Reason: RejectCrossedBook, Bid: bid, Ask: ask}
+	}
+
+	return bid, ask, nil
+}
+
+func checkPrice(price float64) string {
+	switch {
+	case math.IsNaN(price):
+		return RejectNaNPrice
+	case math.IsInf(price, 0):
+		return RejectInfinitePrice
+	case price == 0:
+		return RejectZeroPrice
+	case price < 0:
+		return RejectNegativePrice
+	}
+	return ""
+}
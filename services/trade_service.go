@@ -0,0 +1,72 @@
+package services
+
+import (
+	"log"
+	"math"
+	"sync"
+
+	"github.com/turgaysozen/algotrading/bars"
+	"github.com/turgaysozen/algotrading/db"
+	"github.com/turgaysozen/algotrading/models"
+	"github.com/turgaysozen/algotrading/monitoring/metrics"
+)
+
+var lastTradeMap sync.Map
+
+var (
+	tradeHandlersMu sync.RWMutex
+	tradeHandlers   []func(models.Trade)
+)
+
+// OnTrade registers a strategy or execution algorithm to be called with every accepted trade.
+func OnTrade(handler func(models.Trade)) {
+	tradeHandlersMu.Lock()
+	tradeHandlers = append(tradeHandlers, handler)
+	tradeHandlersMu.Unlock()
+}
+
+// LastTrade returns the most recent trade seen for symbol.
+func LastTrade(symbol string) (models.Trade, bool) {
+	value, ok := lastTradeMap.Load(symbol)
+	if !ok {
+		return models.Trade{}, false
+	}
+	return value.(models.Trade), true
+}
+
+func ProcessTrade(trade models.Trade) {
+	if trade.TradeTime == 0 {
+		trade.TradeTime = clk.Now().UnixMilli()
+	}
+	if trade.Exchange == "" {
+		trade.Exchange = models.DefaultExchange
+	}
+
+	if trade.Price <= 0 || math.IsNaN(trade.Price) || math.IsInf(trade.Price, 0) || trade.Quantity <= 0 {
+		log.Printf("Skipping invalid trade %d for %s: price=%v qty=%v", trade.TradeID, trade.Symbol, trade.Price, trade.Quantity)
+		metrics.RecordTickRejection("invalid_trade")
+		return
+	}
+
+	err := db.SaveTrade(trade)
+	if err != nil {
+		log.Printf("Error saving trade: %v", err)
+		metrics.RecordError("trade_save_error")
+		metrics.RecordDataLoss("trade_save_data_loss")
+	}
+
+	// other exchanges' trades are stored only, like their books
+	if trade.Exchange != strategyExchange {
+		return
+	}
+
+	lastTradeMap.Store(trade.Symbol, trade)
+	bars.AddTrade(trade.Symbol, trade.TradeTime, trade.Price, trade.Quantity)
+
+	tradeHandlersMu.RLock()
+	handlers := tradeHandlers
+	tradeHandlersMu.RUnlock()
+	for _, handler := range handlers {
+		handler(trade)
+	}
+}
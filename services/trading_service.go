@@ -2,32 +2,71 @@ package services
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"sync"
 	"time"
 
+	"github.com/turgaysozen/algotrading/account"
+	"github.com/turgaysozen/algotrading/bars"
+	"github.com/turgaysozen/algotrading/clock"
 	"github.com/turgaysozen/algotrading/config"
 	"github.com/turgaysozen/algotrading/db"
+	"github.com/turgaysozen/algotrading/decimal"
+	"github.com/turgaysozen/algotrading/execution"
 	"github.com/turgaysozen/algotrading/models"
 	"github.com/turgaysozen/algotrading/monitoring/metrics"
-	"github.com/turgaysozen/algotrading/utils"
+	"github.com/turgaysozen/algotrading/portfolio"
 )
 
 var priceDataMap sync.Map
 var lastSignalMap sync.Map
 var smaMap sync.Map
 
+// TickTimeframe runs the strategy on every order book tick instead of on bar closes.
+const TickTimeframe = "tick"
+
+var strategyTimeframe = TickTimeframe
+
+// strategyExchange is the exchange the strategy trades; books from other exchanges are only stored.
+var strategyExchange = models.DefaultExchange
+
+var clk clock.Clock = clock.Real{}
+
 func ProcessOrderBook(orderBook models.OrderBook) {
-	if len(orderBook.Bids) == 0 || len(orderBook.Asks) == 0 {
-		log.Println("No bids or asks data received.")
+	if orderBook.EventTime == 0 {
+		orderBook.EventTime = clk.Now().UnixMilli()
+	}
+	if orderBook.Exchange == "" {
+		orderBook.Exchange = models.DefaultExchange
+	}
+
+	bidPrice, askPrice, err := ValidateTick(orderBook)
+	if err != nil {
+		log.Println("Skipping order book:", err)
+		var tickErr *TickError
+		if errors.As(err, &tickErr) {
+			metrics.RecordTickRejection(tickErr.Reason)
+		}
+		return
+	}
+
+	// outliers are held back from storage, arbitrage and portfolio rates as well as the strategy
+	if !getTickFilter(orderBook.Exchange, orderBook.Symbol).Allow(bidPrice, askPrice) {
 		return
 	}
 
-	bidPrice := GetBestBidPrice(orderBook.Bids)
-	askPrice := GetBestAskPrice(orderBook.Asks)
 	midPrice := (bidPrice + askPrice) / 2
+	primary := orderBook.Exchange == strategyExchange
+
+	var micro models.Microstructure
+	if primary {
+		micro = updateMicrostructure(orderBook, bidPrice, askPrice)
+	} else {
+		micro = computeMicrostructure(orderBook, bidPrice, askPrice)
+	}
 
-	orderBookID, err := db.SaveOrderBook(orderBook.EventType, orderBook.Symbol, orderBook.EventTime, bidPrice, askPrice)
+	orderBookID, err := db.SaveOrderBook(orderBook.Exchange, orderBook.EventType, orderBook.Symbol, orderBook.EventTime, bidPrice, askPrice, micro)
 	if err != nil {
 		log.Printf("Error saving order book: %v", err)
 		metrics.RecordError("orderbook_save_error")
@@ -37,19 +76,71 @@ func ProcessOrderBook(orderBook models.OrderBook) {
 
 	metrics.RecordLatency("orderbook_avg")
 
-	log.Printf("ID: %d | Symbol: %s | EventTime: %d | Bid: %.2f | Ask: %.2f | Mid Price: %.2f\n",
-		orderBookID, orderBook.Symbol, orderBook.EventTime, bidPrice, askPrice, midPrice)
+	log.Printf("ID: %d | Exchange: %s | Symbol: %s | EventTime: %d | Bid: %.2f | Ask: %.2f | Mid Price: %.2f\n",
+		orderBookID, orderBook.Exchange, orderBook.Symbol, orderBook.EventTime, bidPrice, askPrice, midPrice)
+
+	updateCrossExchange(orderBook)
+	portfolio.UpdateRate(orderBook.Symbol, bidPrice, askPrice, orderBook.EventTime)
+
+	if !primary {
+		return
+	}
+
+	execution.OnBook(orderBook.Symbol, orderBook.Bids, orderBook.Asks, orderBook.EventTime)
+	bars.AddMidPrice(orderBook.Symbol, orderBook.EventTime, midPrice)
+
+	if strategyTimeframe == TickTimeframe {
+		runStrategy(orderBook.Symbol, midPrice, orderBook.EventTime)
+		runPairs(orderBook.Symbol, midPrice, orderBook.EventTime)
+	}
+}
+
+// SetClock replaces the wall clock, e.g. with a simulated clock during replays and backtests.
+// Events without an exchange timestamp are stamped with it, and so is everything the account,
+// portfolio and execution packages time.
+func SetClock(c clock.Clock) {
+	clk = c
+	account.SetClock(c)
+	portfolio.SetClock(c)
+	execution.SetClock(c)
+}
+
+// InitStrategy runs the SMA and pairs strategies on every tick, or on bar closes when STRATEGY_TIMEFRAME names a bar timeframe.
+func InitStrategy() {
+	strategyExchange = config.GetEnv("STRATEGY_EXCHANGE", models.DefaultExchange)
+	strategyTimeframe = config.GetEnv("STRATEGY_TIMEFRAME", TickTimeframe)
+	initPairs()
+	OnTrade(execution.OnTrade)
+	if strategyTimeframe == TickTimeframe {
+		return
+	}
+
+	err := bars.Subscribe(strategyTimeframe, func(bar models.Bar) {
+		runStrategy(bar.Symbol, bar.Close, bar.CloseTime)
+		runPairs(bar.Symbol, bar.Close, bar.CloseTime)
+	})
+	if err != nil {
+		log.Printf("Invalid STRATEGY_TIMEFRAME, running on ticks: %v", err)
+		metrics.RecordError("strategy_timeframe_invalid")
+		strategyTimeframe = TickTimeframe
+		return
+	}
+	log.Println("Strategy running on bar closes for timeframe", strategyTimeframe)
+}
 
+// runStrategy feeds one price into the SMA crossover; eventTime is the exchange time in milliseconds
+// that resulting signals and orders are stamped with.
+func runStrategy(symbol string, midPrice float64, eventTime int64) {
 	metrics.SetStartTime("signal_avg")
 	metrics.SetStartTime("order_avg")
 
-	value, _ := priceDataMap.LoadOrStore(orderBook.Symbol, &[]float64{})
+	value, _ := priceDataMap.LoadOrStore(symbol, &[]float64{})
 	priceData := value.(*[]float64)
 
 	appendPriceData(priceData, midPrice)
 
 	if len(*priceData) >= config.MaxPriceCount {
-		smaValue, _ := smaMap.LoadOrStore(orderBook.Symbol, struct {
+		smaValue, _ := smaMap.LoadOrStore(symbol, struct {
 			shortSMA *SMA
 			longSMA  *SMA
 		}{
@@ -65,28 +156,36 @@ func ProcessOrderBook(orderBook models.OrderBook) {
 		shortSMAValue := sma.shortSMA.AddPrice(midPrice)
 		longSMAValue := sma.longSMA.AddPrice(midPrice)
 
-		value, _ := lastSignalMap.LoadOrStore(orderBook.Symbol, "")
+		value, _ := lastSignalMap.LoadOrStore(symbol, "")
 		lastSignal := value.(string)
 
 		newSignal, reason := CheckSignal(shortSMAValue, longSMAValue, lastSignal)
 
 		if newSignal != lastSignal {
-			lastSignalMap.Store(orderBook.Symbol, newSignal)
-			saveSignal(newSignal, midPrice, shortSMAValue, longSMAValue, reason, orderBook.Symbol)
+			lastSignalMap.Store(symbol, newSignal)
+			saveSignal(newSignal, midPrice, shortSMAValue, longSMAValue, reason, symbol, eventTime)
 		}
 	}
 }
 
-func saveSignal(newSignal string, midPrice, shortSMA, longSMA float64, reason, symbol string) {
+func saveSignal(newSignal string, midPrice, shortSMA, longSMA float64, reason, symbol string, eventTime int64) {
 	signal := models.Signal{
-		Type:     newSignal,
-		Price:    midPrice,
-		ShortSMA: shortSMA,
-		LongSMA:  longSMA,
-		Reason:   reason,
+		Symbol:    symbol,
+		Type:      newSignal,
+		Price:     midPrice,
+		ShortSMA:  shortSMA,
+		LongSMA:   longSMA,
+		Reason:    reason,
+		EventTime: eventTime,
 	}
 
 	err := db.SaveSignal(signal)
+	if errors.Is(err, db.ErrDuplicateSignal) {
+		// another instance or an earlier attempt already acted on this signal
+		log.Printf("%s for %s at %d is already stored, skipping it", newSignal, symbol, eventTime)
+		metrics.RecordDuplicateOrder("signals")
+		return
+	}
 	if err != nil {
 		log.Printf("Error saving signal: %v", err)
 		metrics.RecordError("signal_save_error")
@@ -96,26 +195,40 @@ func saveSignal(newSignal string, midPrice, shortSMA, longSMA float64, reason, s
 
 	signalJSON, _ := json.MarshalIndent(signal, "", "  ")
 	log.Println("Signal saved successfully:", string(signalJSON))
+	notifySignal(signal)
 
-	saveOrder(newSignal, midPrice, symbol)
+	saveOrder(newSignal, midPrice, symbol, eventTime)
 	metrics.RecordLatency("signal_avg")
 }
 
-func saveOrder(newSignal string, midPrice float64, symbol string) {
-	lastOrder, err := db.GetLastOpenOrder()
+func saveOrder(newSignal string, midPrice float64, symbol string, eventTime int64) {
+	precision := config.PrecisionFor(symbol)
+	price := decimal.FromFloat(midPrice).Round(precision.Price)
+
+	// a parent still working the previous signal is canceled, saving what it filled so it can be closed
+	execution.CancelSymbol(symbol)
+
+	lastOrder, err := db.GetLastOpenOrder(symbol)
 	if err != nil {
 		log.Printf("Error retrieving last open order: %v", err)
 		return
 	}
 
 	if lastOrder != nil {
-		err := db.CloseOrder(lastOrder.ID)
-		if err != nil {
+		closed := closeAt(*lastOrder, midPrice)
+		err := db.CloseOrder(closed.ID, closed.ClosePrice, closed.CloseFee, closed.CloseSlippage, closed.PnL, eventTime)
+		switch {
+		case errors.Is(err, db.ErrOrderNotOpen):
+			log.Printf("Last order with ID %d was already closed", lastOrder.ID)
+		case err != nil:
 			log.Printf("Error closing last open order: %v", err)
 			metrics.RecordError("order_close_error")
 			return
+		default:
+			account.RecordFill(closingFill(closed, eventTime))
+			recordClosed(closed)
+			log.Printf("Closing last order with ID: %d at %s, fee: %s, PnL: %s\n", closed.ID, closed.ClosePrice, closed.CloseFee, closed.PnL)
 		}
-		log.Printf("Closing last order with ID: %d\n", lastOrder.ID)
 	}
 
 	orderType := "sell"
@@ -124,53 +237,120 @@ func saveOrder(newSignal string, midPrice float64, symbol string) {
 	}
 
 	order := models.Order{
-		Price:     midPrice,
-		Quantity:  1.0,
-		Status:    "open",
-		OrderType: orderType,
+		Symbol:        symbol,
+		Quantity:      decimal.FromInt(1).Round(precision.Quantity),
+		Status:        "open",
+		OrderType:     orderType,
+		EventTime:     eventTime,
+		ClientOrderID: execution.ClientOrderID("sma", symbol, eventTime),
+		Strategy:      "sma",
+		SignalPrice:   price,
 	}
+	fillPrice := takerPrice(symbol, orderType, midPrice)
+	order = withCosts(order, fillPrice, takerFee(symbol, fillPrice, order.Quantity))
 
-	err = db.SaveOrder(order)
-	if err != nil {
-		log.Printf("Error saving order: %v", err)
-		metrics.RecordError("order_save_error")
-		metrics.RecordDataLoss("order_save_data_loss")
+	if err := checkRisk([]models.Order{order}); err != nil {
+		rejectOrders(err)
 		return
 	}
 
-	log.Printf("Order saved successfully: Type= %s, Price= %.2f, Symbol= %s, Timestamp= %s",
-		orderType, midPrice, symbol, time.Now())
-	metrics.RecordLatency("order_avg")
-}
+	switch {
+	case execution.Enabled():
+		_, err := execution.Submit(symbol, orderType, order.Quantity, eventTime, order.ClientOrderID, func(parent models.ParentOrder) {
+			storeExecutedOrder(order, parent.FilledQty, parent.AvgPrice, parent.Fee)
+		})
+		if err == nil {
+			return
+		}
+		if errors.Is(err, execution.ErrDuplicateOrder) {
+			log.Printf("Order %s was already submitted, not submitting it again", order.ClientOrderID)
+			return
+		}
+		log.Printf("Error submitting parent order, saving it as a market order: %v", err)
+		metrics.RecordError("execution_submit_error")
 
-func GetBestBidPrice(bids [][]interface{}) float64 {
-	if len(bids) == 0 {
-		return 0
+	case execution.LimitOrdersEnabled():
+		limit := execution.StrategyLimitOrder(symbol, orderType, order.Quantity, midPrice, eventTime, order.ClientOrderID)
+		_, err := execution.PlaceLimit(limit, func(placed models.LimitOrder) {
+			storeExecutedOrder(order, placed.FilledQty, placed.AvgPrice, placed.Fee)
+		})
+		if err == nil {
+			return
+		}
+		if errors.Is(err, execution.ErrDuplicateOrder) {
+			log.Printf("Order %s was already placed, not placing it again", order.ClientOrderID)
+			return
+		}
+		log.Printf("Error placing limit order, saving it as a market order: %v", err)
+		metrics.RecordError("limit_order_place_error")
 	}
 
-	bestBid := bids[0]
-	for _, bid := range bids {
-		bidPrice := utils.StringToFloat64(bid[0].(string))
-		if bidPrice > utils.StringToFloat64(bestBid[0].(string)) {
-			bestBid = bid
-		}
+	if storeOrder(order) {
+		metrics.RecordLatency("order_avg")
 	}
-	return utils.StringToFloat64(bestBid[0].(string))
 }
 
-func GetBestAskPrice(asks [][]interface{}) float64 {
-	if len(asks) == 0 {
-		return 0
+// storeExecutedOrder saves order with the fills and fees of the parent or limit order that executed it.
+func storeExecutedOrder(order models.Order, filledQty, avgPrice, fee decimal.Decimal) {
+	if filledQty.Sign() == 0 {
+		log.Printf("%s %s finished without fills, no order saved", order.OrderType, order.Symbol)
+		return
 	}
+	order.Quantity = filledQty
+	storeOrder(withCosts(order, avgPrice.Round(config.PrecisionFor(order.Symbol).Price), fee))
+}
 
-	bestAsk := asks[0]
-	for _, ask := range asks {
-		askPrice := utils.StringToFloat64(ask[0].(string))
-		if askPrice < utils.StringToFloat64(bestAsk[0].(string)) {
-			bestAsk = ask
-		}
+// storeOrder saves order; one whose client order ID is already stored, by a retry or another
+// instance handling the same signal, is skipped.
+func storeOrder(order models.Order) bool {
+	err := db.SaveOrder(order)
+	if errors.Is(err, db.ErrDuplicateOrder) {
+		log.Printf("Order %s is already stored, skipping the duplicate", order.ClientOrderID)
+		metrics.RecordDuplicateOrder("orders")
+		return false
+	}
+	if err != nil {
+		log.Printf("Error saving order: %v", err)
+		metrics.RecordError("order_save_error")
+		metrics.RecordDataLoss("order_save_data_loss")
+		return false
+	}
+	recordOpened(order)
+	return true
+}
+
+// recordOpened books a saved order's fill on the account and notifies the order hooks.
+func recordOpened(order models.Order) {
+	account.RecordFill(models.Fill{
+		Symbol: order.Symbol, Side: order.OrderType, Quantity: order.Quantity, Price: order.Price, Fee: order.Fee,
+		EventTime: order.EventTime,
+	})
+
+	log.Printf("Order saved successfully: Type= %s, Price= %s, Quantity= %s, Fee= %s, Slippage= %s, Symbol= %s, Timestamp= %s",
+		order.OrderType, order.Price, order.Quantity, order.Fee, order.Slippage, order.Symbol, time.UnixMilli(order.EventTime).UTC())
+	notifyOrder(order)
+}
+
+// calculatePnL returns the realised PnL of closing order at closePrice, net of the fees paid
+// on opening it and closeFee.
+func calculatePnL(order models.Order, closePrice, closeFee decimal.Decimal) decimal.Decimal {
+	gross := closePrice.Sub(order.Price).Mul(order.Quantity)
+	if order.OrderType == "sell" {
+		gross = gross.Neg()
 	}
-	return utils.StringToFloat64(bestAsk[0].(string))
+	return gross.Sub(order.Fee).Sub(closeFee)
+}
+
+// GetBestBidPrice returns the best bid with quantity, or 0 without one.
+func GetBestBidPrice(bids []models.PriceLevel) float64 {
+	best, _ := models.BestBid(bids)
+	return best.Price
+}
+
+// GetBestAskPrice returns the best ask with quantity, or 0 without one.
+func GetBestAskPrice(asks []models.PriceLevel) float64 {
+	best, _ := models.BestAsk(asks)
+	return best.Price
 }
 
 func appendPriceData(priceData *[]float64, midPrice float64) {
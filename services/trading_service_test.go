@@ -0,0 +1,46 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/turgaysozen/algotrading/db"
+	"github.com/turgaysozen/algotrading/decimal"
+	"github.com/turgaysozen/algotrading/models"
+)
+
+// Two instances, or a retry, handling the same tick against one store must act on its signal
+// once: the second neither closes the order the first opened nor opens another.
+func TestSameTickTwiceSavesOneOrder(t *testing.T) {
+	riskConfigOnce.Do(func() {})
+	saved := riskConfig
+	riskConfig = RiskConfig{}
+	t.Cleanup(func() { riskConfig = saved })
+
+	store := db.NewMemory()
+	db.Use(store)
+	t.Cleanup(func() { db.Use(db.NewMemory()) })
+
+	err := store.SaveOrder(models.Order{Symbol: "BTCUSDT", OrderType: "sell", Status: "open", Price: decimal.FromInt(30000),
+		Quantity: decimal.FromInt(1), EventTime: 1704067200000, ClientOrderID: "sma-BTCUSDT-1704067200000", Strategy: "sma"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		saveSignal("BUY Signal!", 29900, 29950, 29940, "Short SMA crossed above Long SMA", "BTCUSDT", 1704067260000)
+	}
+
+	if signals := store.Signals(); len(signals) != 1 {
+		t.Errorf("stored %d signals, want 1", len(signals))
+	}
+	orders := store.Orders()
+	if len(orders) != 2 {
+		t.Fatalf("stored %d orders, want the earlier one and one for the signal", len(orders))
+	}
+	if orders[0].Status != "closed" {
+		t.Errorf("earlier order is %s, want closed", orders[0].Status)
+	}
+	if got := orders[1]; got.ClientOrderID != "sma-BTCUSDT-1704067260000" || got.OrderType != "buy" || got.Status != "open" {
+		t.Errorf("signal's order = %s %s %s, want sma-BTCUSDT-1704067260000 buy open", got.ClientOrderID, got.OrderType, got.Status)
+	}
+}
@@ -1,16 +1,40 @@
 package utils
 
 import (
+	"errors"
+	"fmt"
+	"math"
 	"strconv"
 
 	"github.com/turgaysozen/algotrading/monitoring/metrics"
 )
 
-func StringToFloat64(s string) float64 {
+var ErrNotFinite = errors.New("value is not finite")
+
+// ParseError is returned when a numeric field from the exchange can't be used as a number.
+type ParseError struct {
+	Input string
+	Err   error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("cannot parse %q as float: %v", e.Input, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// StringToFloat64 parses s and rejects NaN and infinities, which strconv accepts.
+func StringToFloat64(s string) (float64, error) {
 	val, err := strconv.ParseFloat(s, 64)
 	if err != nil {
 		metrics.RecordError("StringToFloat64_error")
-		return 0
+		return 0, &ParseError{Input: s, Err: err}
+	}
+	if math.IsNaN(val) || math.IsInf(val, 0) {
+		metrics.RecordError("StringToFloat64_error")
+		return 0, &ParseError{Input: s, Err: ErrNotFinite}
 	}
-	return val
+	return val, nil
 }
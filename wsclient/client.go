@@ -2,86 +2,217 @@ package wsclient
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"os"
-	"time"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/gorilla/websocket"
+	"github.com/turgaysozen/algotrading/config"
 	"github.com/turgaysozen/algotrading/models"
 	"github.com/turgaysozen/algotrading/monitoring/metrics"
-	"github.com/turgaysozen/algotrading/redisclient"
+	"github.com/turgaysozen/algotrading/retry"
 )
 
-var Connected bool = false
+// Connected reports whether at least one feed connection is up.
+var Connected atomic.Bool
 
-func ConnectWebSocket() (*websocket.Conn, error) {
-	url := os.Getenv("WEB_SOCKET_URL")
+var (
+	upMu    sync.Mutex
+	upConns = make(map[string]bool)
+)
+
+type endpoint struct {
+	name string
+	url  string
+}
+
+// feedEndpoints spreads WEB_SOCKET_CONNECTIONS connections round robin over the comma
+// separated WEB_SOCKET_URLS, falling back to the single WEB_SOCKET_URL.
+func feedEndpoints() ([]endpoint, error) {
+	var urls []string
+	for _, url := range strings.Split(config.GetEnv("WEB_SOCKET_URLS", os.Getenv("WEB_SOCKET_URL")), ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			urls = append(urls, url)
+		}
+	}
+	if len(urls) == 0 {
+		return nil, errors.New("WebSocket URL not set in .env file")
+	}
+
+	connections := config.GetEnvInt("WEB_SOCKET_CONNECTIONS", len(urls))
+	if connections < 1 {
+		log.Printf("Invalid WEB_SOCKET_CONNECTIONS %d, using 1", connections)
+		metrics.RecordError("websocket_config_invalid")
+		connections = 1
+	}
+
+	endpoints := make([]endpoint, connections)
+	for i := range endpoints {
+		endpoints[i] = endpoint{name: strconv.Itoa(i), url: urls[i%len(urls)]}
+	}
+	return endpoints, nil
+}
 
-	if url == "" {
-		log.Fatal("WebSocket URL not set in .env file")
+// Run opens every Binance feed connection and passes the merged feed to onOrderBook and
+// onTrade, each event once from whichever connection delivers it first. It returns once the
+// reconnect policy has given up on every connection; readiness keeps reporting the
+// WebSocket as unreachable after that.
+func Run(onOrderBook func(models.OrderBook), onTrade func(models.Trade)) {
+	endpoints, err := feedEndpoints()
+	if err != nil {
+		log.Println("Error configuring WebSocket feed:", err)
 		metrics.RecordError("websocket_url_missing")
+		return
 	}
 
+	frames := make(chan frame, frameBuffer)
+	var wg sync.WaitGroup
+	for _, e := range endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			superviseConnections(e, frames)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(frames)
+	}()
+
+	for f := range frames {
+		handleFrame(f, onOrderBook, onTrade)
+	}
+}
+
+// connect dials one endpoint according to the WEB_SOCKET_RETRY_* policy.
+func connect(e endpoint) (*websocket.Conn, error) {
 	var conn *websocket.Conn
-	var connectErr error
-	maxRetries := 5
-	retryCount := 0
-
-	for {
-		conn, _, connectErr = websocket.DefaultDialer.Dial(url, nil)
-		if connectErr != nil {
-			Connected = false
-			retryCount++
-			log.Printf("Error connecting to WebSocket, retrying %d/%d... %v", retryCount, maxRetries, connectErr)
+	err := retry.Do("websocket_"+e.name, retry.LoadPolicy("WEB_SOCKET"), func() error {
+		var err error
+		conn, _, err = websocket.DefaultDialer.Dial(e.url, nil)
+		if err != nil {
 			metrics.RecordError("websocket_connection_error")
-			if retryCount >= maxRetries {
-				log.Fatal("Max retry attempts reached, giving up.")
-				metrics.RecordDataLoss("websocket_connection_max_retries")
-				return nil, connectErr
-			}
-			time.Sleep(5 * time.Second)
-			continue
 		}
-
-		Connected = true
-		log.Println("WebSocket connected to:", url)
-		break
+		return err
+	})
+	if err != nil {
+		metrics.RecordDataLoss("websocket_connection_max_retries")
+		return nil, err
 	}
 
-	Connected = true
+	log.Printf("WebSocket connection %s connected to: %s", e.name, e.url)
+	keepAlive(conn)
+	setConnectionUp(e.name, true)
+
+	if err := subscribeTradeStreams(conn); err != nil {
+		log.Println("Error subscribing to trade streams:", err)
+		metrics.RecordError("websocket_subscribe_error")
+	}
 
 	return conn, nil
 }
 
-func ProcessWebSocketMessages(conn *websocket.Conn) {
-	for {
-		_, msg, err := conn.ReadMessage()
-		if err != nil {
-			Connected = false
-			log.Println("Error reading WebSocket message:", err)
-			metrics.RecordError("websocket_connection_error")
-			conn.Close()
-			conn, err = ConnectWebSocket()
-			if err != nil {
-				log.Fatal("Error reconnecting to WebSocket:", err)
-			}
-			continue
-		}
+func setConnectionUp(name string, up bool) {
+	upMu.Lock()
+	defer upMu.Unlock()
 
-		Connected = true
+	upConns[name] = up
+	any := false
+	for _, connUp := range upConns {
+		any = any || connUp
+	}
+	Connected.Store(any)
+	metrics.SetConnectionUp("websocket", any)
+}
 
-		// track latency for orderbook avg processing
-		metrics.SetStartTime("orderbook_avg")
+// HandleMessage decodes one raw feed frame and passes it to onOrderBook or onTrade,
+// dropping events that were already delivered. The replayer processes recorded frames
+// through it inline.
+func HandleMessage(msg []byte, onOrderBook func(models.OrderBook), onTrade func(models.Trade)) {
+	env, ok := decodeEnvelope(msg)
+	if !ok {
+		return
+	}
 
-		var orderBook models.OrderBook
-		err = json.Unmarshal(msg, &orderBook)
-		if err != nil {
-			log.Println("Error unmarshalling WebSocket message:", err)
-			metrics.RecordError("json_unmarshal_error")
-			metrics.RecordDataLoss("json_unmarshal_data_loss")
-			continue
+	_, last := env.ids()
+	if !firstDelivery(env.Symbol, env.EventType, last) {
+		return
+	}
+	dispatch(env, msg, onOrderBook, onTrade)
+}
+
+func dispatch(env envelope, msg []byte, onOrderBook func(models.OrderBook), onTrade func(models.Trade)) {
+	switch env.EventType {
+	case "trade", "aggTrade":
+		handleTrade(msg, onTrade)
+	case snapshotEventType:
+		handleSnapshot(msg, onOrderBook)
+	case "":
+		// subscription acknowledgements carry no event type
+	default:
+		handleOrderBook(msg, onOrderBook)
+	}
+}
+
+// subscribeTradeStreams subscribes to the comma separated streams in WEB_SOCKET_TRADE_STREAMS
+// (e.g. btcusdt@aggTrade) on top of the depth stream named in the URL.
+func subscribeTradeStreams(conn *websocket.Conn) error {
+	streams := os.Getenv("WEB_SOCKET_TRADE_STREAMS")
+	if streams == "" {
+		return nil
+	}
+
+	request := struct {
+		Method string   `json:"method"`
+		Params []string `json:"params"`
+		ID     int      `json:"id"`
+	}{
+		Method: "SUBSCRIBE",
+		Params: strings.Split(streams, ","),
+		ID:     1,
+	}
+	return conn.WriteJSON(request)
+}
+
+func handleOrderBook(msg []byte, onOrderBook func(models.OrderBook)) {
+	// track latency for orderbook avg processing
+	metrics.SetStartTime("orderbook_avg")
+
+	var orderBook models.OrderBook
+	err := json.Unmarshal(msg, &orderBook)
+	if err != nil {
+		log.Println("Error unmarshalling WebSocket message:", err)
+		var levelErr *models.PriceLevelError
+		if errors.As(err, &levelErr) {
+			metrics.RecordTickRejection(models.RejectMalformedPrice)
 		}
+		metrics.RecordError("json_unmarshal_error")
+		metrics.RecordDataLoss("json_unmarshal_data_loss")
+		return
+	}
 
-		redisclient.Publish("order_book", orderBook)
+	if !localBooks {
+		onOrderBook(orderBook)
+		return
 	}
+	for _, book := range applyToLocalBook(orderBook) {
+		onOrderBook(book)
+	}
+}
+
+func handleTrade(msg []byte, onTrade func(models.Trade)) {
+	var trade models.Trade
+	err := json.Unmarshal(msg, &trade)
+	if err != nil {
+		log.Println("Error unmarshalling trade message:", err)
+		metrics.RecordError("json_unmarshal_error")
+		metrics.RecordDataLoss("trade_unmarshal_data_loss")
+		return
+	}
+
+	onTrade(trade)
 }
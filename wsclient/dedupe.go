@@ -0,0 +1,29 @@
+package wsclient
+
+import "sync"
+
+// Redundant and overlapping connections deliver every event more than once. Binance update
+// and trade IDs only increase within a stream, so the first copy of each ID is kept and
+// anything at or below the last delivered ID is dropped.
+
+var (
+	deliveredMu sync.Mutex
+	delivered   = make(map[string]int64)
+)
+
+// firstDelivery reports whether id is new for the symbol's stream of eventType and marks it
+// delivered. Events without an ID are always delivered.
+func firstDelivery(symbol, eventType string, id int64) bool {
+	if id == 0 {
+		return true
+	}
+
+	key := symbol + "@" + eventType
+	deliveredMu.Lock()
+	defer deliveredMu.Unlock()
+	if id <= delivered[key] {
+		return false
+	}
+	delivered[key] = id
+	return true
+}
@@ -0,0 +1,113 @@
+package wsclient
+
+import (
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/turgaysozen/algotrading/config"
+	"github.com/turgaysozen/algotrading/monitoring/metrics"
+)
+
+// Binance closes every connection after 24 hours. Shortly before that a second connection
+// is opened to the same endpoint and both feed the merged frame channel for
+// WEB_SOCKET_HANDOVER_OVERLAP, with duplicates dropped by update ID, before the old
+// connection is closed. The local order books never see the disconnect.
+
+const frameBuffer = 1024
+
+type feedConn struct {
+	name   string
+	conn   *websocket.Conn
+	opened time.Time
+	failed chan error
+}
+
+// startReading forwards every frame read from conn into frames until a read fails.
+func startReading(name string, conn *websocket.Conn, frames chan<- frame) *feedConn {
+	fc := &feedConn{name: name, conn: conn, opened: time.Now(), failed: make(chan error, 1)}
+	go func() {
+		defer forgetConn(conn)
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				fc.failed <- err
+				return
+			}
+			received := time.Now()
+			conn.SetReadDeadline(received.Add(readTimeout()))
+			frames <- frame{conn: name, data: msg, received: received}
+		}
+	}()
+	return fc
+}
+
+// superviseConnections keeps one connection to e reading into frames, reconnecting after
+// failures and handing over ahead of the scheduled disconnect. It returns when the
+// reconnect policy gives up.
+func superviseConnections(e endpoint, frames chan<- frame) {
+	handoverAfter := config.GetEnvDuration("WEB_SOCKET_HANDOVER_AFTER", 23*time.Hour+30*time.Minute)
+	overlap := config.GetEnvDuration("WEB_SOCKET_HANDOVER_OVERLAP", 10*time.Second)
+
+	conn, err := connect(e)
+	if err != nil {
+		log.Printf("Error connecting WebSocket connection %s: %v", e.name, err)
+		return
+	}
+
+	current := startReading(e.name, conn, frames)
+	for {
+		handover := time.NewTimer(time.Until(current.opened.Add(handoverAfter)))
+
+		select {
+		case err := <-current.failed:
+			handover.Stop()
+			setConnectionUp(current.name, false)
+			metrics.SetConnectionUp("websocket_"+current.name, false)
+			log.Printf("Error reading WebSocket connection %s: %v", current.name, err)
+			metrics.RecordError("websocket_connection_error")
+			current.conn.Close()
+			// diffs were missed while no connection was up
+			if !Connected.Load() {
+				resnapshot()
+			}
+
+			conn, err := connect(endpoint{name: current.name, url: e.url})
+			if err != nil {
+				log.Printf("Error reconnecting WebSocket connection %s: %v", current.name, err)
+				return
+			}
+			current = startReading(current.name, conn, frames)
+
+		case <-handover.C:
+			log.Printf("Opening a second WebSocket connection to %s ahead of the scheduled disconnect", e.url)
+			name := handoverName(e.name, current.name)
+			conn, err := connect(endpoint{name: name, url: e.url})
+			if err != nil {
+				// keep the old connection; its failure is handled as a normal reconnect
+				log.Println("Error opening WebSocket handover connection:", err)
+				metrics.RecordError("websocket_handover_error")
+				current.opened = time.Now()
+				continue
+			}
+
+			frames <- frame{conn: name, reset: true}
+			next := startReading(name, conn, frames)
+			time.Sleep(overlap)
+			current.conn.Close()
+			setConnectionUp(current.name, false)
+			metrics.SetConnectionUp("websocket_"+current.name, false)
+			log.Printf("WebSocket connection %s handed over to %s", current.name, name)
+			current = next
+		}
+	}
+}
+
+// handoverName alternates a connection's label between its endpoint's name and a handover
+// name, so the overlapping connections are told apart without a new label every day.
+func handoverName(endpoint, current string) string {
+	if current == endpoint {
+		return endpoint + "-handover"
+	}
+	return endpoint
+}
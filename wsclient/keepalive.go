@@ -0,0 +1,85 @@
+package wsclient
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/turgaysozen/algotrading/config"
+	"github.com/turgaysozen/algotrading/monitoring/metrics"
+)
+
+// A half-open TCP connection never returns a read error on its own, so every connection
+// gets a read deadline that is pushed out by messages, pings and pongs, and a pinger that
+// keeps the exchange answering even when the market is quiet.
+
+const writeWait = 10 * time.Second
+
+var (
+	connMu    sync.Mutex
+	openConns = make(map[*websocket.Conn]bool)
+)
+
+func readTimeout() time.Duration {
+	return config.GetEnvDuration("WEB_SOCKET_READ_TIMEOUT", time.Minute)
+}
+
+func keepAlive(conn *websocket.Conn) {
+	timeout := readTimeout()
+	conn.SetReadDeadline(time.Now().Add(timeout))
+
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(timeout))
+	})
+	conn.SetPingHandler(func(data string) error {
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		err := conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(writeWait))
+		if err == websocket.ErrCloseSent {
+			return nil
+		}
+		return err
+	})
+
+	connMu.Lock()
+	openConns[conn] = true
+	connMu.Unlock()
+
+	interval := config.GetEnvDuration("WEB_SOCKET_PING_INTERVAL", 20*time.Second)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				// the connection is closed or broken; the read loop reconnects
+				return
+			}
+		}
+	}()
+}
+
+func forgetConn(conn *websocket.Conn) {
+	connMu.Lock()
+	delete(openConns, conn)
+	connMu.Unlock()
+}
+
+// Reconnect drops every local book and connection; the books reload from fresh snapshots
+// once the read loops reconnect.
+func Reconnect() {
+	resnapshot()
+	closeConns()
+}
+
+// closeConns makes every read loop fail and reconnect.
+func closeConns() {
+	connMu.Lock()
+	defer connMu.Unlock()
+
+	for conn := range openConns {
+		if err := conn.Close(); err != nil {
+			log.Println("Error closing WebSocket:", err)
+			metrics.RecordError("websocket_close_error")
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package wsclient
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/turgaysozen/algotrading/config"
+	"github.com/turgaysozen/algotrading/models"
+	"github.com/turgaysozen/algotrading/monitoring/metrics"
+	"github.com/turgaysozen/algotrading/orderbook"
+	"github.com/turgaysozen/algotrading/recorder"
+)
+
+// When local books are enabled, depth diffs are applied to a per-symbol local book and
+// the top of that book is published instead of the raw diff. Snapshots are fetched over
+// REST in the background while the symbol's updates are held, and loaded with the next
+// update, so a slow snapshot never blocks the other symbols' updates. Loaded snapshots
+// are also written to the recorder as synthetic "depthSnapshot" frames, so a replay
+// rebuilds exactly the same books without calling the exchange.
+
+const snapshotEventType = "depthSnapshot"
+
+const (
+	minSnapshotInterval = time.Second
+	maxPending          = 1000
+)
+
+type snapshotFrame struct {
+	EventType string             `json:"e"`
+	Symbol    string             `json:"s"`
+	Snapshot  orderbook.Snapshot `json:"snapshot"`
+}
+
+var (
+	localBooks    bool
+	snapshotURL   string
+	snapshotLimit int
+	publishDepth  int
+
+	booksMu       sync.Mutex
+	books         = make(map[string]*orderbook.Book)
+	lastSnapshots = make(map[string]time.Time)
+	pending       = make(map[string][]models.OrderBook)
+	// fetching marks the symbols with a snapshot request in flight; fetched holds the
+	// snapshots waiting for their symbol's next update to be loaded
+	fetching = make(map[string]bool)
+	fetched  = make(map[string]orderbook.Snapshot)
+)
+
+// UseLocalBooks turns on local order books. With an empty snapshotURL books are only
+// loaded from recorded snapshot frames, which is what replays use.
+func UseLocalBooks(url string) {
+	localBooks = true
+	snapshotURL = url
+	snapshotLimit = config.GetEnvInt("DEPTH_SNAPSHOT_LIMIT", 1000)
+	if snapshotLimit < 1 {
+		log.Printf("Invalid DEPTH_SNAPSHOT_LIMIT %d, using 1000", snapshotLimit)
+		metrics.RecordError("orderbook_config_invalid")
+		snapshotLimit = 1000
+	}
+	publishDepth = config.GetEnvInt("ORDER_BOOK_DEPTH", 20)
+}
+
+func getBook(symbol string) *orderbook.Book {
+	booksMu.Lock()
+	defer booksMu.Unlock()
+	book, ok := books[symbol]
+	if !ok {
+		book = orderbook.New()
+		books[symbol] = book
+	}
+	return book
+}
+
+// resnapshot drops every local book; each is reloaded from a fresh snapshot on its next update.
+func resnapshot() {
+	booksMu.Lock()
+	defer booksMu.Unlock()
+	for _, book := range books {
+		book.Reset()
+	}
+	clear(fetched)
+}
+
+// applyToLocalBook applies update to the symbol's local book and returns the top of the book
+// after every update that changed it. Updates that arrive while the book is unsynced are held
+// and applied once a snapshot is loaded.
+func applyToLocalBook(update models.OrderBook) []models.OrderBook {
+	book := getBook(update.Symbol)
+	queue := append(takePending(update.Symbol), update)
+
+	if !book.Synced() {
+		syncBook(update.Symbol, book)
+	}
+	return applyQueued(update.Symbol, book, queue)
+}
+
+func applyQueued(symbol string, book *orderbook.Book, queue []models.OrderBook) []models.OrderBook {
+	var published []models.OrderBook
+	for i, update := range queue {
+		applied, err := book.Apply(update)
+		if errors.Is(err, orderbook.ErrSequenceGap) {
+			log.Printf("Order book %s: %v, resnapshotting", symbol, err)
+			metrics.RecordError("orderbook_sequence_gap")
+			syncBook(symbol, book)
+			applied, err = book.Apply(update)
+		}
+		if errors.Is(err, orderbook.ErrNotSynced) {
+			holdPending(symbol, queue[i:])
+			return published
+		}
+		if !applied {
+			continue
+		}
+		// levels beyond the snapshot's depth were never loaded, so the book is kept to it
+		book.Trim(snapshotLimit)
+
+		bids, asks := book.Top(publishDepth)
+		published = append(published, models.OrderBook{
+			EventType:     update.EventType,
+			Symbol:        update.Symbol,
+			EventTime:     update.EventTime,
+			FirstUpdateID: update.FirstUpdateID,
+			FinalUpdateID: update.FinalUpdateID,
+			Bids:          bids,
+			Asks:          asks,
+		})
+	}
+	return published
+}
+
+func takePending(symbol string) []models.OrderBook {
+	booksMu.Lock()
+	defer booksMu.Unlock()
+	queue := pending[symbol]
+	delete(pending, symbol)
+	return queue
+}
+
+func holdPending(symbol string, queue []models.OrderBook) {
+	if dropped := len(queue) - maxPending; dropped > 0 {
+		metrics.RecordDataLoss("orderbook_pending_overflow")
+		queue = queue[dropped:]
+	}
+	booksMu.Lock()
+	pending[symbol] = queue
+	booksMu.Unlock()
+}
+
+// syncBook loads the snapshot fetched for symbol if there is one, and otherwise requests one
+// in the background, at most once per minSnapshotInterval per symbol so a burst of gaps
+// can't exhaust the exchange's request weight.
+func syncBook(symbol string, book *orderbook.Book) {
+	if snapshotURL == "" {
+		return
+	}
+
+	booksMu.Lock()
+	snapshot, ready := fetched[symbol]
+	delete(fetched, symbol)
+	if !ready && !fetching[symbol] && time.Since(lastSnapshots[symbol]) >= minSnapshotInterval {
+		fetching[symbol] = true
+		lastSnapshots[symbol] = time.Now()
+		go fetchSnapshot(symbol)
+	}
+	booksMu.Unlock()
+	if !ready {
+		return
+	}
+
+	book.LoadSnapshot(snapshot)
+	log.Printf("Order book %s synced at update %d", symbol, snapshot.LastUpdateID)
+
+	frame, err := json.Marshal(snapshotFrame{EventType: snapshotEventType, Symbol: strings.ToUpper(symbol), Snapshot: snapshot})
+	if err == nil {
+		recorder.Record(frame)
+	}
+}
+
+func fetchSnapshot(symbol string) {
+	snapshot, err := orderbook.FetchSnapshot(snapshotURL, symbol, snapshotLimit)
+
+	booksMu.Lock()
+	delete(fetching, symbol)
+	if err == nil {
+		fetched[symbol] = snapshot
+	}
+	booksMu.Unlock()
+
+	if err != nil {
+		log.Printf("Error fetching order book snapshot for %s: %v", symbol, err)
+		metrics.RecordError("orderbook_snapshot_error")
+	}
+}
+
+// handleSnapshot loads a recorded snapshot during replays and applies the updates held for it.
+// Live runs already loaded the snapshot when they fetched it.
+func handleSnapshot(msg []byte, onOrderBook func(models.OrderBook)) {
+	if !localBooks || snapshotURL != "" {
+		return
+	}
+
+	var frame snapshotFrame
+	if err := json.Unmarshal(msg, &frame); err != nil {
+		log.Println("Error unmarshalling recorded snapshot:", err)
+		metrics.RecordError("json_unmarshal_error")
+		return
+	}
+
+	book := getBook(frame.Symbol)
+	book.LoadSnapshot(frame.Snapshot)
+	for _, orderBook := range applyQueued(frame.Symbol, book, takePending(frame.Symbol)) {
+		onOrderBook(orderBook)
+	}
+}
@@ -0,0 +1,173 @@
+package wsclient
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/turgaysozen/algotrading/models"
+	"github.com/turgaysozen/algotrading/monitoring/metrics"
+	"github.com/turgaysozen/algotrading/recorder"
+)
+
+// With several connections every event arrives once per connection. Frames are merged in
+// a single goroutine: the first copy of each update or trade ID is recorded and processed,
+// later copies only feed the per-connection latency, loss and leader statistics.
+
+const (
+	leaderWindow  = 10 * time.Second
+	latencyWeight = 0.1
+)
+
+type frame struct {
+	conn     string
+	data     []byte
+	received time.Time
+	// reset carries no data: a new connection took over the label and its statistics start over
+	reset bool
+}
+
+// envelope holds the fields needed to route and deduplicate a frame. EventTime and
+// TradeTime are decoded so "E" and "T" can't match "e" and "t" case-insensitively.
+type envelope struct {
+	EventType     string          `json:"e"`
+	EventTime     int64           `json:"E"`
+	Symbol        string          `json:"s"`
+	FirstUpdateID int64           `json:"U"`
+	FinalUpdateID int64           `json:"u"`
+	TradeID       int64           `json:"t"`
+	TradeTime     int64           `json:"T"`
+	AggTradeID    json.RawMessage `json:"a"` // the asks on depth updates
+}
+
+func decodeEnvelope(msg []byte) (envelope, bool) {
+	var env envelope
+	if err := json.Unmarshal(msg, &env); err != nil {
+		log.Println("Error unmarshalling WebSocket message:", err)
+		metrics.RecordError("json_unmarshal_error")
+		metrics.RecordDataLoss("json_unmarshal_data_loss")
+		return env, false
+	}
+	return env, true
+}
+
+// ids returns the first and last update or trade ID the event covers, or zeros for events
+// without IDs.
+func (e envelope) ids() (int64, int64) {
+	switch e.EventType {
+	case "aggTrade":
+		id, _ := strconv.ParseInt(string(e.AggTradeID), 10, 64)
+		return id, id
+	case "trade":
+		return e.TradeID, e.TradeID
+	}
+	return e.FirstUpdateID, e.FinalUpdateID
+}
+
+type connStats struct {
+	lastIDs map[string]int64
+	latency float64
+	wins    int
+}
+
+// only touched by the merging goroutine
+var (
+	connections = make(map[string]*connStats)
+	leader      string
+	windowStart time.Time
+)
+
+func handleFrame(f frame, onOrderBook func(models.OrderBook), onTrade func(models.Trade)) {
+	if f.reset {
+		delete(connections, f.conn)
+		return
+	}
+
+	env, ok := decodeEnvelope(f.data)
+	if !ok {
+		return
+	}
+
+	_, last := env.ids()
+	first := firstDelivery(env.Symbol, env.EventType, last)
+	observe(f, env, first)
+	if !first {
+		return
+	}
+
+	recorder.Record(f.data)
+	dispatch(env, f.data, onOrderBook, onTrade)
+}
+
+// observe updates the statistics of the connection that delivered f.
+func observe(f frame, env envelope, first bool) {
+	firstID, lastID := env.ids()
+	if lastID == 0 {
+		return
+	}
+
+	stats, ok := connections[f.conn]
+	if !ok {
+		stats = &connStats{lastIDs: make(map[string]int64)}
+		connections[f.conn] = stats
+	}
+
+	// gaps in a connection's own sequence are what it lost, whether or not another connection covered them
+	key := env.Symbol + "@" + env.EventType
+	if previous, ok := stats.lastIDs[key]; ok && firstID > previous+1 {
+		metrics.RecordFeedMissedUpdates(f.conn, float64(firstID-previous-1))
+	}
+	if lastID > stats.lastIDs[key] {
+		stats.lastIDs[key] = lastID
+	}
+
+	if env.EventTime > 0 {
+		latency := f.received.Sub(time.UnixMilli(env.EventTime)).Seconds()
+		if stats.latency == 0 {
+			stats.latency = latency
+		} else {
+			stats.latency += latencyWeight * (latency - stats.latency)
+		}
+		metrics.SetFeedConnectionLatency(f.conn, stats.latency)
+	}
+
+	metrics.RecordFeedEvent(f.conn, first)
+	if first {
+		stats.wins++
+	}
+	updateLeader(f.received)
+}
+
+// updateLeader names the connection that delivered the most events first over the last
+// leaderWindow; ties keep the current leader.
+func updateLeader(now time.Time) {
+	if windowStart.IsZero() {
+		windowStart = now
+	}
+	if now.Sub(windowStart) < leaderWindow {
+		return
+	}
+	windowStart = now
+
+	best, bestWins := leader, -1
+	if stats, ok := connections[leader]; ok {
+		bestWins = stats.wins
+	}
+	for name, stats := range connections {
+		if stats.wins > bestWins {
+			best, bestWins = name, stats.wins
+		}
+		stats.wins = 0
+	}
+
+	if best == leader {
+		return
+	}
+	log.Printf("Feed connection %s is now leading", best)
+	if leader != "" {
+		metrics.SetFeedLeader(leader, false)
+	}
+	metrics.SetFeedLeader(best, true)
+	leader = best
+}